@@ -1,28 +1,54 @@
 package certificates
 
 import (
+	"bytes"
 	"crypto"
+	cryptorand "crypto/rand"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 
 	"go.aporeto.io/tg/tglib"
 
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	certificatev1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
 	certificateclient "github.com/CodingJzy/trireme-csr/pkg/client/clientset/versioned"
 )
 
+// renewalRetryInterval is the time to wait before retrying a failed renewal.
+const renewalRetryInterval = 30 * time.Second
+
+// errCertTimeout is returned when a Certificate has not been issued within the timeout. The request stays
+// in place, so that waiting for it can be resumed.
+var errCertTimeout = fmt.Errorf("Timed out for certificate generation")
+
+// RenewalCallback is called once a certificate has been renewed, with the new certificate, key and token.
+type RenewalCallback func(cert *x509.Certificate, key crypto.PrivateKey, smartToken []byte)
+
+// TokenCallback is called once the token of the current certificate has been refreshed by the controller.
+type TokenCallback func(smartToken []byte)
+
 // CertManager manages the client side for the client.
 // It encapsulates the PrivateKey that should always remain private to this pod.
 type CertManager struct {
 	certName   string
 	keyPass    string
+	keyType    KeyType
 	privateKey crypto.PrivateKey
+	// duration and usages are requested for the certificate, the controller defaults apply if unset
+	duration *metav1.Duration
+	usages   []certificatev1alpha2.KeyUsage
 	// CSR is encoded in PEM format.
 	csr []byte
 
@@ -31,41 +57,98 @@ type CertManager struct {
 
 	caCertPEM []byte
 	caCert    *x509.Certificate
+	// chainPEM holds the intermediate CAs between the certificate and the root CA
+	chainPEM []byte
 
 	smartToken []byte
 
+	// objectName is the name of the Certificate that holds the current certificate. Renewals are requested
+	// through new Certificates, so that the current one stays in place until it has been replaced.
+	objectName string
+	// pending is the renewal that has been requested and not been issued yet
+	pending *pendingRenewal
+
 	certClient certificateclient.Interface
+
+	renewalCallbacks []RenewalCallback
+	tokenCallbacks   []TokenCallback
+
+	sync.RWMutex
+}
+
+// issuedCert holds everything that the controller returns for a certificate request.
+type issuedCert struct {
+	certPEM    []byte
+	cert       *x509.Certificate
+	caCertPEM  []byte
+	caCert     *x509.Certificate
+	chainPEM   []byte
+	smartToken []byte
+}
+
+// pendingRenewal is a renewal request that waits for its certificate
+type pendingRenewal struct {
+	name       string
+	privateKey crypto.PrivateKey
+	csr        []byte
 }
 
 // NewCertManager creates a NewCertManager with default.
 func NewCertManager(name string, certClient certificateclient.Interface) (*CertManager, error) {
 	return &CertManager{
 		certName:   name,
+		keyType:    KeyTypeECDSA,
 		certClient: certClient,
 	}, nil
 }
 
+// SetKeyType sets the type of the private keys that get generated for this Certificate. Defaults to ECDSA.
+func (m *CertManager) SetKeyType(keyType KeyType) error {
+	switch keyType {
+	case KeyTypeECDSA, KeyTypeEd25519:
+	default:
+		return fmt.Errorf("unsupported key type '%s'", keyType)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	m.keyType = keyType
+	return nil
+}
+
+// SetRequestOptions sets the validity and the usages that get requested for the certificate.
+// A zero duration or no usages request the defaults of the controller.
+func (m *CertManager) SetRequestOptions(duration time.Duration, usages []certificatev1alpha2.KeyUsage) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.duration = nil
+	if duration > 0 {
+		m.duration = &metav1.Duration{Duration: duration}
+	}
+	m.usages = usages
+}
+
 // GeneratePrivateKey generate the private key that will be used for this Certificate.
 func (m *CertManager) GeneratePrivateKey() error {
-	privateKey, err := tglib.ECPrivateKeyGenerator()
-	m.privateKey = privateKey
+	m.Lock()
+	defer m.Unlock()
+
+	privateKey, err := generatePrivateKey(m.keyType)
 	if err != nil {
 		return err
 	}
+
+	m.privateKey = privateKey
 	return nil
 }
 
 // GenerateCSR generates the CSR associated with the key
 func (m *CertManager) GenerateCSR() error {
-	emailAddress := "aporeto@aporeto.com"
+	m.Lock()
+	defer m.Unlock()
 
-	certRequest, err := tglib.GenerateSimpleCSR(
-		[]string{"trireme"},
-		[]string{"unit"},
-		"commonName",
-		[]string{emailAddress},
-		m.privateKey,
-	)
+	certRequest, err := generateCSR(m.privateKey)
 	if err != nil {
 		return err
 	}
@@ -74,14 +157,41 @@ func (m *CertManager) GenerateCSR() error {
 	return nil
 }
 
+// generateCSR generates a PEM encoded CSR for the given key
+func generateCSR(privateKey crypto.PrivateKey) ([]byte, error) {
+	emailAddress := "aporeto@aporeto.com"
+
+	// the signature algorithm is picked by the key type
+	template := &x509.CertificateRequest{
+		Subject: pkix.Name{
+			Organization:       []string{"trireme"},
+			OrganizationalUnit: []string{"unit"},
+			CommonName:         "commonName",
+		},
+		EmailAddresses: []string{emailAddress},
+	}
+
+	csrDER, err := x509.CreateCertificateRequest(cryptorand.Reader, template, privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}), nil
+}
+
 // GetKey return the privateKey
 func (m *CertManager) GetKey() crypto.PrivateKey {
+	m.RLock()
+	defer m.RUnlock()
 	return m.privateKey
 }
 
 // GetKeyPEM return the privateKey in PEM format
 func (m *CertManager) GetKeyPEM() ([]byte, error) {
-	keyPEM, err := tglib.KeyToPEM(m.privateKey)
+	m.RLock()
+	defer m.RUnlock()
+
+	keyPEM, err := keyToPEM(m.privateKey)
 	if err != nil {
 		return nil, fmt.Errorf("Error marshaling Private Key %s", err)
 	}
@@ -92,6 +202,9 @@ func (m *CertManager) GetKeyPEM() ([]byte, error) {
 
 // GetCert return the privateKey
 func (m *CertManager) GetCert() (*x509.Certificate, error) {
+	m.RLock()
+	defer m.RUnlock()
+
 	if m.cert == nil {
 		return nil, fmt.Errorf("Cert is not received yet")
 	}
@@ -100,6 +213,9 @@ func (m *CertManager) GetCert() (*x509.Certificate, error) {
 
 // GetCertPEM return the privateKey in PEM format
 func (m *CertManager) GetCertPEM() ([]byte, error) {
+	m.RLock()
+	defer m.RUnlock()
+
 	if m.cert == nil {
 		return nil, fmt.Errorf("Cert is not received yet")
 	}
@@ -109,6 +225,9 @@ func (m *CertManager) GetCertPEM() ([]byte, error) {
 
 // GetCaCert returns the privateKey
 func (m *CertManager) GetCaCert() (*x509.Certificate, error) {
+	m.RLock()
+	defer m.RUnlock()
+
 	if m.caCert == nil {
 		return nil, fmt.Errorf("Cert is not received yet")
 	}
@@ -118,6 +237,9 @@ func (m *CertManager) GetCaCert() (*x509.Certificate, error) {
 
 // GetCaCertPEM returns the privateKey in PEM format
 func (m *CertManager) GetCaCertPEM() ([]byte, error) {
+	m.RLock()
+	defer m.RUnlock()
+
 	if m.caCertPEM == nil {
 		return nil, fmt.Errorf("Cert is not received yet")
 	}
@@ -125,8 +247,24 @@ func (m *CertManager) GetCaCertPEM() ([]byte, error) {
 	return m.caCertPEM, nil
 }
 
+// GetChainPEM returns the PEM encoded intermediate CAs that the certificate chains up to the root CA through.
+// They must be presented together with the certificate. It is empty if the root CA issued the certificate.
+func (m *CertManager) GetChainPEM() ([]byte, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	if m.cert == nil {
+		return nil, fmt.Errorf("Cert is not received yet")
+	}
+
+	return m.chainPEM, nil
+}
+
 // GetSmartToken returns the GetSmartToken
 func (m *CertManager) GetSmartToken() ([]byte, error) {
+	m.RLock()
+	defer m.RUnlock()
+
 	if m.smartToken == nil {
 		return nil, fmt.Errorf("SmartToken is not received yet")
 	}
@@ -134,80 +272,394 @@ func (m *CertManager) GetSmartToken() ([]byte, error) {
 	return m.smartToken, nil
 }
 
+// AddRenewalCallback registers a callback that gets called every time the certificate has been renewed.
+func (m *CertManager) AddRenewalCallback(callback RenewalCallback) {
+	m.Lock()
+	defer m.Unlock()
+	m.renewalCallbacks = append(m.renewalCallbacks, callback)
+}
+
+// AddTokenCallback registers a callback that gets called every time the token has been refreshed without
+// renewing the certificate.
+func (m *CertManager) AddTokenCallback(callback TokenCallback) {
+	m.Lock()
+	defer m.Unlock()
+	m.tokenCallbacks = append(m.tokenCallbacks, callback)
+}
+
+// StartTokenWatch picks up the tokens that the controller refreshes for the current certificate, by checking
+// the Certificate every `interval` until stopCh closes. A certificate must have been received before.
+func (m *CertManager) StartTokenWatch(interval time.Duration, stopCh <-chan struct{}) error {
+	if interval <= 0 {
+		return fmt.Errorf("token watch interval must be positive: %s", interval)
+	}
+	if _, err := m.GetCert(); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				if err := m.updateToken(); err != nil {
+					zap.L().Warn("Error checking for a refreshed token", zap.Error(err), zap.String("certName", m.certName))
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// updateToken swaps in the token of the Certificate if it has been refreshed for the current certificate
+func (m *CertManager) updateToken() error {
+	m.RLock()
+	objectName := m.objectName
+	m.RUnlock()
+
+	cert, err := m.certClient.CertmanagerV1alpha2().Certificates().Get(objectName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if cert.Status.Phase != certificatev1alpha2.CertificateSigned || len(cert.Status.Token) == 0 {
+		return nil
+	}
+
+	m.Lock()
+	// a token for another certificate belongs to a renewal, which swaps in its token itself
+	if !bytes.Equal(bytes.TrimSpace(cert.Status.Certificate), bytes.TrimSpace(m.certPEM)) || bytes.Equal(cert.Status.Token, m.smartToken) {
+		m.Unlock()
+		return nil
+	}
+	m.smartToken = cert.Status.Token
+	callbacks := append([]TokenCallback{}, m.tokenCallbacks...)
+	m.Unlock()
+
+	zap.L().Info("Token refreshed", zap.String("certName", m.certName))
+	for _, callback := range callbacks {
+		callback(cert.Status.Token)
+	}
+	return nil
+}
+
+// RequestTokenRefresh asks the controller to refresh the token of the current certificate, which then gets
+// picked up by StartTokenWatch.
+func (m *CertManager) RequestTokenRefresh() error {
+	m.RLock()
+	objectName := m.objectName
+	m.RUnlock()
+	if objectName == "" {
+		return fmt.Errorf("Cert is not received yet")
+	}
+
+	cert, err := m.certClient.CertmanagerV1alpha2().Certificates().Get(objectName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if cert.Annotations == nil {
+		cert.Annotations = map[string]string{}
+	}
+	cert.Annotations[certificatev1alpha2.TokenRefreshAnnotation] = time.Now().UTC().Format(time.RFC3339Nano)
+	_, err = m.certClient.CertmanagerV1alpha2().Certificates().Update(cert)
+	return err
+}
+
 // SendAndWaitforCert is a blocking func that issue the CertificateRequest and
 // returns once the Certificate is available.
 func (m *CertManager) SendAndWaitforCert(timeout time.Duration) error {
+	m.RLock()
+	csr := m.csr
+	m.RUnlock()
 
-	// First check if the certificate was already issued.
-	certs, err := m.certClient.CertmanagerV1alpha2().Certificates().List(metav1.ListOptions{})
+	// we start over, so the renewals that a previous run left behind are not needed anymore
+	if err := m.deleteRenewals(); err != nil {
+		return err
+	}
+	if err := m.deleteCert(m.certName); err != nil {
+		return err
+	}
+	if err := m.createRequest(m.certName, "", csr); err != nil {
+		return err
+	}
+
+	issued, err := m.waitForCert(m.certName, timeout)
+	if err != nil {
+		return err
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	m.setIssuedCert(issued)
+	m.objectName = m.certName
+	return nil
+}
+
+// StartRenewal renews the certificate in the background until stopCh closes. The certificate gets re-keyed
+// and re-submitted once `renewFraction` of its lifetime has passed, randomized by up to `jitter` of its lifetime.
+// `timeout` is the timeout of every renewal request. A certificate must have been received before.
+func (m *CertManager) StartRenewal(renewFraction, jitter float64, timeout time.Duration, stopCh <-chan struct{}) error {
+	if renewFraction <= 0 || renewFraction >= 1 {
+		return fmt.Errorf("renewal fraction must be between 0 and 1: %f", renewFraction)
+	}
+	if jitter < 0 || jitter >= renewFraction || renewFraction+jitter >= 1 {
+		return fmt.Errorf("renewal jitter must be positive and keep the renewal within the certificate lifetime: %f", jitter)
+	}
+	// renewals are found by the name of the certificate in their label
+	if errs := validation.IsValidLabelValue(m.certName); len(errs) > 0 {
+		return fmt.Errorf("certificate name '%s' can not be renewed, as it is not a valid label value: %s", m.certName, strings.Join(errs, ", "))
+	}
+	if _, err := m.GetCert(); err != nil {
+		return err
+	}
+
+	go m.runRenewal(renewFraction, jitter, timeout, stopCh)
+	return nil
+}
+
+// runRenewal is the renewal loop started by StartRenewal
+func (m *CertManager) runRenewal(renewFraction, jitter float64, timeout time.Duration, stopCh <-chan struct{}) {
+	var failed bool
+	for {
+		wait := renewalRetryInterval
+		if !failed {
+			cert, _ := m.GetCert()
+			wait = time.Until(renewalTime(cert, renewFraction, jitter))
+		}
+		zap.L().Info("Next certificate renewal scheduled", zap.String("certName", m.certName), zap.Duration("in", wait))
+
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(wait):
+		}
+
+		if err := m.renew(timeout); err != nil {
+			zap.L().Error("Error renewing certificate", zap.Error(err), zap.String("certName", m.certName))
+			failed = true
+			continue
+		}
+		failed = false
+	}
+}
+
+// renew re-keys and re-submits the certificate through a new Certificate, and swaps the new material in once it
+// has been issued. The Certificate of the current certificate is only deleted then, so that it stays known until it
+// has been replaced. A renewal that timed out is resumed by the next call, so that it keeps its approval.
+func (m *CertManager) renew(timeout time.Duration) error {
+	zap.L().Info("Renewing certificate", zap.String("certName", m.certName))
+
+	m.RLock()
+	keyType := m.keyType
+	pending := m.pending
+	m.RUnlock()
+
+	if pending == nil {
+		privateKey, err := generatePrivateKey(keyType)
+		if err != nil {
+			return fmt.Errorf("couldn't generate private key: %s", err.Error())
+		}
+
+		csr, err := generateCSR(privateKey)
+		if err != nil {
+			return fmt.Errorf("couldn't generate CSR: %s", err.Error())
+		}
+
+		pending = &pendingRenewal{
+			name:       fmt.Sprintf("%s-%d", m.certName, time.Now().Unix()),
+			privateKey: privateKey,
+			csr:        csr,
+		}
+		if err := m.createRequest(pending.name, m.certName, csr); err != nil {
+			return err
+		}
+
+		m.Lock()
+		m.pending = pending
+		m.Unlock()
+	}
+
+	issued, err := m.waitForCert(pending.name, timeout)
+	if err != nil {
+		if err != errCertTimeout {
+			// the renewal has failed for good, so the next attempt starts over with a new key
+			m.Lock()
+			m.pending = nil
+			m.Unlock()
+			if deleteErr := m.deleteCert(pending.name); deleteErr != nil {
+				zap.L().Warn("Error deleting failed renewal", zap.Error(deleteErr), zap.String("certName", m.certName), zap.String("name", pending.name))
+			}
+		}
+		return err
+	}
+
+	m.Lock()
+	replaced := m.objectName
+	m.privateKey = pending.privateKey
+	m.csr = pending.csr
+	m.setIssuedCert(issued)
+	m.objectName = pending.name
+	m.pending = nil
+	callbacks := append([]RenewalCallback{}, m.renewalCallbacks...)
+	m.Unlock()
+
+	zap.L().Info("Certificate renewed", zap.String("certName", m.certName), zap.String("name", pending.name), zap.Time("notAfter", issued.cert.NotAfter))
+	for _, callback := range callbacks {
+		callback(issued.cert, pending.privateKey, issued.smartToken)
+	}
+
+	if err := m.deleteCert(replaced); err != nil {
+		zap.L().Warn("Error deleting replaced certificate", zap.Error(err), zap.String("certName", m.certName), zap.String("name", replaced))
+	}
+	return nil
+}
+
+// setIssuedCert stores the issued material. The lock must be held by the caller.
+func (m *CertManager) setIssuedCert(issued *issuedCert) {
+	m.certPEM = issued.certPEM
+	m.cert = issued.cert
+	m.caCertPEM = issued.caCertPEM
+	m.caCert = issued.caCert
+	m.chainPEM = issued.chainPEM
+	m.smartToken = issued.smartToken
+}
+
+// renewalTime returns the time at which `cert` should be renewed
+func renewalTime(cert *x509.Certificate, renewFraction, jitter float64) time.Time {
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	offset := float64(lifetime) * (renewFraction + jitter*(2*rand.Float64()-1))
+	return cert.NotBefore.Add(time.Duration(offset))
+}
+
+// deleteCert deletes the Certificate with the given name if it exists. A revoked certificate must be kept,
+// so that it stays on the CRL, and the deletion is refused.
+func (m *CertManager) deleteCert(name string) error {
+	cert, err := m.certClient.CertmanagerV1alpha2().Certificates().Get(name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("Couldn't query for existing CSR object: %s", err.Error())
+	}
+	if cert.Status.Phase == certificatev1alpha2.CertificateRevoked {
+		return fmt.Errorf("existing cert '%s' for node has been revoked (%s), refusing to replace it", name, cert.Status.RevocationReason)
+	}
+	if err = m.certClient.CertmanagerV1alpha2().Certificates().Delete(name, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("Error deleting existing cert for node: %s", err.Error())
+	}
+	return nil
+}
+
+// deleteRenewals deletes the renewals of our certificate that have not been revoked
+func (m *CertManager) deleteRenewals() error {
+	// a certificate whose name is not a valid label value can not have been renewed
+	if len(validation.IsValidLabelValue(m.certName)) > 0 {
+		return nil
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{certificatev1alpha2.RenewalOfLabel: m.certName})
+	certs, err := m.certClient.CertmanagerV1alpha2().Certificates().List(metav1.ListOptions{LabelSelector: selector.String()})
 	if err != nil {
 		return fmt.Errorf("Couldn't query for existing CSR object list: %s", err.Error())
 	}
 	for _, cert := range certs.Items {
-		if cert.Name == m.certName {
-			if err = m.certClient.CertmanagerV1alpha2().Certificates().Delete(cert.Name, &metav1.DeleteOptions{}); err != nil {
-				return fmt.Errorf("Error deleting existing cert for node: %s", err.Error())
-			}
+		if cert.Status.Phase == certificatev1alpha2.CertificateRevoked {
+			continue
+		}
+		if err = m.certClient.CertmanagerV1alpha2().Certificates().Delete(cert.Name, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("Error deleting existing renewal for node: %s", err.Error())
 		}
 	}
+	return nil
+}
 
+// createRequest submits the PEM encoded CSR to the controller through a new Certificate with the given name.
+// `renewalOf` is the name of the Certificate that gets renewed, if any.
+func (m *CertManager) createRequest(name, renewalOf string, csr []byte) error {
 	// Generate the new certificate kube object
+	m.RLock()
 	kubeCert := &certificatev1alpha2.Certificate{
 		Spec: certificatev1alpha2.CertificateSpec{
-			Request: m.csr,
+			Request:  csr,
+			Duration: m.duration,
+			Usages:   m.usages,
 		},
 	}
-	kubeCert.Name = m.certName
+	m.RUnlock()
+	kubeCert.Name = name
+	if renewalOf != "" {
+		kubeCert.Labels = map[string]string{certificatev1alpha2.RenewalOfLabel: renewalOf}
+	}
 
-	zap.L().Info("Creating new certificate object on Kube API", zap.String("certName", m.certName))
-	_, err = m.certClient.CertmanagerV1alpha2().Certificates().Create(kubeCert)
+	zap.L().Info("Creating new certificate object on Kube API", zap.String("certName", m.certName), zap.String("name", name))
+	_, err := m.certClient.CertmanagerV1alpha2().Certificates().Create(kubeCert)
 	if err != nil {
 		return fmt.Errorf("couldn't create CSR Kube object: %s", err.Error())
 	}
+	return nil
+}
 
+// waitForCert waits until the certificate of the Certificate with the given name is available.
+// It returns errCertTimeout if it has not been issued within the timeout.
+func (m *CertManager) waitForCert(name string, timeout time.Duration) (*issuedCert, error) {
 	timeoutChan := time.After(timeout)
-	tickerChan := time.NewTicker(time.Second).C
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-timeoutChan:
-			return fmt.Errorf("Timed out for certificate generation")
+			return nil, errCertTimeout
 
-		case <-tickerChan:
-			zap.L().Info("Verifying if Certificate was issued by controller...", zap.String("certName", m.certName))
-			cert, err := m.certClient.CertmanagerV1alpha2().Certificates().Get(m.certName, metav1.GetOptions{})
+		case <-ticker.C:
+			zap.L().Info("Verifying if Certificate was issued by controller...", zap.String("certName", m.certName), zap.String("name", name))
+			cert, err := m.certClient.CertmanagerV1alpha2().Certificates().Get(name, metav1.GetOptions{})
 			if err != nil {
-				return fmt.Errorf("existing CSR object deleted %s", err.Error())
+				if errors.IsNotFound(err) {
+					return nil, fmt.Errorf("existing CSR object deleted %s", err.Error())
+				}
+				zap.L().Warn("Error getting Certificate", zap.Error(err), zap.String("certName", m.certName), zap.String("name", name))
+				continue
 			}
 
 			switch cert.Status.Phase {
 			case certificatev1alpha2.CertificateRejected:
-				return fmt.Errorf("Certificate issuing has been rejected by the controller: %s: %s", cert.Status.Reason, cert.Status.Message)
+				return nil, fmt.Errorf("Certificate issuing has been rejected by the controller: %s: %s", cert.Status.Reason, cert.Status.Message)
 
 			case certificatev1alpha2.CertificateUnknown:
-				return fmt.Errorf("The controller did not know how to handle our request and moved it to the '%s' phase (%s: %s)", certificatev1alpha2.CertificateUnknown, cert.Status.Reason, cert.Status.Message)
+				return nil, fmt.Errorf("The controller did not know how to handle our request and moved it to the '%s' phase (%s: %s)", certificatev1alpha2.CertificateUnknown, cert.Status.Reason, cert.Status.Message)
 
 			case certificatev1alpha2.CertificateSubmitted:
 				zap.L().Sugar().Debugf("Controller has accepted our request and moved it to the '%s' phase", certificatev1alpha2.CertificateSubmitted)
 				break
 
+			case certificatev1alpha2.CertificatePending:
+				zap.L().Sugar().Debugf("Controller has validated our request and moved it to the '%s' phase, waiting for an approval", certificatev1alpha2.CertificatePending)
+				break
+
 			case certificatev1alpha2.CertificateSigned:
 				if cert.Status.Certificate != nil {
-					fmt.Printf("Cert is available: %+v", cert.Status.Certificate)
-					m.certPEM = cert.Status.Certificate
-					m.cert, err = tglib.ReadCertificatePEMFromData(cert.Status.Certificate)
+					zap.L().Debug("Cert is available", zap.String("certName", m.certName), zap.String("name", name), zap.ByteString("cert", cert.Status.Certificate))
+					issued := &issuedCert{
+						certPEM:    cert.Status.Certificate,
+						caCertPEM:  cert.Status.Ca,
+						chainPEM:   cert.Status.Chain,
+						smartToken: cert.Status.Token,
+					}
+					issued.cert, err = tglib.ReadCertificatePEMFromData(cert.Status.Certificate)
 					if err != nil {
-						return fmt.Errorf("couldn't parse certificate: %s", err.Error())
+						return nil, fmt.Errorf("couldn't parse certificate: %s", err.Error())
 					}
 
-					m.caCertPEM = cert.Status.Ca
-					m.caCert, err = tglib.ReadCertificatePEMFromData(cert.Status.Certificate)
+					issued.caCert, err = tglib.ReadCertificatePEMFromData(cert.Status.Ca)
 					if err != nil {
-						return fmt.Errorf("couldn't parse CA certificate: %s", err.Error())
+						return nil, fmt.Errorf("couldn't parse CA certificate: %s", err.Error())
 					}
 
-					m.smartToken = cert.Status.Token
-					return nil
+					return issued, nil
 				}
 
 			default:
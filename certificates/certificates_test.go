@@ -0,0 +1,99 @@
+package certificates
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clienttesting "k8s.io/client-go/testing"
+
+	certificatev1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+	certificatefake "github.com/CodingJzy/trireme-csr/pkg/client/clientset/versioned/fake"
+)
+
+// newTestCertificate returns a Certificate with the given name, renewal label and phase
+func newTestCertificate(name, renewalOf string, phase certificatev1alpha2.CertificatePhase) *certificatev1alpha2.Certificate {
+	cert := &certificatev1alpha2.Certificate{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     certificatev1alpha2.CertificateStatus{Phase: phase},
+	}
+	if renewalOf != "" {
+		cert.Labels = map[string]string{certificatev1alpha2.RenewalOfLabel: renewalOf}
+	}
+	return cert
+}
+
+func TestDeleteRenewals(t *testing.T) {
+	certClient := certificatefake.NewSimpleClientset(
+		newTestCertificate("node", "", certificatev1alpha2.CertificateSigned),
+		newTestCertificate("node-1", "node", certificatev1alpha2.CertificateSigned),
+		newTestCertificate("node-2", "node", certificatev1alpha2.CertificateSubmitted),
+		newTestCertificate("node-3", "node", certificatev1alpha2.CertificateRevoked),
+		newTestCertificate("other-1", "other", certificatev1alpha2.CertificateSigned),
+	)
+	manager, err := NewCertManager("node", certClient)
+	if err != nil {
+		t.Fatalf("failed to create CertManager: %s", err)
+	}
+
+	if err := manager.deleteRenewals(); err != nil {
+		t.Fatalf("deleteRenewals() error = %s", err)
+	}
+
+	// only the renewals of our certificate are listed
+	for _, action := range certClient.Actions() {
+		list, ok := action.(clienttesting.ListAction)
+		if !ok {
+			continue
+		}
+		if got, want := list.GetListRestrictions().Labels.String(), certificatev1alpha2.RenewalOfLabel+"=node"; got != want {
+			t.Errorf("deleteRenewals() listed Certificates with selector '%s', want '%s'", got, want)
+		}
+	}
+
+	certs, err := certClient.CertmanagerV1alpha2().Certificates().List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list Certificates: %s", err)
+	}
+	var names []string
+	for _, cert := range certs.Items {
+		names = append(names, cert.Name)
+	}
+	sort.Strings(names)
+	if got, want := strings.Join(names, ","), "node,node-3,other-1"; got != want {
+		t.Errorf("Certificates after deleteRenewals() = %s, want %s", got, want)
+	}
+}
+
+func TestCreateRequestRenewalLabel(t *testing.T) {
+	certClient := certificatefake.NewSimpleClientset()
+	manager, err := NewCertManager("node", certClient)
+	if err != nil {
+		t.Fatalf("failed to create CertManager: %s", err)
+	}
+
+	if err := manager.createRequest("node-1", "node", []byte("csr")); err != nil {
+		t.Fatalf("createRequest() error = %s", err)
+	}
+	cert, err := certClient.CertmanagerV1alpha2().Certificates().Get("node-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get renewal: %s", err)
+	}
+	if got := cert.Labels[certificatev1alpha2.RenewalOfLabel]; got != "node" {
+		t.Errorf("renewal label = '%s', want 'node'", got)
+	}
+}
+
+func TestStartRenewalInvalidName(t *testing.T) {
+	manager, err := NewCertManager(strings.Repeat("n", 64), certificatefake.NewSimpleClientset())
+	if err != nil {
+		t.Fatalf("failed to create CertManager: %s", err)
+	}
+	if err := manager.StartRenewal(0.5, 0.1, 0, nil); err == nil {
+		t.Errorf("StartRenewal() accepted a certificate name that is not a valid label value")
+	}
+	if err := manager.deleteRenewals(); err != nil {
+		t.Errorf("deleteRenewals() error = %s for a certificate name that is not a valid label value", err)
+	}
+}
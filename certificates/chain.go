@@ -0,0 +1,195 @@
+package certificates
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// caChain is a CA together with the intermediates and the root CA that it chains up to
+type caChain struct {
+	cert *x509.Certificate
+	// chainPEM holds the intermediates from the CA up to the root, which are published with every issued certificate
+	chainPEM      []byte
+	root          *x509.Certificate
+	intermediates *x509.CertPool
+	roots         *x509.CertPool
+}
+
+// parseCAChain parses a CA bundle. The bundle starts with the CA, which can be followed by its intermediate CAs
+// and by the root CA, in this order. A self signed CA is its own root.
+func parseCAChain(bundlePEM []byte) (*caChain, error) {
+	certs, err := parseCertificatesPEM(bundlePEM)
+	if err != nil {
+		return nil, err
+	}
+
+	// the bundle gets published in order, so every certificate must be issued by the one that follows it
+	for i := 1; i < len(certs); i++ {
+		if err := certs[i-1].CheckSignatureFrom(certs[i]); err != nil {
+			return nil, fmt.Errorf("certificate %d of the CA bundle has not been issued by the next one, the bundle must start with the CA and end with its root CA: %s", i, err)
+		}
+	}
+
+	chain := &caChain{
+		cert:          certs[0],
+		intermediates: x509.NewCertPool(),
+		roots:         x509.NewCertPool(),
+	}
+	for _, cert := range certs {
+		if isSelfSigned(cert) {
+			if chain.root != nil {
+				return nil, fmt.Errorf("the CA bundle contains more than one root CA certificate")
+			}
+			chain.root = cert
+			continue
+		}
+		chain.intermediates.AddCert(cert)
+		chain.chainPEM = append(chain.chainPEM, encodeCertificatePEM(cert)...)
+	}
+
+	// without a root, the last certificate of the bundle is trusted like a root, which is how a
+	// single intermediate signing CA has always been handled
+	if chain.root == nil {
+		chain.root = certs[len(certs)-1]
+		zap.L().Warn("CA bundle does not contain a self signed root CA, trusting its last certificate as the root", zap.String("subject", chain.root.Subject.String()))
+		chain.chainPEM = bytes.TrimSuffix(chain.chainPEM, encodeCertificatePEM(chain.root))
+	}
+	chain.roots.AddCert(chain.root)
+
+	// the bundle must verify the way that clients verify it, at a time when it was valid,
+	// as the expiry of the signing CA is reported by the health checks instead
+	_, err = chain.cert.Verify(x509.VerifyOptions{
+		Intermediates: chain.intermediates,
+		Roots:         chain.roots,
+		CurrentTime:   chain.cert.NotBefore,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("the CA certificate does not chain up to the root CA of the bundle: %s", err)
+	}
+	return chain, nil
+}
+
+// issued returns true if the certificate has been signed by the CA
+func (c *caChain) issued(cert *x509.Certificate) bool {
+	return cert.CheckSignatureFrom(c.cert) == nil
+}
+
+// verify verifies the certificate up to the root CA
+func (c *caChain) verify(cert *x509.Certificate) error {
+	_, err := cert.Verify(x509.VerifyOptions{
+		Intermediates: c.intermediates,
+		Roots:         c.roots,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
+
+// AddTrustedCA adds the CA bundle of a retiring signing CA. The certificates it issued stay valid until they
+// expire, but are reported as retiring by ChainFor, and its root CA is published until it gets removed.
+// Its certificates can not be revoked, as the issuer holds no key of the trusted CA to sign a CRL for it, and
+// relying parties ignore revocations on the CRL of another CA.
+func (i *TriremeIssuer) AddTrustedCA(bundlePEM []byte) error {
+	chain, err := parseCAChain(bundlePEM)
+	if err != nil {
+		return err
+	}
+	if chain.cert.Equal(i.currentCA().cert) {
+		return fmt.Errorf("the trusted CA '%s' is the signing CA", chain.cert.Subject)
+	}
+	i.trustedCAs = append(i.trustedCAs, chain)
+	return nil
+}
+
+// GetChain returns the PEM encoded intermediate CA certificates that issued certificates chain up through,
+// starting with the signing CA. It is empty if the signing CA is the root CA.
+func (i *TriremeIssuer) GetChain() []byte {
+	return i.currentCA().chain.chainPEM
+}
+
+// ChainFor returns the PEM encoded intermediate CA certificates of the CA that issued the certificate, which
+// is the signing CA, a signing CA replaced by a reload or a trusted CA. `retiring` is true if it has not been
+// issued by the signing CA, and should be re-issued by it. An error is returned if none of them issued it.
+func (i *TriremeIssuer) ChainFor(cert *x509.Certificate) (chainPEM []byte, retiring bool, err error) {
+	if chain := i.currentCA().chain; chain.issued(cert) {
+		return chain.chainPEM, false, nil
+	}
+	for _, retired := range i.retired() {
+		if retired.chain.issued(cert) {
+			return retired.chain.chainPEM, true, nil
+		}
+	}
+	for _, trusted := range i.trustedCAs {
+		if trusted.issued(cert) {
+			return trusted.chainPEM, true, nil
+		}
+	}
+	return nil, false, fmt.Errorf("certificate has not been issued by the signing CA or a trusted CA")
+}
+
+// trustedIssuerOf returns the trusted CA that issued the certificate, or nil if it has not been issued by a trusted
+// CA, or by a signing CA of the issuer, whose CRLs list it
+func (i *TriremeIssuer) trustedIssuerOf(cert *x509.Certificate) *caChain {
+	if i.currentCA().chain.issued(cert) {
+		return nil
+	}
+	for _, retired := range i.retired() {
+		if retired.chain.issued(cert) {
+			return nil
+		}
+	}
+	for _, trusted := range i.trustedCAs {
+		if trusted.issued(cert) {
+			return trusted
+		}
+	}
+	return nil
+}
+
+// GetRootCACert returns the PEM encoded root CA certificates that issued certificates chain up to. The root CA
+// of the signing CA comes first, followed by the ones of the replaced and the trusted CAs, so that all of them
+// are trusted during a rotation.
+func (i *TriremeIssuer) GetRootCACert() []byte {
+	chains := []*caChain{i.currentCA().chain}
+	for _, retired := range i.retired() {
+		chains = append(chains, retired.chain)
+	}
+	return rootsPEM(append(chains, i.trustedCAs...))
+}
+
+// rootsPEM returns the PEM encoded root CA certificates of the chains, in order and without duplicates
+func rootsPEM(chains []*caChain) []byte {
+	var roots []*x509.Certificate
+	for _, chain := range chains {
+		known := false
+		for _, root := range roots {
+			if root.Equal(chain.root) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			roots = append(roots, chain.root)
+		}
+	}
+
+	var bundle []byte
+	for _, root := range roots {
+		bundle = append(bundle, encodeCertificatePEM(root)...)
+	}
+	return bundle
+}
+
+// isSelfSigned returns true if the certificate is signed by its own key
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// encodeCertificatePEM returns the PEM encoding of the certificate
+func encodeCertificatePEM(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
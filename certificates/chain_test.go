@@ -0,0 +1,231 @@
+package certificates
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"testing"
+)
+
+// testChain is a CA signed by an intermediate CA, which is signed by a root CA
+type testChain struct {
+	rootPEM, interPEM, caPEM []byte
+	root, inter, ca          *x509.Certificate
+	rootKey, interKey, caKey crypto.Signer
+}
+
+// newTestChain creates a CA that chains up to a new root CA through an intermediate CA
+func newTestChain(t *testing.T, name string) *testChain {
+	t.Helper()
+
+	chain := &testChain{
+		rootKey:  newTestKey(t, "ecdsa"),
+		interKey: newTestKey(t, "ecdsa"),
+		caKey:    newTestKey(t, "ecdsa"),
+	}
+	chain.rootPEM, chain.root = newTestCA(t, chain.rootKey, name+"-root")
+	chain.interPEM, chain.inter = newTestSubCA(t, chain.interKey, name+"-intermediate", chain.root, chain.rootKey)
+	chain.caPEM, chain.ca = newTestSubCA(t, chain.caKey, name+"-ca", chain.inter, chain.interKey)
+	return chain
+}
+
+// joinPEM concatenates PEM certificates into a bundle
+func joinPEM(certs ...[]byte) []byte {
+	return bytes.Join(certs, nil)
+}
+
+func TestParseCAChain(t *testing.T) {
+	chain := newTestChain(t, "test")
+	other := newTestChain(t, "other")
+
+	tests := []struct {
+		name      string
+		bundlePEM []byte
+		wantErr   bool
+		wantCA    *x509.Certificate
+		wantRoot  *x509.Certificate
+		wantChain []byte
+	}{
+		{
+			name:      "single self signed CA",
+			bundlePEM: chain.rootPEM,
+			wantCA:    chain.root,
+			wantRoot:  chain.root,
+		},
+		{
+			name:      "CA, intermediate and root",
+			bundlePEM: joinPEM(chain.caPEM, chain.interPEM, chain.rootPEM),
+			wantCA:    chain.ca,
+			wantRoot:  chain.root,
+			wantChain: joinPEM(chain.caPEM, chain.interPEM),
+		},
+		{
+			name:      "missing root trusts the last certificate",
+			bundlePEM: joinPEM(chain.caPEM, chain.interPEM),
+			wantCA:    chain.ca,
+			wantRoot:  chain.inter,
+			wantChain: chain.caPEM,
+		},
+		{
+			name:      "single intermediate CA",
+			bundlePEM: chain.caPEM,
+			wantCA:    chain.ca,
+			wantRoot:  chain.ca,
+		},
+		{name: "root first", bundlePEM: joinPEM(chain.rootPEM, chain.interPEM, chain.caPEM), wantErr: true},
+		{name: "CA after its intermediate", bundlePEM: joinPEM(chain.interPEM, chain.caPEM, chain.rootPEM), wantErr: true},
+		{name: "missing intermediate", bundlePEM: joinPEM(chain.caPEM, chain.rootPEM), wantErr: true},
+		{name: "intermediate of another chain", bundlePEM: joinPEM(chain.caPEM, other.interPEM, other.rootPEM), wantErr: true},
+		{name: "root of another chain", bundlePEM: joinPEM(chain.caPEM, chain.interPEM, other.rootPEM), wantErr: true},
+		{name: "two roots", bundlePEM: joinPEM(chain.rootPEM, chain.rootPEM), wantErr: true},
+		{name: "no certificate", bundlePEM: []byte("no PEM"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCAChain(tt.bundlePEM)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseCAChain() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCAChain() error = %s", err)
+			}
+			if !got.cert.Equal(tt.wantCA) {
+				t.Errorf("CA = '%s', want '%s'", got.cert.Subject, tt.wantCA.Subject)
+			}
+			if !got.root.Equal(tt.wantRoot) {
+				t.Errorf("root = '%s', want '%s'", got.root.Subject, tt.wantRoot.Subject)
+			}
+			if !bytes.Equal(got.chainPEM, tt.wantChain) {
+				t.Errorf("chain = %q, want %q", got.chainPEM, tt.wantChain)
+			}
+			if err := got.verify(got.cert); err != nil {
+				t.Errorf("verify() error = %s for the CA of the bundle", err)
+			}
+		})
+	}
+}
+
+func TestGetRootCACert(t *testing.T) {
+	chain := newTestChain(t, "test")
+	issuer, err := NewTriremeIssuer(joinPEM(chain.caPEM, chain.interPEM, chain.rootPEM), chain.ca, chain.caKey)
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+	if got := issuer.GetRootCACert(); !bytes.Equal(got, chain.rootPEM) {
+		t.Errorf("GetRootCACert() = %q, want the root CA", got)
+	}
+	if got := issuer.GetChain(); !bytes.Equal(got, joinPEM(chain.caPEM, chain.interPEM)) {
+		t.Errorf("GetChain() = %q, want the CA and its intermediate", got)
+	}
+
+	if err := issuer.AddTrustedCA(joinPEM(chain.caPEM, chain.interPEM)); err == nil {
+		t.Errorf("AddTrustedCA() succeeded with the signing CA")
+	}
+
+	// a trusted CA under the same root does not publish it twice
+	siblingPEM, _ := newTestSubCA(t, newTestKey(t, "ecdsa"), "sibling-ca", chain.inter, chain.interKey)
+	if err := issuer.AddTrustedCA(joinPEM(siblingPEM, chain.interPEM, chain.rootPEM)); err != nil {
+		t.Fatalf("AddTrustedCA() error = %s", err)
+	}
+	if got := issuer.GetRootCACert(); !bytes.Equal(got, chain.rootPEM) {
+		t.Errorf("GetRootCACert() = %q with a trusted CA under the same root, want the root CA once", got)
+	}
+
+	otherPEM, _ := newTestCA(t, newTestKey(t, "ecdsa"), "other-root")
+	if err := issuer.AddTrustedCA(otherPEM); err != nil {
+		t.Fatalf("AddTrustedCA() error = %s", err)
+	}
+	if got := issuer.GetRootCACert(); !bytes.Equal(got, joinPEM(chain.rootPEM, otherPEM)) {
+		t.Errorf("GetRootCACert() = %q, want the root CA followed by the trusted root CA", got)
+	}
+
+	// the root of a new signing CA comes first, followed by the replaced and the trusted ones
+	newCertPEM, newKeyPEM := newTestCAData(t, "ecdsa", "new-ca")
+	if err := issuer.Reload(newCertPEM, newKeyPEM, ""); err != nil {
+		t.Fatalf("Reload() error = %s", err)
+	}
+	if got := issuer.GetRootCACert(); !bytes.Equal(got, joinPEM(newCertPEM, chain.rootPEM, otherPEM)) {
+		t.Errorf("GetRootCACert() = %q after a reload, want the new, the replaced and the trusted root CAs", got)
+	}
+	if got := issuer.GetChain(); len(got) != 0 {
+		t.Errorf("GetChain() = %q for a self signed CA, want no intermediates", got)
+	}
+}
+
+func TestTrustedCARotation(t *testing.T) {
+	oldKey := newTestKey(t, "ecdsa")
+	oldPEM, oldCA := newTestCA(t, oldKey, "old-ca")
+	oldIssuer, err := NewTriremeIssuer(oldPEM, oldCA, oldKey)
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+	oldCert := signTestCert(t, oldIssuer, newTestKey(t, "ecdsa"), "old")
+
+	// a trusted CA with an intermediate publishes its chain with its certificates
+	trusted := newTestChain(t, "trusted")
+	trustedBundle := joinPEM(trusted.caPEM, trusted.interPEM, trusted.rootPEM)
+	trustedIssuer, err := NewTriremeIssuer(trustedBundle, trusted.ca, trusted.caKey)
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+	trustedCert := signTestCert(t, trustedIssuer, newTestKey(t, "ecdsa"), "trusted")
+
+	chain := newTestChain(t, "new")
+	issuer, err := NewTriremeIssuer(joinPEM(chain.caPEM, chain.interPEM, chain.rootPEM), chain.ca, chain.caKey)
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+	for _, bundlePEM := range [][]byte{oldPEM, trustedBundle} {
+		if err := issuer.AddTrustedCA(bundlePEM); err != nil {
+			t.Fatalf("AddTrustedCA() error = %s", err)
+		}
+	}
+	if err := issuer.AddTrustedCA(joinPEM(trusted.caPEM, oldPEM)); err == nil {
+		t.Errorf("AddTrustedCA() succeeded with a broken CA bundle")
+	}
+	newCert := signTestCert(t, issuer, newTestKey(t, "ecdsa"), "new")
+
+	tests := []struct {
+		name         string
+		cert         *x509.Certificate
+		wantChain    []byte
+		wantRetiring bool
+	}{
+		{name: "signing CA", cert: newCert, wantChain: joinPEM(chain.caPEM, chain.interPEM)},
+		{name: "self signed trusted CA", cert: oldCert, wantRetiring: true},
+		{name: "trusted CA with intermediate", cert: trustedCert, wantChain: joinPEM(trusted.caPEM, trusted.interPEM), wantRetiring: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chainPEM, retiring, err := issuer.ChainFor(tt.cert)
+			if err != nil {
+				t.Fatalf("ChainFor() error = %s", err)
+			}
+			if retiring != tt.wantRetiring {
+				t.Errorf("ChainFor() retiring = %v, want %v", retiring, tt.wantRetiring)
+			}
+			if !bytes.Equal(chainPEM, tt.wantChain) {
+				t.Errorf("ChainFor() chain = %q, want %q", chainPEM, tt.wantChain)
+			}
+			if err := issuer.ValidateCert(tt.cert, nil); err != nil {
+				t.Errorf("ValidateCert() error = %s", err)
+			}
+		})
+	}
+
+	foreign := signTestCert(t, newTestIssuer(t), newTestKey(t, "ecdsa"), "foreign")
+	if _, _, err := issuer.ChainFor(foreign); err == nil {
+		t.Errorf("ChainFor() succeeded for a certificate of another CA")
+	}
+	if err := issuer.ValidateCert(foreign, nil); err == nil {
+		t.Errorf("ValidateCert() succeeded for a certificate of another CA")
+	}
+
+	// the roots of the trusted CAs are published until their certificates have been re-issued
+	if got := issuer.GetRootCACert(); !bytes.Equal(got, joinPEM(chain.rootPEM, oldPEM, trusted.rootPEM)) {
+		t.Errorf("GetRootCACert() = %q, want the root CA followed by the trusted root CAs", got)
+	}
+}
@@ -0,0 +1,119 @@
+package certificates
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// newTestKey generates a private key of the given type: "ecdsa", "rsa" or "ed25519"
+func newTestKey(t *testing.T, keyType string) crypto.Signer {
+	t.Helper()
+
+	var key crypto.Signer
+	var err error
+	switch keyType {
+	case "ecdsa":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "rsa":
+		key, err = rsa.GenerateKey(rand.Reader, DefaultMinRSAKeySize)
+	case "ed25519":
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		t.Fatalf("unknown key type '%s'", keyType)
+	}
+	if err != nil {
+		t.Fatalf("failed to generate %s key: %s", keyType, err)
+	}
+	return key
+}
+
+// newTestCA creates a self-signed CA certificate for the key, and returns it PEM encoded
+func newTestCA(t *testing.T, key crypto.Signer, commonName string) ([]byte, *x509.Certificate) {
+	t.Helper()
+
+	return newTestSubCA(t, key, commonName, nil, nil)
+}
+
+// newTestSubCA creates a CA certificate for the key that is signed by the parent CA, or self-signed if the parent
+// is nil, and returns it PEM encoded
+func newTestSubCA(t *testing.T, key crypto.Signer, commonName string, parent *x509.Certificate, parentKey crypto.Signer) ([]byte, *x509.Certificate) {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse CA certificate: %s", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), cert
+}
+
+// newTestIssuer creates a TriremeIssuer with a new self-signed ECDSA CA
+func newTestIssuer(t *testing.T) *TriremeIssuer {
+	t.Helper()
+
+	key := newTestKey(t, "ecdsa")
+	caPEM, caCert := newTestCA(t, key, "test-ca")
+	issuer, err := NewTriremeIssuer(caPEM, caCert, key)
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+	return issuer
+}
+
+// newTestCSR creates a CSR signed by the key
+func newTestCSR(t *testing.T, key crypto.Signer, commonName string) *x509.CertificateRequest {
+	t.Helper()
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: commonName},
+		DNSNames: []string{commonName + ".example.com"},
+	}, key)
+	if err != nil {
+		t.Fatalf("failed to create CSR: %s", err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatalf("failed to parse CSR: %s", err)
+	}
+	return csr
+}
+
+// signTestCert signs a certificate for the key with the issuer
+func signTestCert(t *testing.T, issuer Issuer, key crypto.Signer, commonName string) *x509.Certificate {
+	t.Helper()
+
+	certPEM, err := issuer.Sign(newTestCSR(t, key, commonName), &SignOptions{})
+	if err != nil {
+		t.Fatalf("failed to sign certificate: %s", err)
+	}
+	cert, err := parseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %s", err)
+	}
+	return cert
+}
@@ -4,128 +4,382 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"math/big"
+	"sync"
 	"time"
 
-	"go.aporeto.io/tg/tglib"
+	"github.com/CodingJzy/trireme-csr/policy"
 	"go.aporeto.io/trireme-lib/controller/pkg/pkiverifier"
 )
 
-// Issuer is able to validate and sign certificates based on a CSR.
+// Issuer is able to validate and sign certificates based on a CSR. Errors of signings and revocations that
+// can succeed when retried, e.g. as a remote signing service is unreachable, are temporary, see IsTemporary.
 type Issuer interface {
-	ValidateRequest(csr *x509.CertificateRequest) error
+	ValidateRequest(csr *x509.CertificateRequest, options *SignOptions) error
 	ValidateCert(cert, ca *x509.Certificate) error
-	Sign(csr *x509.CertificateRequest) ([]byte, error)
-	IssueToken(cert *x509.Certificate) ([]byte, error)
+	Sign(csr *x509.CertificateRequest, options *SignOptions) ([]byte, error)
+	IssueToken(cert *x509.Certificate, attributes *TokenAttributes) ([]byte, time.Time, error)
 	GetCACert() []byte
+	GetChain() []byte
+	ChainFor(cert *x509.Certificate) ([]byte, bool, error)
+	GetRootCACert() []byte
+	Healthy() error
+	Revoke(cert *x509.Certificate, revokedAt time.Time, reason int) error
+	GetCRL() ([]byte, error)
+}
+
+// temporaryError is an error that can go away when the operation is retried
+type temporaryError struct {
+	err error
+}
+
+func (e *temporaryError) Error() string {
+	return e.err.Error()
+}
+
+// Temporary returns true, so that IsTemporary recognizes the error
+func (e *temporaryError) Temporary() bool {
+	return true
+}
+
+// IsTemporary returns true if the error is temporary, e.g. a transport error or a server error of a remote signing
+// service, so that the operation should be retried instead of failing for good.
+func IsTemporary(err error) bool {
+	temporary, ok := err.(interface{ Temporary() bool })
+	return ok && temporary.Temporary()
+}
+
+// wrapTemporary returns `err`, which is temporary if its cause is
+func wrapTemporary(cause, err error) error {
+	if IsTemporary(cause) {
+		return &temporaryError{err: err}
+	}
+	return err
 }
 
 // TriremeIssuer takes CSRs and issues valid certificates based on a valid CA
 type TriremeIssuer struct {
-	signingCert    *x509.Certificate
-	signingCertPEM []byte
-	signingKey     crypto.PrivateKey
-	caCertPool     *x509.CertPool
-	tokenIssuer    pkiverifier.PKITokenIssuer
+	// ca is replaced as a whole when the signing CA gets reloaded
+	caLock sync.RWMutex
+	ca     *signingCA
+	caHash []byte
+	// signatureAlgorithmName overrides the default signature algorithm of the signing CA key
+	signatureAlgorithmName string
+	// tokenIssuer signs tokens with a separate key instead of the signing CA key if set
+	tokenIssuer pkiverifier.PKITokenIssuer
+	// tokenOptions configure the tags and the validity of the tokens
+	tokenOptions *TokenOptions
+
+	// trustedCAs are retiring signing CAs, whose certificates stay valid until they expire
+	trustedCAs []*caChain
+	// retiredCAs are the signing CAs that have been replaced by a reload, until they expire. Their certificates
+	// stay valid, and they keep signing the CRLs and OCSP responses of them.
+	retiredCAs []*signingCA
+
+	revocationLock sync.RWMutex
+	revoked        map[string]Revocation
+	crl            []byte
+	retiredCRLs    map[*signingCA][]byte
+	crlNumber      *big.Int
+	crlValidity    time.Duration
+
+	ocspServers    []string
+	ocspSignerCert *x509.Certificate
+	ocspSignerKey  crypto.Signer
+
+	minRSAKeySize   int
+	defaultDuration time.Duration
+	maxDuration     time.Duration
+
+	policy *policy.Engine
 }
 
-// NewTriremeIssuer creates an issuer based on crypto CA objects
+// DefaultMinRSAKeySize is the default and lowest allowed minimum size in bits of RSA keys in CSRs.
+const DefaultMinRSAKeySize = 2048
+
+// NewTriremeIssuer creates an issuer based on crypto CA objects. `signingCertPEM` is the CA bundle,
+// which starts with the signing CA, and can be followed by its intermediates and by the root CA.
+// Encrypted keys must be loaded with NewTriremeIssuerFromPath or NewTriremeIssuerFromData instead.
 // TODO: Remove the double reference to the SigningCert.
-func NewTriremeIssuer(signingCertPEM []byte, signingCert *x509.Certificate, signingKey crypto.PrivateKey, signingKeyPass string) (*TriremeIssuer, error) {
-	// TODO: Better validation of parameters here.
-	pkiIssuer := pkiverifier.NewPKIIssuer(signingKey.(*ecdsa.PrivateKey))
-	caCertPool := x509.NewCertPool()
-	caCertPool.AddCert(signingCert)
+func NewTriremeIssuer(signingCertPEM []byte, signingCert *x509.Certificate, signingKey crypto.PrivateKey) (*TriremeIssuer, error) {
+	signer, ok := signingKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("signing key can not be used to sign: %T", signingKey)
+	}
+	issuer, err := NewTriremeIssuerWithSigner(signingCertPEM, signer)
+	if err != nil {
+		return nil, err
+	}
+	if !issuer.ca.cert.Equal(signingCert) {
+		return nil, fmt.Errorf("the CA bundle must start with the signing CA certificate")
+	}
+	return issuer, nil
+}
+
+// NewTriremeIssuerWithSigner creates an issuer for the CA bundle `signingCertPEM` that signs through `signer`,
+// e.g. the client of a remote signing service, so that the CA key never has to be loaded in memory.
+func NewTriremeIssuerWithSigner(signingCertPEM []byte, signer crypto.Signer) (*TriremeIssuer, error) {
+	ca, err := newSigningCA(signingCertPEM, signer)
+	if err != nil {
+		return nil, err
+	}
 
 	return &TriremeIssuer{
-		signingCert:    signingCert,
-		signingCertPEM: signingCertPEM,
-		signingKey:     signingKey,
-		caCertPool:     caCertPool,
-		tokenIssuer:    pkiIssuer,
+		ca:              ca,
+		tokenOptions:    &TokenOptions{},
+		revoked:         map[string]Revocation{},
+		crlValidity:     2 * DefaultCRLUpdateInterval,
+		minRSAKeySize:   DefaultMinRSAKeySize,
+		defaultDuration: DefaultCertificateDuration,
+		maxDuration:     DefaultCertificateDuration,
 	}, nil
 }
 
 // NewTriremeIssuerFromPath creates an issuer based on the path of PEM encoded crypto primitives
 func NewTriremeIssuerFromPath(signingCertPath, signingCertKeyPath, signingKeyPass string) (*TriremeIssuer, error) {
-	signingCert, signingKey, err := tglib.ReadCertificatePEM(signingCertPath, signingCertKeyPath, signingKeyPass)
+	caCertPEM, caKeyPEM, err := readCAFiles(signingCertPath, signingCertKeyPath)
 	if err != nil {
 		return nil, err
 	}
 
-	caCertPEM, err := LoadCertPEM(signingCertPath)
+	return NewTriremeIssuerFromData(caCertPEM, caKeyPEM, signingKeyPass)
+}
+
+// NewTriremeIssuerFromData creates an issuer from the PEM encoded CA certificate and key, which is decrypted
+// with `keyPass` if encrypted
+func NewTriremeIssuerFromData(caCertPEM, caKeyPEM []byte, keyPass string) (*TriremeIssuer, error) {
+	signingCert, err := parseCertificatePEM(caCertPEM)
+	if err != nil {
+		return nil, err
+	}
+	signingKey, err := ParsePrivateKeyPEM(caKeyPEM, keyPass)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer, err := NewTriremeIssuer(caCertPEM, signingCert, signingKey)
+	if err != nil {
+		return nil, err
+	}
+	// reloading the same data has no effect
+	issuer.caHash = hashCA(caCertPEM, caKeyPEM)
+	return issuer, nil
+}
+
+// ValidateRequest verifies that the CSR is valid and is allowed to be issued with the requested options.
+// Return an error if not allowed.
+func (i *TriremeIssuer) ValidateRequest(csr *x509.CertificateRequest, options *SignOptions) error {
+	if err := csr.CheckSignature(); err != nil {
+		return err
+	}
+
+	if err := validatePublicKey(csr.PublicKey, i.minRSAKeySize); err != nil {
+		return err
+	}
+
+	if options.Duration < 0 {
+		return fmt.Errorf("invalid duration %s", options.Duration)
+	}
+	usages := options.Usages
+	if len(usages) == 0 {
+		usages = defaultUsages(csr, options.IsCA)
+	}
+	if _, _, err := parseUsages(usages); err != nil {
+		return err
+	}
+
+	// CA certificates and the restricted usages must always be allowed explicitly by a policy
+	if i.policy == nil {
+		if options.IsCA {
+			return fmt.Errorf("CA certificates are not allowed without an issuance policy")
+		}
+		return validateUsagesWithoutPolicy(usages)
+	}
+	return i.policy.Validate(csr, usageNames(usages), options.IsCA, options.Duration)
+}
+
+// SetDurations sets the default and the maximum validity of issued certificates.
+func (i *TriremeIssuer) SetDurations(defaultDuration, maxDuration time.Duration) error {
+	if defaultDuration <= 0 || maxDuration <= 0 {
+		return fmt.Errorf("certificate durations must be positive")
+	}
+	if defaultDuration > maxDuration {
+		return fmt.Errorf("default certificate duration %s is greater than the maximum of %s", defaultDuration, maxDuration)
+	}
+	i.defaultDuration = defaultDuration
+	i.maxDuration = maxDuration
+	return nil
+}
+
+// validatePublicKey verifies that the issuers support the key type of the CSR, and that RSA keys are large enough.
+func validatePublicKey(publicKey crypto.PublicKey, minRSAKeySize int) error {
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		return nil
+	case ed25519.PublicKey:
+		return nil
+	case *rsa.PublicKey:
+		if size := key.N.BitLen(); size < minRSAKeySize {
+			return fmt.Errorf("RSA key size %d is smaller than the minimum of %d", size, minRSAKeySize)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported key type %T (only ECDSA, Ed25519 and RSA keys are supported)", publicKey)
+	}
+}
+
+// SetMinRSAKeySize sets the minimum size in bits of RSA keys in CSRs.
+func (i *TriremeIssuer) SetMinRSAKeySize(bits int) error {
+	if bits < DefaultMinRSAKeySize {
+		return fmt.Errorf("minimum RSA key size must be at least %d", DefaultMinRSAKeySize)
+	}
+	i.minRSAKeySize = bits
+	return nil
+}
+
+// SetSignatureAlgorithm overrides the algorithm used to sign certificates, e.g. `SHA384-RSAPSS` for an RSA CA.
+// It must match the type of the signing CA key.
+func (i *TriremeIssuer) SetSignatureAlgorithm(name string) error {
+	i.caLock.Lock()
+	defer i.caLock.Unlock()
+
+	algorithm, err := parseSignatureAlgorithm(name, i.ca.signer.Public())
 	if err != nil {
-		return nil, fmt.Errorf("Error loading CaCertFile %s", err)
+		return err
+	}
+	ca := *i.ca
+	ca.signatureAlgorithm = algorithm
+	i.ca = &ca
+	i.signatureAlgorithmName = name
+	return nil
+}
+
+// SetTokenSigningKey sets a separate ECDSA key to sign tokens with, instead of the signing CA key.
+// Token verifiers must then trust the public key of this key.
+func (i *TriremeIssuer) SetTokenSigningKey(key crypto.PrivateKey) error {
+	ecdsaKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return fmt.Errorf("token signing key must be an ECDSA key: %T", key)
 	}
+	i.tokenIssuer = pkiverifier.NewPKIIssuer(ecdsaKey)
+	return nil
+}
+
+// SetTokenOptions sets the tags and the validity of the tokens.
+func (i *TriremeIssuer) SetTokenOptions(options *TokenOptions) error {
+	if err := options.validate(); err != nil {
+		return err
+	}
+	i.tokenOptions = options
+	return nil
+}
+
+// ValidateTokenIssuer returns an error if tokens can not be issued, because the signing CA key is not an
+// ECDSA key and no separate token signing key has been set.
+func (i *TriremeIssuer) ValidateTokenIssuer() error {
+	return i.validateTokenIssuer(i.currentCA())
+}
 
-	return NewTriremeIssuer(caCertPEM, signingCert, signingKey, signingKeyPass)
+// validateTokenIssuer returns an error if neither the signing CA nor a separate token signing key can sign tokens
+func (i *TriremeIssuer) validateTokenIssuer(ca *signingCA) error {
+	if i.tokenIssuer == nil && ca.tokenIssuer == nil {
+		return fmt.Errorf("the signing CA key is a %T, which can not sign tokens: a separate ECDSA token signing key is required", ca.signer)
+	}
+	return nil
 }
 
-// ValidateRequest verifies that the CSR is valid and is allowed to be issued. Return an error if not allowed.
-func (i *TriremeIssuer) ValidateRequest(csr *x509.CertificateRequest) error {
-	// we check the signature for validation only at the moment
-	// TODO: there should be more criteria than just a valid signature
-	return csr.CheckSignature()
+// SetPolicy sets the issuance policy engine that every CSR gets validated against.
+func (i *TriremeIssuer) SetPolicy(engine *policy.Engine) {
+	i.policy = engine
 }
 
-// ValidateCert validates if the certificate has been signed by the TriremeIssuer and if we can verify
-// the certificate chain with it. If `ca` is provided, the CA certificate is used instead of the TriremeIssuer
-// CA. Returns an error if it cannot be validated.
+// ValidateCert validates if the certificate has been signed by the TriremeIssuer or one of its trusted CAs,
+// and if we can verify the certificate chain with it, up to the root CA. If `ca` is provided, the CA certificate
+// is used instead of the TriremeIssuer CAs. Returns an error if it cannot be validated.
 func (i *TriremeIssuer) ValidateCert(cert, ca *x509.Certificate) error {
-	var err error
 	if ca != nil {
-		err = cert.CheckSignatureFrom(ca)
-		if err != nil {
-			return nil
+		return verifyWithCA(cert, ca)
+	}
+
+	chain := i.currentCA().chain
+	err := cert.CheckSignatureFrom(chain.cert)
+	if err == nil {
+		return chain.verify(cert)
+	}
+	for _, retired := range i.retired() {
+		if retired.chain.issued(cert) {
+			return retired.chain.verify(cert)
 		}
-		caCertPool := x509.NewCertPool()
-		caCertPool.AddCert(ca)
-		_, err = cert.Verify(x509.VerifyOptions{
-			Roots: caCertPool,
-		})
-		return err
 	}
+	for _, trusted := range i.trustedCAs {
+		if trusted.issued(cert) {
+			return trusted.verify(cert)
+		}
+	}
+	return err
+}
 
-	err = cert.CheckSignatureFrom(i.signingCert)
-	if err != nil {
+// verifyWithCA verifies that the certificate has been signed by the CA, and chains up to it
+func verifyWithCA(cert, ca *x509.Certificate) error {
+	if err := cert.CheckSignatureFrom(ca); err != nil {
 		return err
 	}
-	_, err = cert.Verify(x509.VerifyOptions{
-		Roots: i.caCertPool,
+	caCertPool := x509.NewCertPool()
+	caCertPool.AddCert(ca)
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:     caCertPool,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
 	})
 	return err
 }
 
-// Sign generate a signed and valid certificate for the CSR given as parameter
-func (i *TriremeIssuer) Sign(csr *x509.CertificateRequest) ([]byte, error) {
+// Sign generate a signed and valid certificate for the CSR given as parameter, with the requested options.
+// The validity is clamped to the maximum duration of the issuer and to the validity of the CA.
+func (i *TriremeIssuer) Sign(csr *x509.CertificateRequest, options *SignOptions) ([]byte, error) {
+	usages := options.Usages
+	if len(usages) == 0 {
+		usages = defaultUsages(csr, options.IsCA)
+	}
+	keyUsage, extKeyUsage, err := parseUsages(usages)
+	if err != nil {
+		return nil, err
+	}
 
-	var keyUsage x509.KeyUsage
-	var extKeyUsage []x509.ExtKeyUsage
+	// the CA can be reloaded at any time, so the whole signing uses the same one
+	ca := i.currentCA()
 
-	// TODO: Revisit the existing KeyUsage.
-	keyUsage = x509.KeyUsageDigitalSignature
-	keyUsage |= x509.KeyUsageKeyEncipherment
+	duration := i.defaultDuration
+	if options.Duration > 0 {
+		duration = options.Duration
+	}
+	if duration > i.maxDuration {
+		duration = i.maxDuration
+	}
 
-	extKeyUsage = append(extKeyUsage, x509.ExtKeyUsageClientAuth)
-	extKeyUsage = append(extKeyUsage, x509.ExtKeyUsageServerAuth)
+	notBefore := time.Now()
+	notAfter := notBefore.Add(duration)
+	if notAfter.After(ca.cert.NotAfter) {
+		notAfter = ca.cert.NotAfter
+	}
 
-	pemCert, _, err := tglib.SignCSR(csr,
-		i.signingCert,
-		i.signingKey,
-		time.Now(),
-		time.Now().AddDate(1, 0, 0),
+	pemCert, err := i.signCSR(ca,
+		csr,
+		notBefore,
+		notAfter,
 		keyUsage,
 		extKeyUsage,
-		x509.ECDSAWithSHA384,
-		x509.ECDSA,
-		false,
-		nil,
+		options.IsCA,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to generate Cert: %s", err)
+		// a remote signer can fail temporarily
+		return nil, wrapTemporary(err, fmt.Errorf("Failed to generate Cert: %s", err))
 	}
 
 	clientCertificate := pem.EncodeToMemory(pemCert)
@@ -135,14 +389,72 @@ func (i *TriremeIssuer) Sign(csr *x509.CertificateRequest) ([]byte, error) {
 	return certificatePem, nil
 }
 
-// IssueToken generates a valid token for the cert given as parameter
-func (i *TriremeIssuer) IssueToken(cert *x509.Certificate) ([]byte, error) {
-	return i.tokenIssuer.CreateTokenFromCertificate(cert, []string{})
+// signCSR creates a certificate for the CSR, signed by the signing CA, and returns it PEM encoded
+func (i *TriremeIssuer) signCSR(ca *signingCA, csr *x509.CertificateRequest, notBefore, notAfter time.Time, keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage, isCA bool) (*pem.Block, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate serial number: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               csr.Subject,
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              keyUsage,
+		ExtKeyUsage:           extKeyUsage,
+		SignatureAlgorithm:    ca.signatureAlgorithm,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		MaxPathLenZero:        isCA, // issued CAs can only issue leaf certificates
+		DNSNames:              csr.DNSNames,
+		EmailAddresses:        csr.EmailAddresses,
+		IPAddresses:           csr.IPAddresses,
+		URIs:                  csr.URIs,
+		OCSPServer:            i.ocspServers,
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, ca.cert, csr.PublicKey, ca.signer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pem.Block{Type: "CERTIFICATE", Bytes: certDER}, nil
+}
+
+// IssueToken generates a valid token for the cert given as parameter, with the tags of the token options
+// taken from the certificate and the attributes of its request. It returns the token and its expiry.
+func (i *TriremeIssuer) IssueToken(cert *x509.Certificate, attributes *TokenAttributes) ([]byte, time.Time, error) {
+	tokenIssuer := i.tokenIssuer
+	if tokenIssuer == nil {
+		tokenIssuer = i.currentCA().tokenIssuer
+	}
+	if tokenIssuer == nil {
+		return nil, time.Time{}, fmt.Errorf("no token signing key configured")
+	}
+	return i.tokenOptions.issue(tokenIssuer, cert, attributes)
+}
+
+// Healthy returns an error if the issuer is not able to sign valid certificates anymore.
+func (i *TriremeIssuer) Healthy() error {
+	return checkCAValidity(i.currentCA().cert)
+}
+
+// checkCAValidity returns an error if the signing CA certificate is not valid yet or has expired
+func checkCAValidity(signingCert *x509.Certificate) error {
+	now := time.Now()
+	if now.Before(signingCert.NotBefore) {
+		return fmt.Errorf("signing CA certificate is not valid before %s", signingCert.NotBefore.Format(time.RFC3339))
+	}
+	if now.After(signingCert.NotAfter) {
+		return fmt.Errorf("signing CA certificate has expired on %s", signingCert.NotAfter.Format(time.RFC3339))
+	}
+	return nil
 }
 
-// GetCACert returns the CA Certificate that is used for this issuer.
+// GetCACert returns the PEM encoded signing CA certificate of this issuer.
 func (i *TriremeIssuer) GetCACert() []byte {
-	return i.signingCertPEM
+	return i.currentCA().certPEM
 }
 
 // LoadCertPEM returns the byte array of the PEM encoded Cert.
@@ -0,0 +1,207 @@
+package certificates
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestValidateRequestRSAKeySize(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+	if err := issuer.ValidateRequest(newTestCSR(t, smallKey, "small"), &SignOptions{}); err == nil {
+		t.Errorf("ValidateRequest() accepted a 1024 bit RSA key")
+	}
+	if err := issuer.ValidateRequest(newTestCSR(t, newTestKey(t, "rsa"), "large"), &SignOptions{}); err != nil {
+		t.Errorf("ValidateRequest() error = %s for a %d bit RSA key", err, DefaultMinRSAKeySize)
+	}
+
+	if err := issuer.SetMinRSAKeySize(1024); err == nil {
+		t.Errorf("SetMinRSAKeySize() accepted a minimum below %d", DefaultMinRSAKeySize)
+	}
+	if err := issuer.SetMinRSAKeySize(3072); err != nil {
+		t.Fatalf("SetMinRSAKeySize() error = %s", err)
+	}
+	if err := issuer.ValidateRequest(newTestCSR(t, newTestKey(t, "rsa"), "large"), &SignOptions{}); err == nil {
+		t.Errorf("ValidateRequest() accepted a %d bit RSA key with a minimum of 3072", DefaultMinRSAKeySize)
+	}
+}
+
+func TestSignKeyTypes(t *testing.T) {
+	caTests := []struct {
+		keyType            string
+		signatureAlgorithm x509.SignatureAlgorithm
+	}{
+		{keyType: "ecdsa", signatureAlgorithm: x509.ECDSAWithSHA384},
+		{keyType: "ed25519", signatureAlgorithm: x509.PureEd25519},
+		{keyType: "rsa", signatureAlgorithm: x509.SHA256WithRSA},
+	}
+	for _, ca := range caTests {
+		caKey := newTestKey(t, ca.keyType)
+		caPEM, caCert := newTestCA(t, caKey, ca.keyType+"-ca")
+		issuer, err := NewTriremeIssuer(caPEM, caCert, caKey)
+		if err != nil {
+			t.Fatalf("failed to create issuer with a %s CA: %s", ca.keyType, err)
+		}
+		roots := x509.NewCertPool()
+		roots.AddCert(caCert)
+
+		for _, keyType := range []string{"ecdsa", "ed25519", "rsa"} {
+			t.Run(ca.keyType+" CA/"+keyType+" request", func(t *testing.T) {
+				key := newTestKey(t, keyType)
+				csr := newTestCSR(t, key, "workload")
+				if err := issuer.ValidateRequest(csr, &SignOptions{}); err != nil {
+					t.Fatalf("ValidateRequest() error = %s", err)
+				}
+
+				cert := signTestCert(t, issuer, key, "workload")
+				if cert.SignatureAlgorithm != ca.signatureAlgorithm {
+					t.Errorf("signature algorithm = %s, want %s", cert.SignatureAlgorithm, ca.signatureAlgorithm)
+				}
+				if !key.Public().(interface{ Equal(x crypto.PublicKey) bool }).Equal(cert.PublicKey) {
+					t.Errorf("certificate has not been issued for the key of the request")
+				}
+				if err := issuer.ValidateCert(cert, nil); err != nil {
+					t.Errorf("ValidateCert() error = %s", err)
+				}
+				if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+					t.Errorf("certificate does not chain to the CA: %s", err)
+				}
+
+				// only RSA keys can encipher keys, ECDSA and Ed25519 keys are used for key agreement or signatures
+				if got, want := cert.KeyUsage&x509.KeyUsageKeyEncipherment != 0, keyType == "rsa"; got != want {
+					t.Errorf("key encipherment usage = %v for a %s key, want %v", got, keyType, want)
+				}
+				if cert.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+					t.Errorf("certificate is missing the digital signature usage")
+				}
+			})
+		}
+	}
+}
+
+// failingSigner is a signing key whose signatures fail with `err`
+type failingSigner struct {
+	crypto.Signer
+	err error
+}
+
+func (s *failingSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return nil, s.err
+}
+
+// temporarySignerError is a temporary error of a remote signer
+type temporarySignerError struct{}
+
+func (temporarySignerError) Error() string   { return "signing service unavailable" }
+func (temporarySignerError) Temporary() bool { return true }
+
+func TestSignTemporaryErrors(t *testing.T) {
+	key := newTestKey(t, "ecdsa")
+	caPEM, caCert := newTestCA(t, key, "test-ca")
+
+	tests := []struct {
+		name      string
+		err       error
+		temporary bool
+	}{
+		{name: "temporary", err: temporarySignerError{}, temporary: true},
+		{name: "permanent", err: errors.New("key is disabled"), temporary: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer, err := NewTriremeIssuer(caPEM, caCert, &failingSigner{Signer: key, err: tt.err})
+			if err != nil {
+				t.Fatalf("failed to create issuer: %s", err)
+			}
+			_, err = issuer.Sign(newTestCSR(t, newTestKey(t, "ecdsa"), "workload"), &SignOptions{})
+			if err == nil {
+				t.Fatalf("Sign() succeeded with a failing signer")
+			}
+			if IsTemporary(err) != tt.temporary {
+				t.Errorf("IsTemporary(%s) = %v, want %v", err, IsTemporary(err), tt.temporary)
+			}
+		})
+	}
+}
+
+func TestSetSignatureAlgorithm(t *testing.T) {
+	tests := []struct {
+		keyType   string
+		algorithm string
+		want      x509.SignatureAlgorithm
+		wantErr   bool
+	}{
+		{keyType: "rsa", algorithm: "SHA384-RSAPSS", want: x509.SHA384WithRSAPSS},
+		{keyType: "rsa", algorithm: "SHA512-RSA", want: x509.SHA512WithRSA},
+		{keyType: "ecdsa", algorithm: "ECDSA-SHA512", want: x509.ECDSAWithSHA512},
+		{keyType: "ecdsa", algorithm: "SHA384-RSAPSS", wantErr: true},
+		{keyType: "ecdsa", algorithm: "SHA256-RSA", wantErr: true},
+		{keyType: "ed25519", algorithm: "SHA384-RSAPSS", wantErr: true},
+		{keyType: "ed25519", algorithm: "ECDSA-SHA256", wantErr: true},
+		{keyType: "rsa", algorithm: "SHA384-RSA-PSS", wantErr: true},
+		{keyType: "rsa", algorithm: "MD5-RSA", wantErr: true},
+		{keyType: "rsa", algorithm: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.keyType+" CA/"+tt.algorithm, func(t *testing.T) {
+			caKey := newTestKey(t, tt.keyType)
+			caPEM, caCert := newTestCA(t, caKey, tt.keyType+"-ca")
+			issuer, err := NewTriremeIssuer(caPEM, caCert, caKey)
+			if err != nil {
+				t.Fatalf("failed to create issuer: %s", err)
+			}
+			defaultAlgorithm := signTestCert(t, issuer, newTestKey(t, "ecdsa"), "before").SignatureAlgorithm
+
+			err = issuer.SetSignatureAlgorithm(tt.algorithm)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetSignatureAlgorithm() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			cert := signTestCert(t, issuer, newTestKey(t, "ecdsa"), "workload")
+			want := tt.want
+			if tt.wantErr {
+				// a rejected algorithm keeps the previous one
+				want = defaultAlgorithm
+			}
+			if cert.SignatureAlgorithm != want {
+				t.Errorf("signature algorithm = %s, want %s", cert.SignatureAlgorithm, want)
+			}
+			if err := cert.CheckSignatureFrom(caCert); err != nil {
+				t.Errorf("certificate signature can not be verified with the CA: %s", err)
+			}
+		})
+	}
+}
+
+// unsupportedSigner is a signer with a public key of a type that can not sign certificates
+type unsupportedSigner struct{}
+
+type unsupportedPublicKey struct{}
+
+func (unsupportedSigner) Public() crypto.PublicKey { return unsupportedPublicKey{} }
+
+func (unsupportedSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return nil, fmt.Errorf("can not sign")
+}
+
+func TestUnsupportedCAKey(t *testing.T) {
+	caPEM, _ := newTestCA(t, newTestKey(t, "ecdsa"), "test-ca")
+
+	if _, err := NewTriremeIssuerWithSigner(caPEM, unsupportedSigner{}); err == nil || !strings.Contains(err.Error(), "unsupported signing key type") {
+		t.Errorf("NewTriremeIssuerWithSigner() error = %v for an unsupported key type, want an unsupported signing key type error", err)
+	}
+	if _, err := NewTriremeIssuer(caPEM, nil, "not a key"); err == nil {
+		t.Errorf("NewTriremeIssuer() accepted a key that can not sign")
+	}
+}
@@ -0,0 +1,231 @@
+package certificates
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/youmark/pkcs8"
+	"go.aporeto.io/tg/tglib"
+)
+
+// KeyType is the type of the private keys generated by the CertManager
+type KeyType string
+
+// Supported key types
+const (
+	KeyTypeECDSA   KeyType = "ecdsa"
+	KeyTypeEd25519 KeyType = "ed25519"
+)
+
+// generatePrivateKey generates a new private key of the given type
+func generatePrivateKey(keyType KeyType) (crypto.PrivateKey, error) {
+	switch keyType {
+	case KeyTypeECDSA:
+		return tglib.ECPrivateKeyGenerator()
+	case KeyTypeEd25519:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		return privateKey, err
+	default:
+		return nil, fmt.Errorf("unsupported key type '%s'", keyType)
+	}
+}
+
+// keyToPEM encodes the private key in a PEM block: SEC 1 for ECDSA keys, and PKCS#8 for all others
+func keyToPEM(key crypto.PrivateKey) (*pem.Block, error) {
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		return tglib.KeyToPEM(key)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &pem.Block{Type: "PRIVATE KEY", Bytes: der}, nil
+}
+
+// signatureAlgorithm returns the default algorithm used to sign certificates with the given public CA key.
+// The hash of RSA signatures grows with the key size.
+func signatureAlgorithm(key crypto.PublicKey) (x509.SignatureAlgorithm, error) {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		return x509.ECDSAWithSHA384, nil
+	case ed25519.PublicKey:
+		return x509.PureEd25519, nil
+	case *rsa.PublicKey:
+		switch size := k.N.BitLen(); {
+		case size >= 7680:
+			return x509.SHA512WithRSA, nil
+		case size >= 3072:
+			return x509.SHA384WithRSA, nil
+		default:
+			return x509.SHA256WithRSA, nil
+		}
+	default:
+		return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported signing key type %T (only ECDSA, Ed25519 and RSA keys are supported)", key)
+	}
+}
+
+// signatureAlgorithms lists the algorithms that can be chosen explicitly to sign with a key type
+var signatureAlgorithms = map[x509.PublicKeyAlgorithm][]x509.SignatureAlgorithm{
+	x509.ECDSA:   {x509.ECDSAWithSHA256, x509.ECDSAWithSHA384, x509.ECDSAWithSHA512},
+	x509.Ed25519: {x509.PureEd25519},
+	x509.RSA: {
+		x509.SHA256WithRSA, x509.SHA384WithRSA, x509.SHA512WithRSA,
+		x509.SHA256WithRSAPSS, x509.SHA384WithRSAPSS, x509.SHA512WithRSAPSS,
+	},
+}
+
+// parseSignatureAlgorithm returns the signature algorithm named `name`, e.g. `SHA256-RSAPSS`,
+// if it can be used with the given public CA key
+func parseSignatureAlgorithm(name string, key crypto.PublicKey) (x509.SignatureAlgorithm, error) {
+	var keyAlgorithm x509.PublicKeyAlgorithm
+	switch key.(type) {
+	case *ecdsa.PublicKey:
+		keyAlgorithm = x509.ECDSA
+	case ed25519.PublicKey:
+		keyAlgorithm = x509.Ed25519
+	case *rsa.PublicKey:
+		keyAlgorithm = x509.RSA
+	default:
+		return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported signing key type %T", key)
+	}
+
+	for _, algorithm := range signatureAlgorithms[keyAlgorithm] {
+		if algorithm.String() == name {
+			return algorithm, nil
+		}
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("signature algorithm '%s' can not be used with a %s signing key", name, keyAlgorithm)
+}
+
+// ReadCertificatePEM reads a PEM certificate and its PEM private key, which is decrypted with `keyPass` if encrypted
+func ReadCertificatePEM(certPath, keyPath, keyPass string) (*x509.Certificate, crypto.PrivateKey, error) {
+	certPEM, err := LoadCertPEM(certPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := parseCertificatePEM(certPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", certPath, err)
+	}
+
+	key, err := ReadPrivateKeyPEM(keyPath, keyPass)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// parseCertificatePEM parses the first PEM certificate
+func parseCertificatePEM(certPEM []byte) (*x509.Certificate, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse certificate: %s", err)
+	}
+	return cert, nil
+}
+
+// parseCertificatesPEM parses all PEM certificates of a bundle, in order
+func parseCertificatesPEM(bundlePEM []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var certBlock *pem.Block
+		certBlock, bundlePEM = pem.Decode(bundlePEM)
+		if certBlock == nil {
+			break
+		}
+		if certBlock.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(certBlock.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse certificate %d of bundle: %s", len(certs)+1, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	return certs, nil
+}
+
+// ReadPrivateKeyPEM reads a PEM private key, which is decrypted with `keyPass` if encrypted
+func ReadPrivateKeyPEM(keyPath, keyPass string) (crypto.PrivateKey, error) {
+	keyPEM, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read key file: %s", err)
+	}
+	return ParsePrivateKeyPEM(keyPEM, keyPass)
+}
+
+// ParsePrivateKeyPEM parses SEC 1 EC, PKCS#1 RSA and PKCS#8 private keys. Encrypted keys are decrypted with `keyPass`,
+// which works for legacy encrypted PEM blocks (`Proc-Type: 4,ENCRYPTED`) of all three formats, and for encrypted
+// PKCS#8 keys (`ENCRYPTED PRIVATE KEY`) with PBES2 or the common PBES1 schemes.
+func ParsePrivateKeyPEM(keyPEM []byte, keyPass string) (crypto.PrivateKey, error) {
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, fmt.Errorf("no PEM private key found")
+	}
+
+	if keyBlock.Type == "ENCRYPTED PRIVATE KEY" {
+		if keyPass == "" {
+			return nil, fmt.Errorf("private key is encrypted, but no passphrase has been configured")
+		}
+		key, err := pkcs8.ParsePKCS8PrivateKey(keyBlock.Bytes, []byte(keyPass))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt PKCS#8 private key, the passphrase may be wrong: %s", err)
+		}
+		return key, nil
+	}
+
+	der := keyBlock.Bytes
+	encrypted := x509.IsEncryptedPEMBlock(keyBlock)
+	if encrypted {
+		if keyPass == "" {
+			return nil, fmt.Errorf("private key is encrypted, but no passphrase has been configured")
+		}
+		var err error
+		der, err = x509.DecryptPEMBlock(keyBlock, []byte(keyPass))
+		if err == x509.IncorrectPasswordError {
+			return nil, fmt.Errorf("unable to decrypt private key: incorrect passphrase")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt private key: %s", err)
+		}
+	}
+
+	key, err := parsePrivateKeyDER(keyBlock.Type, der)
+	if err != nil {
+		// the decryption of legacy PEM blocks does not always detect a wrong passphrase
+		if encrypted {
+			return nil, fmt.Errorf("unable to parse decrypted private key, the passphrase may be wrong: %s", err)
+		}
+		return nil, fmt.Errorf("unable to parse private key: %s", err)
+	}
+	return key, nil
+}
+
+// parsePrivateKeyDER parses the DER private key of the PEM block type
+func parsePrivateKeyDER(blockType string, der []byte) (crypto.PrivateKey, error) {
+	switch blockType {
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(der)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(der)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(der)
+	default:
+		return nil, fmt.Errorf("unsupported private key PEM type '%s'", blockType)
+	}
+}
@@ -0,0 +1,183 @@
+package certificates
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/youmark/pkcs8"
+)
+
+func TestGeneratePrivateKey(t *testing.T) {
+	for _, keyType := range []KeyType{KeyTypeECDSA, KeyTypeEd25519} {
+		t.Run(string(keyType), func(t *testing.T) {
+			key, err := generatePrivateKey(keyType)
+			if err != nil {
+				t.Fatalf("generatePrivateKey() error = %s", err)
+			}
+			switch keyType {
+			case KeyTypeECDSA:
+				if _, ok := key.(*ecdsa.PrivateKey); !ok {
+					t.Fatalf("generatePrivateKey() = %T, want an ECDSA key", key)
+				}
+			case KeyTypeEd25519:
+				if _, ok := key.(ed25519.PrivateKey); !ok {
+					t.Fatalf("generatePrivateKey() = %T, want an Ed25519 key", key)
+				}
+			}
+
+			block, err := keyToPEM(key)
+			if err != nil {
+				t.Fatalf("keyToPEM() error = %s", err)
+			}
+			parsed, err := ParsePrivateKeyPEM(pem.EncodeToMemory(block), "")
+			if err != nil {
+				t.Fatalf("ParsePrivateKeyPEM() error = %s", err)
+			}
+			if !parsed.(crypto.Signer).Public().(interface{ Equal(x crypto.PublicKey) bool }).Equal(key.(crypto.Signer).Public()) {
+				t.Errorf("parsed key differs from the generated key")
+			}
+		})
+	}
+
+	if _, err := generatePrivateKey("dsa"); err == nil {
+		t.Errorf("generatePrivateKey() accepted an unsupported key type")
+	}
+}
+
+func TestSignatureAlgorithm(t *testing.T) {
+	tests := []struct {
+		keyType string
+		want    string
+		other   string
+	}{
+		{keyType: "ecdsa", want: "ECDSA-SHA384", other: "SHA256-RSA"},
+		{keyType: "ed25519", want: "Ed25519", other: "ECDSA-SHA256"},
+		{keyType: "rsa", want: "SHA256-RSA", other: "Ed25519"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.keyType, func(t *testing.T) {
+			publicKey := newTestKey(t, tt.keyType).Public()
+
+			algorithm, err := signatureAlgorithm(publicKey)
+			if err != nil {
+				t.Fatalf("signatureAlgorithm() error = %s", err)
+			}
+			if algorithm.String() != tt.want {
+				t.Errorf("signatureAlgorithm() = %s, want %s", algorithm, tt.want)
+			}
+
+			if _, err := parseSignatureAlgorithm(tt.want, publicKey); err != nil {
+				t.Errorf("parseSignatureAlgorithm(%s) error = %s", tt.want, err)
+			}
+			if _, err := parseSignatureAlgorithm(tt.other, publicKey); err == nil {
+				t.Errorf("parseSignatureAlgorithm(%s) accepted an algorithm of another key type", tt.other)
+			}
+		})
+	}
+}
+
+// encryptTestKey returns the key PEM encoded and encrypted with the passphrase in the given format:
+// "pkcs8" for an encrypted PKCS#8 key, or "legacy" for a legacy encrypted PEM block
+func encryptTestKey(t *testing.T, key crypto.PrivateKey, format, keyPass string) []byte {
+	t.Helper()
+
+	switch format {
+	case "pkcs8":
+		der, err := pkcs8.ConvertPrivateKeyToPKCS8(key, []byte(keyPass))
+		if err != nil {
+			t.Fatalf("failed to encrypt PKCS#8 key: %s", err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: der})
+	case "legacy":
+		var blockType string
+		var der []byte
+		switch k := key.(type) {
+		case *ecdsa.PrivateKey:
+			var err error
+			blockType = "EC PRIVATE KEY"
+			if der, err = x509.MarshalECPrivateKey(k); err != nil {
+				t.Fatalf("failed to encode EC key: %s", err)
+			}
+		case *rsa.PrivateKey:
+			blockType = "RSA PRIVATE KEY"
+			der = x509.MarshalPKCS1PrivateKey(k)
+		default:
+			t.Fatalf("legacy encrypted PEM blocks do not support %T keys", key)
+		}
+		encrypted, err := x509.EncryptPEMBlock(rand.Reader, blockType, der, []byte(keyPass), x509.PEMCipherAES256)
+		if err != nil {
+			t.Fatalf("failed to encrypt PEM block: %s", err)
+		}
+		return pem.EncodeToMemory(encrypted)
+	default:
+		t.Fatalf("unknown key format '%s'", format)
+		return nil
+	}
+}
+
+func TestParsePrivateKeyPEMEncrypted(t *testing.T) {
+	tests := []struct {
+		keyType string
+		format  string
+	}{
+		{keyType: "ecdsa", format: "pkcs8"},
+		{keyType: "rsa", format: "pkcs8"},
+		{keyType: "ed25519", format: "pkcs8"},
+		{keyType: "ecdsa", format: "legacy"},
+		{keyType: "rsa", format: "legacy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format+"/"+tt.keyType, func(t *testing.T) {
+			key := newTestKey(t, tt.keyType)
+			keyPEM := encryptTestKey(t, key, tt.format, "correct horse")
+
+			parsed, err := ParsePrivateKeyPEM(keyPEM, "correct horse")
+			if err != nil {
+				t.Fatalf("ParsePrivateKeyPEM() error = %s", err)
+			}
+			if !parsed.(crypto.Signer).Public().(interface{ Equal(x crypto.PublicKey) bool }).Equal(key.Public()) {
+				t.Errorf("decrypted key differs from the encrypted key")
+			}
+
+			if _, err := ParsePrivateKeyPEM(keyPEM, "wrong horse"); err == nil {
+				t.Errorf("ParsePrivateKeyPEM() succeeded with a wrong passphrase")
+			}
+			if _, err := ParsePrivateKeyPEM(keyPEM, ""); err == nil {
+				t.Errorf("ParsePrivateKeyPEM() succeeded without a passphrase")
+			}
+		})
+	}
+}
+
+func TestParseSignatureAlgorithm(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		key       crypto.PublicKey
+		want      x509.SignatureAlgorithm
+		wantErr   bool
+	}{
+		{name: "RSA-PSS with an RSA key", algorithm: "SHA384-RSAPSS", key: newTestKey(t, "rsa").Public(), want: x509.SHA384WithRSAPSS},
+		{name: "RSA-PSS with an ECDSA key", algorithm: "SHA384-RSAPSS", key: newTestKey(t, "ecdsa").Public(), wantErr: true},
+		{name: "RSA with an Ed25519 key", algorithm: "SHA256-RSA", key: newTestKey(t, "ed25519").Public(), wantErr: true},
+		{name: "unknown algorithm", algorithm: "SHA3-RSA", key: newTestKey(t, "rsa").Public(), wantErr: true},
+		{name: "unsupported key type", algorithm: "SHA256-RSA", key: "not a key", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSignatureAlgorithm(tt.algorithm, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSignatureAlgorithm() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseSignatureAlgorithm() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package certificates
+
+import "strings"
+
+// MatchesAny returns true if the value matches one of the patterns, where `*` matches any characters
+func MatchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if globMatch(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch returns true if the value matches the pattern, where `*` matches any characters, like in Vault roles.
+// Patterns without `*` only match the same value.
+func globMatch(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		index := strings.Index(value, part)
+		if index < 0 {
+			return false
+		}
+		value = value[index+len(part):]
+	}
+	return len(value) >= len(last) && strings.HasSuffix(value, last)
+}
+
+// containsString returns true if the slice contains the value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
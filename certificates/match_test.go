@@ -0,0 +1,65 @@
+package certificates
+
+import "testing"
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		want    bool
+	}{
+		{pattern: "node", value: "node", want: true},
+		{pattern: "node", value: "node-1", want: false},
+		{pattern: "node", value: "", want: false},
+		{pattern: "", value: "", want: true},
+		{pattern: "*", value: "", want: true},
+		{pattern: "*", value: "anything", want: true},
+		{pattern: "node-*", value: "node-1", want: true},
+		{pattern: "node-*", value: "node-", want: true},
+		{pattern: "node-*", value: "worker-1", want: false},
+		{pattern: "*.example.com", value: "api.example.com", want: true},
+		{pattern: "*.example.com", value: "example.com", want: false},
+		{pattern: "*.example.com", value: "api.example.com.evil", want: false},
+		{pattern: "a*b*c", value: "abc", want: true},
+		{pattern: "a*b*c", value: "axxbyyc", want: true},
+		{pattern: "a*b*c", value: "acb", want: false},
+		// the prefix and the suffix must not overlap
+		{pattern: "ab*ba", value: "aba", want: false},
+		{pattern: "ab*ba", value: "abba", want: true},
+		{pattern: "**", value: "x", want: true},
+		// patterns are not regular expressions
+		{pattern: "node.?", value: "node-1", want: false},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesAny(t *testing.T) {
+	patterns := []string{"node-*", "ingress"}
+
+	for value, want := range map[string]bool{"node-1": true, "ingress": true, "ingress-1": false, "worker": false} {
+		if got := MatchesAny(patterns, value); got != want {
+			t.Errorf("MatchesAny(%v, %q) = %v, want %v", patterns, value, got, want)
+		}
+	}
+	if MatchesAny(nil, "node") {
+		t.Errorf("MatchesAny() = true without patterns, want false")
+	}
+}
+
+func TestContainsString(t *testing.T) {
+	values := []string{"DigitalSignature", "KeyEncipherment"}
+
+	if !containsString(values, "KeyEncipherment") {
+		t.Errorf("containsString() = false for a value of the slice")
+	}
+	if containsString(values, "keyencipherment") {
+		t.Errorf("containsString() = true for a value that only differs in case")
+	}
+	if containsString(nil, "") {
+		t.Errorf("containsString() = true for an empty slice")
+	}
+}
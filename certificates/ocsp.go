@@ -0,0 +1,57 @@
+package certificates
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// SetOCSPResponderURL sets the URL of the OCSP responder that gets embedded in the
+// Authority Information Access extension of all issued certificates.
+func (i *TriremeIssuer) SetOCSPResponderURL(url string) {
+	if url == "" {
+		i.ocspServers = nil
+		return
+	}
+	i.ocspServers = []string{url}
+}
+
+// SetOCSPSigner configures a delegated OCSP signing certificate to sign OCSP responses, instead of the CA.
+// The certificate must be issued by the signing CA and must be valid for OCSP signing.
+func (i *TriremeIssuer) SetOCSPSigner(cert *x509.Certificate, key crypto.PrivateKey) error {
+	if err := cert.CheckSignatureFrom(i.currentCA().cert); err != nil {
+		return fmt.Errorf("OCSP signing certificate has not been issued by the signing CA: %s", err)
+	}
+
+	ocspSigning := false
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageOCSPSigning {
+			ocspSigning = true
+			break
+		}
+	}
+	if !ocspSigning {
+		return fmt.Errorf("OCSP signing certificate is missing the OCSP signing extended key usage")
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return fmt.Errorf("OCSP signing key can not be used to sign: %T", key)
+	}
+
+	i.ocspSignerCert = cert
+	i.ocspSignerKey = signer
+	return nil
+}
+
+// SignOCSPResponse signs the OCSP response with the delegated OCSP signer if configured, or with the signing CA.
+func (i *TriremeIssuer) SignOCSPResponse(template ocsp.Response) ([]byte, error) {
+	ca := i.currentCA()
+	if i.ocspSignerCert != nil {
+		template.Certificate = i.ocspSignerCert
+		return ocsp.CreateResponse(ca.cert, i.ocspSignerCert, template, i.ocspSignerKey)
+	}
+	return ocsp.CreateResponse(ca.cert, ca.cert, template, ca.signer)
+}
@@ -0,0 +1,129 @@
+package certificates
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// newTestOCSPSigner creates a certificate for the key signed by the CA, with the given extended key usages
+func newTestOCSPSigner(t *testing.T, ca *x509.Certificate, caKey crypto.Signer, key crypto.Signer, usages []x509.ExtKeyUsage) *x509.Certificate {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "ocsp-signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  usages,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
+	if err != nil {
+		t.Fatalf("failed to create OCSP signing certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse OCSP signing certificate: %s", err)
+	}
+	return cert
+}
+
+func TestSetOCSPSigner(t *testing.T) {
+	caKey := newTestKey(t, "ecdsa")
+	caPEM, ca := newTestCA(t, caKey, "test-ca")
+	issuer, err := NewTriremeIssuer(caPEM, ca, caKey)
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+	otherKey := newTestKey(t, "ecdsa")
+	_, other := newTestCA(t, otherKey, "other-ca")
+	key := newTestKey(t, "ecdsa")
+
+	tests := []struct {
+		name    string
+		cert    *x509.Certificate
+		wantErr bool
+	}{
+		{
+			name: "OCSP signing certificate of the CA",
+			cert: newTestOCSPSigner(t, ca, caKey, key, []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}),
+		},
+		{
+			name:    "certificate of another CA",
+			cert:    newTestOCSPSigner(t, other, otherKey, key, []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}),
+			wantErr: true,
+		},
+		{
+			name:    "certificate without the OCSP signing usage",
+			cert:    newTestOCSPSigner(t, ca, caKey, key, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := issuer.SetOCSPSigner(tt.cert, key); (err != nil) != tt.wantErr {
+				t.Errorf("SetOCSPSigner() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignOCSPResponse(t *testing.T) {
+	caKey := newTestKey(t, "ecdsa")
+	caPEM, ca := newTestCA(t, caKey, "test-ca")
+	issuer, err := NewTriremeIssuer(caPEM, ca, caKey)
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+	cert := signTestCert(t, issuer, newTestKey(t, "ecdsa"), "leaf")
+	template := ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: cert.SerialNumber,
+		ThisUpdate:   time.Now(),
+		NextUpdate:   time.Now().Add(time.Hour),
+	}
+
+	// without a delegated signer, the CA signs the response itself
+	der, err := issuer.SignOCSPResponse(template)
+	if err != nil {
+		t.Fatalf("SignOCSPResponse() error = %s", err)
+	}
+	response, err := ocsp.ParseResponseForCert(der, cert, ca)
+	if err != nil {
+		t.Fatalf("OCSP response can not be verified with the CA: %s", err)
+	}
+	if response.Certificate != nil {
+		t.Errorf("OCSP response signed by the CA embeds a signing certificate")
+	}
+	if response.Status != ocsp.Good || response.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+		t.Errorf("OCSP response = status %d for serial %s, want %d for %s", response.Status, response.SerialNumber, ocsp.Good, cert.SerialNumber)
+	}
+
+	// with a delegated signer, the response is signed by it and embeds its certificate
+	signerKey := newTestKey(t, "ecdsa")
+	signer := newTestOCSPSigner(t, ca, caKey, signerKey, []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning})
+	if err := issuer.SetOCSPSigner(signer, signerKey); err != nil {
+		t.Fatalf("SetOCSPSigner() error = %s", err)
+	}
+	der, err = issuer.SignOCSPResponse(template)
+	if err != nil {
+		t.Fatalf("SignOCSPResponse() error = %s", err)
+	}
+	response, err = ocsp.ParseResponseForCert(der, cert, ca)
+	if err != nil {
+		t.Fatalf("OCSP response of the delegated signer can not be verified with the CA: %s", err)
+	}
+	if response.Certificate == nil || !response.Certificate.Equal(signer) {
+		t.Errorf("OCSP response has not been signed by the delegated signer")
+	}
+	if err := response.CheckSignatureFrom(signer); err != nil {
+		t.Errorf("OCSP response signature can not be verified with the delegated signer: %s", err)
+	}
+}
@@ -0,0 +1,292 @@
+package certificates
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"time"
+
+	"go.aporeto.io/trireme-lib/controller/pkg/pkiverifier"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ocsp"
+)
+
+// signingCA is the CA that an issuer signs with. It is never modified once in use, but replaced as a whole,
+// so that a signing always uses a matching certificate and key.
+type signingCA struct {
+	cert               *x509.Certificate
+	certPEM            []byte
+	signer             crypto.Signer
+	signatureAlgorithm x509.SignatureAlgorithm
+	chain              *caChain
+	// tokenIssuer signs tokens with the CA key, which only works with ECDSA keys
+	tokenIssuer pkiverifier.PKITokenIssuer
+}
+
+// newSigningCA creates the signing CA from its bundle and its signer, and verifies that they belong together.
+// The signer can be a private key in memory, or a client of a signing service that keeps the key.
+func newSigningCA(bundlePEM []byte, signer crypto.Signer) (*signingCA, error) {
+	sigAlg, err := signatureAlgorithm(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := parseCAChain(bundlePEM)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !publicKey.Equal(chain.cert.PublicKey) {
+		return nil, fmt.Errorf("the signing key does not belong to the signing CA certificate")
+	}
+
+	// the PKI token issuer only works with ECDSA keys in memory, all others need a separate token signing key
+	var pkiIssuer pkiverifier.PKITokenIssuer
+	if ecdsaKey, ok := signer.(*ecdsa.PrivateKey); ok {
+		pkiIssuer = pkiverifier.NewPKIIssuer(ecdsaKey)
+	}
+
+	return &signingCA{
+		cert:               chain.cert,
+		certPEM:            encodeCertificatePEM(chain.cert),
+		signer:             signer,
+		signatureAlgorithm: sigAlg,
+		chain:              chain,
+		tokenIssuer:        pkiIssuer,
+	}, nil
+}
+
+// currentCA returns the signing CA that is currently in use
+func (i *TriremeIssuer) currentCA() *signingCA {
+	i.caLock.RLock()
+	defer i.caLock.RUnlock()
+	return i.ca
+}
+
+// retired returns the signing CAs that have been replaced by a reload and have not expired yet
+func (i *TriremeIssuer) retired() []*signingCA {
+	i.caLock.RLock()
+	defer i.caLock.RUnlock()
+
+	now := time.Now()
+	retired := make([]*signingCA, 0, len(i.retiredCAs))
+	for _, ca := range i.retiredCAs {
+		if now.Before(ca.cert.NotAfter) {
+			retired = append(retired, ca)
+		}
+	}
+	return retired
+}
+
+// retire returns the replaced signing CAs once `previous` has been replaced by `current`, without the expired
+// ones and without the current one, which a reload can switch back to
+func retire(retiredCAs []*signingCA, previous, current *signingCA) []*signingCA {
+	now := time.Now()
+	var kept []*signingCA
+	for _, ca := range append([]*signingCA{previous}, retiredCAs...) {
+		if ca == nil || ca.cert.Equal(current.cert) || !now.Before(ca.cert.NotAfter) {
+			continue
+		}
+		kept = append(kept, ca)
+	}
+	return kept
+}
+
+// Reload replaces the signing CA with the PEM encoded CA bundle and key, once they have been validated against
+// the settings of the issuer. Signings that are in progress finish with the previous CA. If the new CA fails to
+// load, the current one is kept and an error is returned. Reloading unchanged data has no effect.
+// The previous CA is retired: the certificates it issued stay valid and get re-issued, and it keeps signing
+// their CRL and OCSP responses until it expires.
+func (i *TriremeIssuer) Reload(caCertPEM, caKeyPEM []byte, keyPass string) error {
+	hash := hashCA(caCertPEM, caKeyPEM)
+	i.caLock.RLock()
+	unchanged := bytes.Equal(i.caHash, hash)
+	i.caLock.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	zap.L().Info("Signing CA changed, reloading", zap.String("sha256", fmt.Sprintf("%x", hash)))
+	key, err := ParsePrivateKeyPEM(caKeyPEM, keyPass)
+	if err != nil {
+		return err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return fmt.Errorf("signing key can not be used to sign: %T", key)
+	}
+	ca, err := newSigningCA(caCertPEM, signer)
+	if err != nil {
+		return err
+	}
+	if i.signatureAlgorithmName != "" {
+		ca.signatureAlgorithm, err = parseSignatureAlgorithm(i.signatureAlgorithmName, signer.Public())
+		if err != nil {
+			return err
+		}
+	}
+	if err := i.validateTokenIssuer(ca); err != nil {
+		return err
+	}
+	if i.ocspSignerCert != nil {
+		if err := i.ocspSignerCert.CheckSignatureFrom(ca.cert); err != nil {
+			return fmt.Errorf("OCSP signing certificate has not been issued by the new signing CA: %s", err)
+		}
+	}
+
+	i.caLock.Lock()
+	i.retiredCAs = retire(i.retiredCAs, i.ca, ca)
+	i.ca = ca
+	i.caHash = hash
+	i.caLock.Unlock()
+
+	zap.L().Info("Signing CA reloaded", zap.String("subject", ca.cert.Subject.String()), zap.Time("not_after", ca.cert.NotAfter))
+
+	// the CRL must be signed by the new CA, and the previous one needs a CRL of its own
+	if err := i.UpdateCRL(); err != nil {
+		zap.L().Error("Error updating CRL after reloading the signing CA", zap.Error(err))
+	}
+	return nil
+}
+
+// ReloadFiles reloads the signing CA from the PEM encoded CA bundle and key files
+func (i *TriremeIssuer) ReloadFiles(caCertPath, caKeyPath, keyPass string) error {
+	caCertPEM, caKeyPEM, err := readCAFiles(caCertPath, caKeyPath)
+	if err != nil {
+		return err
+	}
+	return i.Reload(caCertPEM, caKeyPEM, keyPass)
+}
+
+// WatchFiles reloads the signing CA from its files every `interval` until stopCh closes.
+// Errors are logged once per change of the files, and the current signing CA stays active.
+func (i *TriremeIssuer) WatchFiles(caCertPath, caKeyPath, keyPass string, interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// failed identifies the content of the files, or the error reading them, that failed to load last
+	var failed string
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		var state string
+		caCertPEM, caKeyPEM, err := readCAFiles(caCertPath, caKeyPath)
+		if err == nil {
+			state = string(hashCA(caCertPEM, caKeyPEM))
+			err = i.Reload(caCertPEM, caKeyPEM, keyPass)
+		} else {
+			state = err.Error()
+		}
+		if err == nil {
+			failed = ""
+			continue
+		}
+		if state == failed {
+			continue
+		}
+		failed = state
+		zap.L().Error("Error reloading signing CA, keeping the current one", zap.String("file", caCertPath), zap.Error(err))
+	}
+}
+
+// readCAFiles reads the PEM encoded CA bundle and key files
+func readCAFiles(caCertPath, caKeyPath string) ([]byte, []byte, error) {
+	caCertPEM, err := LoadCertPEM(caCertPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	caKeyPEM, err := ioutil.ReadFile(caKeyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read key file: %s", err)
+	}
+	return caCertPEM, caKeyPEM, nil
+}
+
+// hashCA returns the hash of the CA bundle and key, to detect changes
+func hashCA(caCertPEM, caKeyPEM []byte) []byte {
+	h := sha256.New()
+	h.Write(caCertPEM)
+	h.Write([]byte{0})
+	h.Write(caKeyPEM)
+	return h.Sum(nil)
+}
+
+// RetiredCA is a signing CA that has been replaced by a reload. It keeps answering for the certificates that it
+// issued, with its own CRL and OCSP responses, until it expires.
+type RetiredCA struct {
+	issuer *TriremeIssuer
+	ca     *signingCA
+}
+
+// RetiredCAs returns the signing CAs that have been replaced by a reload and have not expired yet
+func (i *TriremeIssuer) RetiredCAs() []*RetiredCA {
+	var retiredCAs []*RetiredCA
+	for _, ca := range i.retired() {
+		retiredCAs = append(retiredCAs, &RetiredCA{issuer: i, ca: ca})
+	}
+	return retiredCAs
+}
+
+// KeyID returns the hex encoded subject key ID of the CA, which is the authority key ID of its certificates
+func (r *RetiredCA) KeyID() string {
+	return caKeyID(r.ca.cert)
+}
+
+// GetCACert returns the PEM encoded CA certificate
+func (r *RetiredCA) GetCACert() []byte {
+	return r.ca.certPEM
+}
+
+// GetCRL returns the last CRL generated for the CA, DER encoded
+func (r *RetiredCA) GetCRL() ([]byte, error) {
+	r.issuer.revocationLock.RLock()
+	defer r.issuer.revocationLock.RUnlock()
+
+	crl, ok := r.issuer.retiredCRLs[r.ca]
+	if !ok {
+		return nil, fmt.Errorf("CRL has not been generated yet")
+	}
+	return crl, nil
+}
+
+// IsRevoked returns the revocation time and reason of the certificate with the given serial, and true if it is revoked
+func (r *RetiredCA) IsRevoked(serial *big.Int) (time.Time, int, bool) {
+	return r.issuer.IsRevoked(serial)
+}
+
+// SignOCSPResponse signs the OCSP response with the CA, as the delegated OCSP signer belongs to the current one
+func (r *RetiredCA) SignOCSPResponse(template ocsp.Response) ([]byte, error) {
+	return ocsp.CreateResponse(r.ca.cert, r.ca.cert, template, r.ca.signer)
+}
+
+// caKeyID returns the hex encoded subject key ID of the CA certificate. If it has none, the key ID is derived
+// like in method 1 of RFC 5280, section 4.2.1.2, as the SHA-1 hash of the subjectPublicKey BIT STRING, which is
+// how CAs usually derive it. It returns an empty string for a public key that can not be parsed.
+func caKeyID(cert *x509.Certificate) string {
+	if len(cert.SubjectKeyId) > 0 {
+		return hex.EncodeToString(cert.SubjectKeyId)
+	}
+
+	var publicKeyInfo struct {
+		Algorithm asn1.RawValue
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &publicKeyInfo); err != nil {
+		return ""
+	}
+	sum := sha1.Sum(publicKeyInfo.PublicKey.RightAlign())
+	return hex.EncodeToString(sum[:])
+}
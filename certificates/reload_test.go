@@ -0,0 +1,156 @@
+package certificates
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// newTestCAData creates a self-signed CA for a new key of the given type, and returns the PEM encoded certificate and key
+func newTestCAData(t *testing.T, keyType, commonName string) ([]byte, []byte) {
+	t.Helper()
+
+	key := newTestKey(t, keyType)
+	caPEM, _ := newTestCA(t, key, commonName)
+	keyBlock, err := keyToPEM(key)
+	if err != nil {
+		t.Fatalf("failed to encode CA key: %s", err)
+	}
+	return caPEM, pem.EncodeToMemory(keyBlock)
+}
+
+func TestReloadRetiresPreviousCA(t *testing.T) {
+	oldCertPEM, oldKeyPEM := newTestCAData(t, "ecdsa", "old-ca")
+	issuer, err := NewTriremeIssuerFromData(oldCertPEM, oldKeyPEM, "")
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+	oldCert := signTestCert(t, issuer, newTestKey(t, "ecdsa"), "old")
+
+	newCertPEM, newKeyPEM := newTestCAData(t, "ecdsa", "new-ca")
+	if err := issuer.Reload(newCertPEM, newKeyPEM, ""); err != nil {
+		t.Fatalf("Reload() error = %s", err)
+	}
+	newCert := signTestCert(t, issuer, newTestKey(t, "ecdsa"), "new")
+	if newCert.Issuer.CommonName != "new-ca" {
+		t.Errorf("certificate issued by '%s' after reload, want 'new-ca'", newCert.Issuer.CommonName)
+	}
+
+	if err := issuer.ValidateCert(oldCert, nil); err != nil {
+		t.Errorf("ValidateCert() error = %s for a certificate of the replaced CA", err)
+	}
+	if _, retiring, err := issuer.ChainFor(oldCert); err != nil || !retiring {
+		t.Errorf("ChainFor() = retiring %v, %v for a certificate of the replaced CA, want retiring", retiring, err)
+	}
+	if _, retiring, err := issuer.ChainFor(newCert); err != nil || retiring {
+		t.Errorf("ChainFor() = retiring %v, %v for a certificate of the new CA, want not retiring", retiring, err)
+	}
+	roots := string(issuer.GetRootCACert())
+	if !strings.Contains(roots, string(newCertPEM)) || !strings.Contains(roots, string(oldCertPEM)) {
+		t.Errorf("GetRootCACert() does not contain the roots of both the new and the replaced CA")
+	}
+
+	retiredCAs := issuer.RetiredCAs()
+	if len(retiredCAs) != 1 {
+		t.Fatalf("RetiredCAs() returned %d CAs, want 1", len(retiredCAs))
+	}
+	if string(retiredCAs[0].GetCACert()) != string(oldCertPEM) {
+		t.Errorf("RetiredCAs() did not return the replaced CA")
+	}
+
+	// reloading the replaced CA makes it the signing CA again, and retires the other one
+	if err := issuer.Reload(oldCertPEM, oldKeyPEM, ""); err != nil {
+		t.Fatalf("Reload() error = %s", err)
+	}
+	retiredCAs = issuer.RetiredCAs()
+	if len(retiredCAs) != 1 || string(retiredCAs[0].GetCACert()) != string(newCertPEM) {
+		t.Errorf("RetiredCAs() after reloading the previous CA does not only contain the other one")
+	}
+}
+
+func TestRetiredCACRL(t *testing.T) {
+	oldCertPEM, oldKeyPEM := newTestCAData(t, "ecdsa", "old-ca")
+	issuer, err := NewTriremeIssuerFromData(oldCertPEM, oldKeyPEM, "")
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+	oldCert := signTestCert(t, issuer, newTestKey(t, "ecdsa"), "old")
+	oldCA, err := parseCertificatePEM(oldCertPEM)
+	if err != nil {
+		t.Fatalf("failed to parse CA certificate: %s", err)
+	}
+
+	newCertPEM, newKeyPEM := newTestCAData(t, "ecdsa", "new-ca")
+	if err := issuer.Reload(newCertPEM, newKeyPEM, ""); err != nil {
+		t.Fatalf("Reload() error = %s", err)
+	}
+	if err := issuer.Revoke(oldCert, time.Now(), 1); err != nil {
+		t.Fatalf("Revoke() error = %s for a certificate of the replaced CA", err)
+	}
+	if _, _, revoked := issuer.IsRevoked(oldCert.SerialNumber); !revoked {
+		t.Errorf("IsRevoked() = false after revoking a certificate of the replaced CA")
+	}
+
+	retiredCAs := issuer.RetiredCAs()
+	if len(retiredCAs) != 1 {
+		t.Fatalf("RetiredCAs() returned %d CAs, want 1", len(retiredCAs))
+	}
+	if got, want := retiredCAs[0].KeyID(), hex.EncodeToString(oldCA.SubjectKeyId); got != want {
+		t.Errorf("KeyID() = %s, want %s", got, want)
+	}
+
+	der, err := retiredCAs[0].GetCRL()
+	if err != nil {
+		t.Fatalf("GetCRL() error = %s", err)
+	}
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		t.Fatalf("failed to parse CRL: %s", err)
+	}
+	if err := crl.CheckSignatureFrom(oldCA); err != nil {
+		t.Errorf("CRL of the replaced CA has not been signed by it: %s", err)
+	}
+	if len(crl.RevokedCertificateEntries) != 1 || crl.RevokedCertificateEntries[0].SerialNumber.Cmp(oldCert.SerialNumber) != 0 {
+		t.Errorf("CRL of the replaced CA does not list the revoked certificate")
+	}
+
+	der, err = retiredCAs[0].SignOCSPResponse(ocsp.Response{
+		Status:       ocsp.Revoked,
+		SerialNumber: oldCert.SerialNumber,
+		ThisUpdate:   time.Now(),
+		NextUpdate:   time.Now().Add(time.Hour),
+		RevokedAt:    time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("SignOCSPResponse() error = %s", err)
+	}
+	if _, err := ocsp.ParseResponseForCert(der, oldCert, oldCA); err != nil {
+		t.Errorf("OCSP response of the replaced CA can not be verified with it: %s", err)
+	}
+}
+
+func TestCAKeyID(t *testing.T) {
+	for _, keyType := range []string{"ecdsa", "rsa", "ed25519"} {
+		t.Run(keyType, func(t *testing.T) {
+			_, ca := newTestCA(t, newTestKey(t, keyType), "test-ca")
+			if len(ca.SubjectKeyId) == 0 {
+				t.Fatalf("CA certificate has no subject key ID")
+			}
+			want := hex.EncodeToString(ca.SubjectKeyId)
+			if got := caKeyID(ca); got != want {
+				t.Errorf("caKeyID() = %s, want %s", got, want)
+			}
+
+			// without the extension, the key ID is derived from the subject public key like the generated one
+			ca.SubjectKeyId = nil
+			if got := caKeyID(ca); got != want {
+				t.Errorf("caKeyID() = %s without a subject key ID, want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package certificates
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultCRLUpdateInterval is the default interval at which the CRL gets regenerated.
+const DefaultCRLUpdateInterval = time.Hour
+
+// Revocation is the revocation of a certificate
+type Revocation struct {
+	Serial    *big.Int
+	RevokedAt time.Time
+	Reason    int
+	// NotAfter is the expiry of the revoked certificate, after which its revocation does not need to be kept anymore
+	NotAfter time.Time
+}
+
+// Revoke adds the certificate to the list of revoked certificates, and regenerates the CRL. The certificate must
+// have been issued by the signing CA or by a signing CA replaced by a reload, as the certificates of trusted CAs
+// can only be revoked on the CRL of their own CA. Revoking a certificate that is already revoked has no effect.
+func (i *TriremeIssuer) Revoke(cert *x509.Certificate, revokedAt time.Time, reason int) error {
+	if _, _, err := i.ChainFor(cert); err != nil {
+		return fmt.Errorf("certificate has not been issued by this issuer: %s", err)
+	}
+	if trusted := i.trustedIssuerOf(cert); trusted != nil {
+		return fmt.Errorf("certificate has been issued by the trusted CA '%s', whose CRL this issuer can not sign", trusted.cert.Subject)
+	}
+
+	added := i.addRevocations([]Revocation{{
+		Serial:    cert.SerialNumber,
+		RevokedAt: revokedAt,
+		Reason:    reason,
+		NotAfter:  cert.NotAfter,
+	}})
+	if !added {
+		return nil
+	}
+
+	zap.L().Info("Certificate revoked", zap.String("serial", cert.SerialNumber.String()), zap.Int("reason", reason))
+	return i.UpdateCRL()
+}
+
+// AddRevocations adds revocations that have been persisted, and regenerates the CRL if any of them is new.
+// Revocations are never removed, they only get dropped from the CRL once their certificate has expired.
+func (i *TriremeIssuer) AddRevocations(revocations []Revocation) error {
+	if !i.addRevocations(revocations) {
+		return nil
+	}
+	return i.UpdateCRL()
+}
+
+// addRevocations adds the revocations that are not known yet, and returns true if there was any
+func (i *TriremeIssuer) addRevocations(revocations []Revocation) bool {
+	i.revocationLock.Lock()
+	defer i.revocationLock.Unlock()
+
+	var added bool
+	for _, revocation := range revocations {
+		key := revocation.Serial.String()
+		if _, ok := i.revoked[key]; ok {
+			continue
+		}
+		i.revoked[key] = revocation
+		added = true
+	}
+	return added
+}
+
+// IsRevoked returns the revocation time and reason of the certificate with the given serial, and true if it is revoked.
+func (i *TriremeIssuer) IsRevoked(serial *big.Int) (time.Time, int, bool) {
+	i.revocationLock.RLock()
+	defer i.revocationLock.RUnlock()
+
+	revoked, ok := i.revoked[serial.String()]
+	if !ok {
+		return time.Time{}, 0, false
+	}
+	return revoked.RevokedAt, revoked.Reason, true
+}
+
+// UpdateCRL regenerates and signs the CRL with all revoked certificates, and the CRLs of the signing CAs that
+// have been replaced by a reload. All CRLs list all revocations, as serials are unique across the CAs.
+func (i *TriremeIssuer) UpdateCRL() error {
+	i.revocationLock.Lock()
+	defer i.revocationLock.Unlock()
+
+	ca := i.currentCA()
+	retiredCAs := i.retired()
+
+	// expired certificates do not need to be listed anymore
+	now := time.Now()
+	entries := make([]x509.RevocationListEntry, 0, len(i.revoked))
+	for _, revoked := range i.revoked {
+		if !revoked.NotAfter.IsZero() && revoked.NotAfter.Before(now) {
+			continue
+		}
+		entries = append(entries, x509.RevocationListEntry{
+			SerialNumber:   revoked.Serial,
+			RevocationTime: revoked.RevokedAt,
+			ReasonCode:     revoked.Reason,
+		})
+	}
+
+	// the CRL number must increase with every CRL, also across restarts
+	number := big.NewInt(now.Unix())
+	if i.crlNumber != nil && number.Cmp(i.crlNumber) <= 0 {
+		number = new(big.Int).Add(i.crlNumber, big.NewInt(1))
+	}
+
+	template := &x509.RevocationList{
+		RevokedCertificateEntries: entries,
+		Number:                    number,
+		ThisUpdate:                now,
+		NextUpdate:                now.Add(i.crlValidity),
+		SignatureAlgorithm:        ca.signatureAlgorithm,
+	}
+	crl, err := x509.CreateRevocationList(rand.Reader, template, ca.cert, ca.signer)
+	if err != nil {
+		return fmt.Errorf("failed to generate CRL: %s", err)
+	}
+
+	retiredCRLs := make(map[*signingCA][]byte, len(retiredCAs))
+	for _, retired := range retiredCAs {
+		template.SignatureAlgorithm = retired.signatureAlgorithm
+		retiredCRL, err := x509.CreateRevocationList(rand.Reader, template, retired.cert, retired.signer)
+		if err != nil {
+			return fmt.Errorf("failed to generate CRL of replaced CA '%s': %s", retired.cert.Subject, err)
+		}
+		retiredCRLs[retired] = retiredCRL
+	}
+
+	i.crl = crl
+	i.retiredCRLs = retiredCRLs
+	i.crlNumber = number
+	zap.L().Debug("CRL updated", zap.String("number", number.String()), zap.Int("revoked", len(entries)))
+	return nil
+}
+
+// GetCRL returns the last generated CRL, DER encoded.
+func (i *TriremeIssuer) GetCRL() ([]byte, error) {
+	i.revocationLock.RLock()
+	defer i.revocationLock.RUnlock()
+
+	if i.crl == nil {
+		return nil, fmt.Errorf("CRL has not been generated yet")
+	}
+	return i.crl, nil
+}
+
+// RunCRLUpdater regenerates the CRL every `interval` until stopCh closes. Every CRL is valid for twice the interval,
+// so that clients never see an expired CRL if one update fails.
+func (i *TriremeIssuer) RunCRLUpdater(interval time.Duration, stopCh <-chan struct{}) {
+	i.revocationLock.Lock()
+	i.crlValidity = 2 * interval
+	i.revocationLock.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := i.UpdateCRL(); err != nil {
+			zap.L().Error("Error updating CRL", zap.Error(err))
+		}
+
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
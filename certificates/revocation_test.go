@@ -0,0 +1,214 @@
+package certificates
+
+import (
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// parseTestCRL returns the current CRL of the issuer, which must have been signed by the CA
+func parseTestCRL(t *testing.T, issuer *TriremeIssuer, ca *x509.Certificate) *x509.RevocationList {
+	t.Helper()
+
+	der, err := issuer.GetCRL()
+	if err != nil {
+		t.Fatalf("GetCRL() error = %s", err)
+	}
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		t.Fatalf("failed to parse CRL: %s", err)
+	}
+	if err := crl.CheckSignatureFrom(ca); err != nil {
+		t.Fatalf("CRL has not been signed by the CA: %s", err)
+	}
+	return crl
+}
+
+// crlReasons returns the reason codes of the CRL entries by serial
+func crlReasons(crl *x509.RevocationList) map[string]int {
+	reasons := map[string]int{}
+	for _, entry := range crl.RevokedCertificateEntries {
+		reasons[entry.SerialNumber.String()] = entry.ReasonCode
+	}
+	return reasons
+}
+
+func TestCRLEntries(t *testing.T) {
+	key := newTestKey(t, "ecdsa")
+	caPEM, ca := newTestCA(t, key, "test-ca")
+	issuer, err := NewTriremeIssuer(caPEM, ca, key)
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+
+	if _, err := issuer.GetCRL(); err == nil {
+		t.Errorf("GetCRL() succeeded before a CRL has been generated")
+	}
+
+	compromised := signTestCert(t, issuer, newTestKey(t, "ecdsa"), "compromised")
+	superseded := signTestCert(t, issuer, newTestKey(t, "ecdsa"), "superseded")
+	valid := signTestCert(t, issuer, newTestKey(t, "ecdsa"), "valid")
+	revokedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+	if err := issuer.Revoke(compromised, revokedAt, 1); err != nil {
+		t.Fatalf("Revoke() error = %s", err)
+	}
+	if err := issuer.Revoke(superseded, revokedAt, 4); err != nil {
+		t.Fatalf("Revoke() error = %s", err)
+	}
+	// persisted revocations are listed until their certificate expires
+	err = issuer.AddRevocations([]Revocation{
+		{Serial: big.NewInt(1001), RevokedAt: revokedAt, Reason: 5, NotAfter: time.Now().Add(time.Hour)},
+		{Serial: big.NewInt(1002), RevokedAt: revokedAt, Reason: 1, NotAfter: time.Now().Add(-time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("AddRevocations() error = %s", err)
+	}
+
+	crl := parseTestCRL(t, issuer, ca)
+	reasons := crlReasons(crl)
+	want := map[string]int{
+		compromised.SerialNumber.String(): 1,
+		superseded.SerialNumber.String():  4,
+		"1001":                            5,
+	}
+	if len(reasons) != len(want) {
+		t.Errorf("CRL lists %d entries, want %d: %v", len(reasons), len(want), reasons)
+	}
+	for serial, reason := range want {
+		if got, ok := reasons[serial]; !ok || got != reason {
+			t.Errorf("CRL entry of serial %s has reason %d (listed %v), want %d", serial, got, ok, reason)
+		}
+	}
+	if _, ok := reasons[valid.SerialNumber.String()]; ok {
+		t.Errorf("CRL lists a certificate that has not been revoked")
+	}
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(compromised.SerialNumber) == 0 && !entry.RevocationTime.Equal(revokedAt) {
+			t.Errorf("CRL entry has revocation time %s, want %s", entry.RevocationTime, revokedAt)
+		}
+	}
+	if got, want := crl.NextUpdate.Sub(crl.ThisUpdate), 2*DefaultCRLUpdateInterval; got != want {
+		t.Errorf("CRL is valid for %s, want %s", got, want)
+	}
+
+	if at, reason, revoked := issuer.IsRevoked(compromised.SerialNumber); !revoked || reason != 1 || !at.Equal(revokedAt) {
+		t.Errorf("IsRevoked() = %s, %d, %v, want %s, 1, true", at, reason, revoked, revokedAt)
+	}
+	if _, _, revoked := issuer.IsRevoked(valid.SerialNumber); revoked {
+		t.Errorf("IsRevoked() = true for a certificate that has not been revoked")
+	}
+
+	// revoking again keeps the first revocation and the CRL
+	if err := issuer.Revoke(compromised, time.Now(), 0); err != nil {
+		t.Fatalf("Revoke() error = %s for a revoked certificate", err)
+	}
+	if _, reason, _ := issuer.IsRevoked(compromised.SerialNumber); reason != 1 {
+		t.Errorf("IsRevoked() reason = %d after revoking again, want the first reason 1", reason)
+	}
+	if again := parseTestCRL(t, issuer, ca); again.Number.Cmp(crl.Number) != 0 {
+		t.Errorf("CRL has been regenerated when revoking a revoked certificate")
+	}
+
+	// every new CRL has a greater number
+	if err := issuer.UpdateCRL(); err != nil {
+		t.Fatalf("UpdateCRL() error = %s", err)
+	}
+	if updated := parseTestCRL(t, issuer, ca); updated.Number.Cmp(crl.Number) <= 0 {
+		t.Errorf("CRL number %s did not increase from %s", updated.Number, crl.Number)
+	}
+}
+
+func TestRevokeForeignCertificate(t *testing.T) {
+	issuer := newTestIssuer(t)
+	other := newTestIssuer(t)
+	cert := signTestCert(t, other, newTestKey(t, "ecdsa"), "foreign")
+
+	if err := issuer.Revoke(cert, time.Now(), 1); err == nil {
+		t.Errorf("Revoke() accepted a certificate of another issuer")
+	}
+	if _, _, revoked := issuer.IsRevoked(cert.SerialNumber); revoked {
+		t.Errorf("IsRevoked() = true for a certificate of another issuer")
+	}
+}
+
+func TestRevokeTrustedCACertificate(t *testing.T) {
+	oldCertPEM, oldKeyPEM := newTestCAData(t, "ecdsa", "old-ca")
+	oldIssuer, err := NewTriremeIssuerFromData(oldCertPEM, oldKeyPEM, "")
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+	oldCert := signTestCert(t, oldIssuer, newTestKey(t, "ecdsa"), "old")
+
+	newCertPEM, newKeyPEM := newTestCAData(t, "ecdsa", "new-ca")
+	issuer, err := NewTriremeIssuerFromData(newCertPEM, newKeyPEM, "")
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+	if err := issuer.AddTrustedCA(oldCertPEM); err != nil {
+		t.Fatalf("AddTrustedCA() error = %s", err)
+	}
+	newCert := signTestCert(t, issuer, newTestKey(t, "ecdsa"), "new")
+
+	// the CRL of the signing CA can not revoke the certificate of another CA
+	if err := issuer.Revoke(oldCert, time.Now(), 1); err == nil {
+		t.Errorf("Revoke() accepted a certificate of a trusted CA")
+	}
+	if _, _, revoked := issuer.IsRevoked(oldCert.SerialNumber); revoked {
+		t.Errorf("IsRevoked() = true for a certificate of a trusted CA")
+	}
+	if err := issuer.Revoke(newCert, time.Now(), 1); err != nil {
+		t.Fatalf("Revoke() error = %s", err)
+	}
+	if reasons := crlReasons(parseTestCRL(t, issuer, issuer.currentCA().cert)); len(reasons) != 1 || reasons[newCert.SerialNumber.String()] != 1 {
+		t.Errorf("CRL = %v, want only the certificate of the signing CA", reasons)
+	}
+
+	// a trusted CA that the issuer signed with before a reload keeps its own CRL
+	if err := oldIssuer.Reload(newCertPEM, newKeyPEM, ""); err != nil {
+		t.Fatalf("Reload() error = %s", err)
+	}
+	if err := oldIssuer.AddTrustedCA(oldCertPEM); err != nil {
+		t.Fatalf("AddTrustedCA() error = %s", err)
+	}
+	if err := oldIssuer.Revoke(oldCert, time.Now(), 1); err != nil {
+		t.Errorf("Revoke() error = %s for a certificate of a replaced CA that is also trusted", err)
+	}
+}
+
+func TestCRLPerIssuer(t *testing.T) {
+	keyA := newTestKey(t, "ecdsa")
+	caPEMA, caA := newTestCA(t, keyA, "ca-a")
+	issuerA, err := NewTriremeIssuer(caPEMA, caA, keyA)
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+	keyB := newTestKey(t, "rsa")
+	caPEMB, caB := newTestCA(t, keyB, "ca-b")
+	issuerB, err := NewTriremeIssuer(caPEMB, caB, keyB)
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+
+	certA := signTestCert(t, issuerA, newTestKey(t, "ecdsa"), "a")
+	certB := signTestCert(t, issuerB, newTestKey(t, "ecdsa"), "b")
+	if err := issuerA.Revoke(certA, time.Now(), 1); err != nil {
+		t.Fatalf("Revoke() error = %s", err)
+	}
+	if err := issuerB.Revoke(certB, time.Now(), 3); err != nil {
+		t.Fatalf("Revoke() error = %s", err)
+	}
+
+	crlA := parseTestCRL(t, issuerA, caA)
+	if reasons := crlReasons(crlA); len(reasons) != 1 || reasons[certA.SerialNumber.String()] != 1 {
+		t.Errorf("CRL of issuer A = %v, want only its own revocation", reasons)
+	}
+	crlB := parseTestCRL(t, issuerB, caB)
+	if reasons := crlReasons(crlB); len(reasons) != 1 || reasons[certB.SerialNumber.String()] != 3 {
+		t.Errorf("CRL of issuer B = %v, want only its own revocation", reasons)
+	}
+	if crlB.SignatureAlgorithm != x509.SHA256WithRSA {
+		t.Errorf("CRL of the RSA issuer is signed with %s, want %s", crlB.SignatureAlgorithm, x509.SHA256WithRSA)
+	}
+}
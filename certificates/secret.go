@@ -0,0 +1,81 @@
+package certificates
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ReadCASecret returns the PEM encoded CA bundle and key from the `tls.crt` and `tls.key` keys of the Secret,
+// and the password from its `passKey` key if it is set, without trailing newlines. Both PEM keys must hold PEM data.
+func ReadCASecret(secret *corev1.Secret, passKey string) ([]byte, []byte, string, error) {
+	caCertPEM, err := secretPEM(secret, corev1.TLSCertKey)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	caKeyPEM, err := secretPEM(secret, corev1.TLSPrivateKeyKey)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	var keyPass string
+	if pass, ok := secret.Data[passKey]; ok && passKey != "" {
+		keyPass = strings.TrimRight(string(pass), "\r\n")
+	}
+	return caCertPEM, caKeyPEM, keyPass, nil
+}
+
+// secretPEM returns the PEM data of the key of the Secret
+func secretPEM(secret *corev1.Secret, key string) ([]byte, error) {
+	data, ok := secret.Data[key]
+	if !ok || len(data) == 0 {
+		return nil, fmt.Errorf("Secret %s/%s has no key '%s'", secret.Namespace, secret.Name, key)
+	}
+	if !bytes.Contains(data, []byte("-----BEGIN ")) {
+		return nil, fmt.Errorf("key '%s' of Secret %s/%s does not hold PEM data", key, secret.Namespace, secret.Name)
+	}
+	return data, nil
+}
+
+// WatchSecret reloads the signing CA from the Secret `namespace/name` whenever it changes, until stopCh closes.
+// Errors are logged and the current signing CA stays active, also when the Secret gets deleted.
+func (i *TriremeIssuer) WatchSecret(kubeClient kubernetes.Interface, namespace, name, passKey string, stopCh <-chan struct{}) {
+	listWatch := cache.NewListWatchFromClient(
+		kubeClient.CoreV1().RESTClient(),
+		"secrets",
+		namespace,
+		fields.OneTermEqualSelector("metadata.name", name),
+	)
+
+	load := func(obj interface{}) {
+		secret, ok := obj.(*corev1.Secret)
+		if !ok {
+			return
+		}
+		caCertPEM, caKeyPEM, keyPass, err := ReadCASecret(secret, passKey)
+		if err == nil {
+			err = i.Reload(caCertPEM, caKeyPEM, keyPass)
+		}
+		if err != nil {
+			zap.L().Error("Error reloading signing CA, keeping the current one", zap.String("secret", namespace+"/"+name), zap.Error(err))
+		}
+	}
+
+	_, informer := cache.NewInformer(listWatch, &corev1.Secret{}, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc: load,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			load(newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			zap.L().Warn("Signing CA Secret deleted, keeping the current signing CA", zap.String("secret", namespace+"/"+name))
+		},
+	})
+	informer.Run(stopCh)
+}
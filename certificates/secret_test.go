@@ -0,0 +1,118 @@
+package certificates
+
+import (
+	"bytes"
+	"encoding/pem"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReadCASecret(t *testing.T) {
+	caKey := newTestKey(t, "ecdsa")
+	caPEM, _ := newTestCA(t, caKey, "test-ca")
+	keyBlock, err := keyToPEM(caKey)
+	if err != nil {
+		t.Fatalf("failed to encode CA key: %s", err)
+	}
+	keyPEM := pem.EncodeToMemory(keyBlock)
+	encryptedKeyPEM := encryptTestKey(t, caKey, "pkcs8", "correct horse")
+	_, otherKeyPEM := newTestCAData(t, "ecdsa", "other-ca")
+
+	tests := []struct {
+		name    string
+		data    map[string][]byte
+		passKey string
+		// wantErr is set if the Secret can not be read, wantIssuerErr if no issuer can be created from it
+		wantErr       bool
+		wantPass      string
+		wantIssuerErr bool
+	}{
+		{
+			name:    "unencrypted key",
+			data:    map[string][]byte{corev1.TLSCertKey: caPEM, corev1.TLSPrivateKeyKey: keyPEM},
+			passKey: "passphrase",
+		},
+		{
+			name:    "missing certificate",
+			data:    map[string][]byte{corev1.TLSPrivateKeyKey: keyPEM},
+			wantErr: true,
+		},
+		{
+			name:    "empty certificate",
+			data:    map[string][]byte{corev1.TLSCertKey: {}, corev1.TLSPrivateKeyKey: keyPEM},
+			wantErr: true,
+		},
+		{
+			name:    "missing key",
+			data:    map[string][]byte{corev1.TLSCertKey: caPEM},
+			wantErr: true,
+		},
+		{
+			name:    "key without PEM data",
+			data:    map[string][]byte{corev1.TLSCertKey: caPEM, corev1.TLSPrivateKeyKey: []byte("not a key")},
+			wantErr: true,
+		},
+		{
+			name:          "key of another CA",
+			data:          map[string][]byte{corev1.TLSCertKey: caPEM, corev1.TLSPrivateKeyKey: otherKeyPEM},
+			wantIssuerErr: true,
+		},
+		{
+			name:     "encrypted key with passphrase",
+			data:     map[string][]byte{corev1.TLSCertKey: caPEM, corev1.TLSPrivateKeyKey: encryptedKeyPEM, "passphrase": []byte("correct horse\r\n")},
+			passKey:  "passphrase",
+			wantPass: "correct horse",
+		},
+		{
+			name:          "encrypted key without passphrase key",
+			data:          map[string][]byte{corev1.TLSCertKey: caPEM, corev1.TLSPrivateKeyKey: encryptedKeyPEM},
+			passKey:       "passphrase",
+			wantIssuerErr: true,
+		},
+		{
+			name:          "encrypted key with passphrase under another key",
+			data:          map[string][]byte{corev1.TLSCertKey: caPEM, corev1.TLSPrivateKeyKey: encryptedKeyPEM, "passphrase": []byte("correct horse")},
+			passKey:       "password",
+			wantIssuerErr: true,
+		},
+		{
+			name:          "encrypted key without configured passphrase key",
+			data:          map[string][]byte{corev1.TLSCertKey: caPEM, corev1.TLSPrivateKeyKey: encryptedKeyPEM, "passphrase": []byte("correct horse")},
+			wantIssuerErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ca"},
+				Data:       tt.data,
+			}
+			certPEM, gotKeyPEM, keyPass, err := ReadCASecret(secret, tt.passKey)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ReadCASecret() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadCASecret() error = %s", err)
+			}
+			if !bytes.Equal(certPEM, tt.data[corev1.TLSCertKey]) || !bytes.Equal(gotKeyPEM, tt.data[corev1.TLSPrivateKeyKey]) {
+				t.Errorf("ReadCASecret() did not return the certificate and the key of the Secret")
+			}
+			if keyPass != tt.wantPass {
+				t.Errorf("ReadCASecret() passphrase = %q, want %q", keyPass, tt.wantPass)
+			}
+
+			_, err = NewTriremeIssuerFromData(certPEM, gotKeyPEM, keyPass)
+			if tt.wantIssuerErr && err == nil {
+				t.Errorf("NewTriremeIssuerFromData() succeeded with the Secret, want an error")
+			}
+			if !tt.wantIssuerErr && err != nil {
+				t.Errorf("NewTriremeIssuerFromData() error = %s", err)
+			}
+		})
+	}
+}
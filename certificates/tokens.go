@@ -0,0 +1,223 @@
+package certificates
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.aporeto.io/trireme-lib/controller/pkg/pkiverifier"
+	"go.uber.org/zap"
+
+	certificatev1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+)
+
+// TokenOptions configure the tags and the validity of the tokens issued next to the certificates.
+// Tags are `key=value` strings.
+type TokenOptions struct {
+	// Validity of the tokens, which defaults to and is capped by the validity of the certificate
+	Validity time.Duration
+	// StaticTags are added to every token, and take precedence over the tags of the requests
+	StaticTags []string
+	// LabelKeys and AnnotationKeys are patterns of the label and annotation keys of a Certificate to copy
+	LabelKeys      []string
+	AnnotationKeys []string
+	// SubjectFields and SANs are the subject fields and the types of SANs of the certificate to copy
+	SubjectFields []string
+	SANs          []string
+	// AllowedKeys and DeniedKeys are patterns that limit the tag keys that requests can set
+	AllowedKeys []string
+	DeniedKeys  []string
+}
+
+// TokenAttributes are the attributes of a Certificate request that tags can be copied from
+type TokenAttributes struct {
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// subjectFields return the values of the subject fields that can be copied into tokens
+var subjectFields = map[string]func(cert *x509.Certificate) []string{
+	"commonName": func(cert *x509.Certificate) []string {
+		if cert.Subject.CommonName == "" {
+			return nil
+		}
+		return []string{cert.Subject.CommonName}
+	},
+	"organization":       func(cert *x509.Certificate) []string { return cert.Subject.Organization },
+	"organizationalUnit": func(cert *x509.Certificate) []string { return cert.Subject.OrganizationalUnit },
+	"country":            func(cert *x509.Certificate) []string { return cert.Subject.Country },
+	"province":           func(cert *x509.Certificate) []string { return cert.Subject.Province },
+	"locality":           func(cert *x509.Certificate) []string { return cert.Subject.Locality },
+	"serialNumber": func(cert *x509.Certificate) []string {
+		if cert.Subject.SerialNumber == "" {
+			return nil
+		}
+		return []string{cert.Subject.SerialNumber}
+	},
+}
+
+// sanTypes return the values of the types of SANs that can be copied into tokens
+var sanTypes = map[string]func(cert *x509.Certificate) []string{
+	"dns":   func(cert *x509.Certificate) []string { return cert.DNSNames },
+	"email": func(cert *x509.Certificate) []string { return cert.EmailAddresses },
+	"ip": func(cert *x509.Certificate) []string {
+		values := make([]string, 0, len(cert.IPAddresses))
+		for _, ip := range cert.IPAddresses {
+			values = append(values, ip.String())
+		}
+		return values
+	},
+	"uri": func(cert *x509.Certificate) []string {
+		values := make([]string, 0, len(cert.URIs))
+		for _, uri := range cert.URIs {
+			values = append(values, uri.String())
+		}
+		return values
+	},
+}
+
+// NewTokenOptions returns the token options of the token settings of an Issuer
+func NewTokenOptions(spec *certificatev1alpha2.IssuerToken) *TokenOptions {
+	options := &TokenOptions{
+		StaticTags:     spec.StaticTags,
+		LabelKeys:      spec.LabelKeys,
+		AnnotationKeys: spec.AnnotationKeys,
+		SubjectFields:  spec.SubjectFields,
+		SANs:           spec.SANs,
+		AllowedKeys:    spec.AllowedKeys,
+		DeniedKeys:     spec.DeniedKeys,
+	}
+	if spec.Validity != nil {
+		options.Validity = spec.Validity.Duration
+	}
+	return options
+}
+
+// validate returns an error if the options are invalid
+func (o *TokenOptions) validate() error {
+	if o.Validity < 0 {
+		return fmt.Errorf("invalid token validity %s", o.Validity)
+	}
+	for _, tag := range o.StaticTags {
+		if key, _, ok := splitTag(tag); !ok || key == "" {
+			return fmt.Errorf("invalid static tag '%s': must be key=value", tag)
+		}
+	}
+	for _, field := range o.SubjectFields {
+		if _, ok := subjectFields[field]; !ok {
+			return fmt.Errorf("unknown subject field '%s'", field)
+		}
+	}
+	for _, san := range o.SANs {
+		if _, ok := sanTypes[san]; !ok {
+			return fmt.Errorf("unknown SAN type '%s'", san)
+		}
+	}
+	return nil
+}
+
+// tags returns the tags of the token of the certificate: the static tags, followed by the sorted tags of the
+// request that are allowed and do not override a static tag
+func (o *TokenOptions) tags(cert *x509.Certificate, attributes *TokenAttributes) []string {
+	tags := []string{}
+	static := map[string]bool{}
+	for _, tag := range o.StaticTags {
+		key, _, _ := splitTag(tag)
+		static[key] = true
+		tags = append(tags, tag)
+	}
+
+	var requested []string
+	add := func(key, value string) {
+		if static[key] {
+			return
+		}
+		if !o.allowsKey(key) {
+			zap.L().Debug("Dropping token tag that is not allowed", zap.String("key", key))
+			return
+		}
+		requested = append(requested, key+"="+value)
+	}
+
+	if attributes != nil {
+		for key, value := range attributes.Labels {
+			if MatchesAny(o.LabelKeys, key) {
+				add(key, value)
+			}
+		}
+		for key, value := range attributes.Annotations {
+			if MatchesAny(o.AnnotationKeys, key) {
+				add(key, value)
+			}
+		}
+	}
+	for _, field := range o.SubjectFields {
+		for _, value := range subjectFields[field](cert) {
+			add(field, value)
+		}
+	}
+	for _, san := range o.SANs {
+		for _, value := range sanTypes[san](cert) {
+			add(san, value)
+		}
+	}
+
+	// labels and annotations are maps, so that their tags get sorted to issue the same token for the same request
+	sort.Strings(requested)
+	return append(tags, requested...)
+}
+
+// allowsKey returns true if requests can set a tag with the key
+func (o *TokenOptions) allowsKey(key string) bool {
+	if len(o.AllowedKeys) > 0 && !MatchesAny(o.AllowedKeys, key) {
+		return false
+	}
+	return !MatchesAny(o.DeniedKeys, key)
+}
+
+// SupportsToken returns true if tokens can be issued for the certificate. Tokens carry the public key of the
+// certificate as an ECDSA point, so that certificates with other keys are issued without a token.
+func SupportsToken(cert *x509.Certificate) bool {
+	_, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	return ok
+}
+
+// issue creates the token of the certificate with the token issuer, and returns it with its expiry
+func (o *TokenOptions) issue(tokenIssuer pkiverifier.PKITokenIssuer, cert *x509.Certificate, attributes *TokenAttributes) ([]byte, time.Time, error) {
+	if !SupportsToken(cert) {
+		return nil, time.Time{}, fmt.Errorf("tokens can only be issued for ECDSA keys, not for %T keys", cert.PublicKey)
+	}
+	tokenCert := o.tokenCertificate(cert)
+	token, err := tokenIssuer.CreateTokenFromCertificate(tokenCert, o.tags(cert, attributes))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return token, tokenCert.NotAfter, nil
+}
+
+// tokenCertificate returns the certificate that the token gets created from. The token expires with the
+// certificate, so that a shorter validity is applied to a copy of the certificate.
+func (o *TokenOptions) tokenCertificate(cert *x509.Certificate) *x509.Certificate {
+	if o.Validity <= 0 {
+		return cert
+	}
+	notAfter := time.Now().Add(o.Validity)
+	if !notAfter.Before(cert.NotAfter) {
+		return cert
+	}
+	tokenCert := *cert
+	tokenCert.NotAfter = notAfter
+	return &tokenCert
+}
+
+// splitTag splits a `key=value` tag
+func splitTag(tag string) (string, string, bool) {
+	parts := strings.SplitN(tag, "=", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
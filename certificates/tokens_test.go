@@ -0,0 +1,321 @@
+package certificates
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	certificatev1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+)
+
+func TestSupportsToken(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	tests := []struct {
+		keyType string
+		want    bool
+	}{
+		{keyType: "ecdsa", want: true},
+		{keyType: "rsa", want: false},
+		{keyType: "ed25519", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.keyType, func(t *testing.T) {
+			cert := signTestCert(t, issuer, newTestKey(t, tt.keyType), "workload")
+			if got := SupportsToken(cert); got != tt.want {
+				t.Errorf("SupportsToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIssueTokenKeyTypes(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	tests := []struct {
+		keyType string
+		wantErr bool
+	}{
+		{keyType: "ecdsa", wantErr: false},
+		{keyType: "rsa", wantErr: true},
+		{keyType: "ed25519", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.keyType, func(t *testing.T) {
+			cert := signTestCert(t, issuer, newTestKey(t, tt.keyType), "workload")
+
+			token, notAfter, err := issuer.IssueToken(cert, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("IssueToken() succeeded for a %s key", tt.keyType)
+				}
+				if token != nil {
+					t.Errorf("IssueToken() returned a token with an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("IssueToken() error = %s", err)
+			}
+			if len(token) == 0 {
+				t.Errorf("IssueToken() returned an empty token")
+			}
+			if !notAfter.Equal(cert.NotAfter) {
+				t.Errorf("IssueToken() expiry = %s, want the expiry of the certificate %s", notAfter, cert.NotAfter)
+			}
+		})
+	}
+}
+
+func TestIssueTokenWithoutTokenSigningKey(t *testing.T) {
+	key := newTestKey(t, "ed25519")
+	caPEM, caCert := newTestCA(t, key, "test-ca")
+	issuer, err := NewTriremeIssuer(caPEM, caCert, key)
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+	if err := issuer.ValidateTokenIssuer(); err == nil {
+		t.Errorf("ValidateTokenIssuer() succeeded for an Ed25519 CA without a token signing key")
+	}
+
+	cert := signTestCert(t, issuer, newTestKey(t, "ecdsa"), "workload")
+	if _, _, err := issuer.IssueToken(cert, nil); err == nil {
+		t.Errorf("IssueToken() succeeded without a key that can sign tokens")
+	}
+
+	if err := issuer.SetTokenSigningKey(newTestKey(t, "ecdsa")); err != nil {
+		t.Fatalf("SetTokenSigningKey() error = %s", err)
+	}
+	if err := issuer.ValidateTokenIssuer(); err != nil {
+		t.Errorf("ValidateTokenIssuer() error = %s", err)
+	}
+	if token, _, err := issuer.IssueToken(cert, nil); err != nil || len(token) == 0 {
+		t.Errorf("IssueToken() with a token signing key = %d bytes, %v", len(token), err)
+	}
+}
+
+// recordingTokenIssuer records the certificate and the tags that tokens get created from
+type recordingTokenIssuer struct {
+	cert *x509.Certificate
+	tags []string
+}
+
+func (r *recordingTokenIssuer) CreateTokenFromCertificate(cert *x509.Certificate, tags []string) ([]byte, error) {
+	r.cert = cert
+	r.tags = tags
+	return []byte("token"), nil
+}
+
+// newTokenTestCert returns a certificate for a new ECDSA key with subject fields and SANs to copy into tokens
+func newTokenTestCert(t *testing.T) *x509.Certificate {
+	t.Helper()
+
+	uri, err := url.Parse("spiffe://example.com/web")
+	if err != nil {
+		t.Fatalf("failed to parse URI: %s", err)
+	}
+	return &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:         "web",
+			Organization:       []string{"acme"},
+			OrganizationalUnit: []string{"frontend", "edge"},
+		},
+		DNSNames:       []string{"web.example.com"},
+		EmailAddresses: []string{"web@example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+		URIs:           []*url.URL{uri},
+		PublicKey:      newTestKey(t, "ecdsa").Public(),
+		NotBefore:      time.Now().Add(-time.Minute),
+		NotAfter:       time.Now().Add(24 * time.Hour),
+	}
+}
+
+func TestTokenTags(t *testing.T) {
+	attributes := &TokenAttributes{
+		Labels:      map[string]string{"app": "web", "team": "blue", "env": "prod", "internal/owner": "alice"},
+		Annotations: map[string]string{"example.com/tier": "gold", "other": "ignored"},
+	}
+
+	tests := []struct {
+		name    string
+		options *TokenOptions
+		want    []string
+	}{
+		{
+			name:    "no tags",
+			options: &TokenOptions{},
+			want:    []string{},
+		},
+		{
+			name:    "static tags",
+			options: &TokenOptions{StaticTags: []string{"cluster=prod", "zone=a"}},
+			want:    []string{"cluster=prod", "zone=a"},
+		},
+		{
+			name:    "label and annotation keys, sorted after the static tags",
+			options: &TokenOptions{StaticTags: []string{"zone=a"}, LabelKeys: []string{"app", "team"}, AnnotationKeys: []string{"example.com/*"}},
+			want:    []string{"zone=a", "app=web", "example.com/tier=gold", "team=blue"},
+		},
+		{
+			name:    "static tags take precedence",
+			options: &TokenOptions{StaticTags: []string{"env=staging"}, LabelKeys: []string{"*"}},
+			want:    []string{"env=staging", "app=web", "internal/owner=alice", "team=blue"},
+		},
+		{
+			name:    "allowed and denied keys",
+			options: &TokenOptions{LabelKeys: []string{"*"}, AllowedKeys: []string{"app", "env", "internal/*"}, DeniedKeys: []string{"internal/*"}},
+			want:    []string{"app=web", "env=prod"},
+		},
+		{
+			name:    "denied keys do not apply to static tags",
+			options: &TokenOptions{StaticTags: []string{"internal/cluster=a"}, DeniedKeys: []string{"internal/*"}},
+			want:    []string{"internal/cluster=a"},
+		},
+		{
+			name:    "subject fields",
+			options: &TokenOptions{SubjectFields: []string{"commonName", "organizationalUnit", "country"}},
+			want:    []string{"commonName=web", "organizationalUnit=edge", "organizationalUnit=frontend"},
+		},
+		{
+			name:    "SANs",
+			options: &TokenOptions{SANs: []string{"dns", "email", "ip", "uri"}},
+			want:    []string{"dns=web.example.com", "email=web@example.com", "ip=10.0.0.1", "uri=spiffe://example.com/web"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.options.validate(); err != nil {
+				t.Fatalf("validate() error = %s", err)
+			}
+			tokenIssuer := &recordingTokenIssuer{}
+			if _, _, err := tt.options.issue(tokenIssuer, newTokenTestCert(t), attributes); err != nil {
+				t.Fatalf("issue() error = %s", err)
+			}
+			if !reflect.DeepEqual(tokenIssuer.tags, tt.want) {
+				t.Errorf("tags = %v, want %v", tokenIssuer.tags, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenValidity(t *testing.T) {
+	cert := newTokenTestCert(t)
+	certNotAfter := cert.NotAfter
+
+	tests := []struct {
+		name     string
+		validity time.Duration
+		capped   bool
+	}{
+		{name: "certificate validity", validity: 0, capped: false},
+		{name: "shorter validity", validity: time.Hour, capped: true},
+		{name: "longer validity is capped by the certificate", validity: 48 * time.Hour, capped: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenIssuer := &recordingTokenIssuer{}
+			before := time.Now()
+			_, notAfter, err := (&TokenOptions{Validity: tt.validity}).issue(tokenIssuer, cert, nil)
+			if err != nil {
+				t.Fatalf("issue() error = %s", err)
+			}
+			if !notAfter.Equal(tokenIssuer.cert.NotAfter) {
+				t.Errorf("issue() expiry %s differs from the expiry of the token certificate %s", notAfter, tokenIssuer.cert.NotAfter)
+			}
+			if tt.capped {
+				if notAfter.Before(before.Add(tt.validity)) || notAfter.After(time.Now().Add(tt.validity)) {
+					t.Errorf("issue() expiry = %s, want %s from now", notAfter, tt.validity)
+				}
+				if tokenIssuer.cert == cert {
+					t.Errorf("token has been created from the certificate instead of a copy with a shorter validity")
+				}
+			} else if !notAfter.Equal(certNotAfter) {
+				t.Errorf("issue() expiry = %s, want the expiry of the certificate %s", notAfter, certNotAfter)
+			}
+			if !cert.NotAfter.Equal(certNotAfter) {
+				t.Errorf("issue() modified the expiry of the certificate")
+			}
+		})
+	}
+}
+
+func TestTokenOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *TokenOptions
+		wantErr bool
+	}{
+		{name: "valid", options: &TokenOptions{Validity: time.Hour, StaticTags: []string{"a=b", "c="}, SubjectFields: []string{"commonName"}, SANs: []string{"dns"}}},
+		{name: "negative validity", options: &TokenOptions{Validity: -time.Hour}, wantErr: true},
+		{name: "static tag without value", options: &TokenOptions{StaticTags: []string{"a"}}, wantErr: true},
+		{name: "static tag without key", options: &TokenOptions{StaticTags: []string{"=b"}}, wantErr: true},
+		{name: "unknown subject field", options: &TokenOptions{SubjectFields: []string{"title"}}, wantErr: true},
+		{name: "unknown SAN type", options: &TokenOptions{SANs: []string{"otherName"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.options.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	issuer := newTestIssuer(t)
+	if err := issuer.SetTokenOptions(&TokenOptions{StaticTags: []string{"invalid"}}); err == nil {
+		t.Errorf("SetTokenOptions() accepted invalid options")
+	}
+}
+
+func TestNewTokenOptions(t *testing.T) {
+	spec := &certificatev1alpha2.IssuerToken{
+		Validity:       &metav1.Duration{Duration: time.Hour},
+		StaticTags:     []string{"cluster=prod"},
+		LabelKeys:      []string{"app"},
+		AnnotationKeys: []string{"example.com/*"},
+		SubjectFields:  []string{"commonName"},
+		SANs:           []string{"dns"},
+		AllowedKeys:    []string{"*"},
+		DeniedKeys:     []string{"internal/*"},
+	}
+	want := &TokenOptions{
+		Validity:       time.Hour,
+		StaticTags:     spec.StaticTags,
+		LabelKeys:      spec.LabelKeys,
+		AnnotationKeys: spec.AnnotationKeys,
+		SubjectFields:  spec.SubjectFields,
+		SANs:           spec.SANs,
+		AllowedKeys:    spec.AllowedKeys,
+		DeniedKeys:     spec.DeniedKeys,
+	}
+	if got := NewTokenOptions(spec); !reflect.DeepEqual(got, want) {
+		t.Errorf("NewTokenOptions() = %+v, want %+v", got, want)
+	}
+	if got := NewTokenOptions(&certificatev1alpha2.IssuerToken{}); got.Validity != 0 {
+		t.Errorf("NewTokenOptions() validity = %s without a validity, want 0", got.Validity)
+	}
+}
+
+func TestIssueTokenValidity(t *testing.T) {
+	issuer := newTestIssuer(t)
+	if err := issuer.SetTokenOptions(&TokenOptions{Validity: time.Hour}); err != nil {
+		t.Fatalf("SetTokenOptions() error = %s", err)
+	}
+
+	cert := signTestCert(t, issuer, newTestKey(t, "ecdsa"), "workload")
+	token, notAfter, err := issuer.IssueToken(cert, nil)
+	if err != nil {
+		t.Fatalf("IssueToken() error = %s", err)
+	}
+	if len(token) == 0 {
+		t.Errorf("IssueToken() returned an empty token")
+	}
+	if notAfter.After(time.Now().Add(time.Hour)) || !notAfter.Before(cert.NotAfter) {
+		t.Errorf("IssueToken() expiry = %s, want an hour from now", notAfter)
+	}
+}
@@ -0,0 +1,145 @@
+package certificates
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	certificatev1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+	"github.com/CodingJzy/trireme-csr/policy"
+)
+
+// DefaultCertificateDuration is the default validity of issued certificates.
+const DefaultCertificateDuration = 365 * 24 * time.Hour
+
+// SignOptions are the properties requested for an issued certificate. The zero value requests the defaults.
+type SignOptions struct {
+	Duration time.Duration
+	Usages   []certificatev1alpha2.KeyUsage
+	IsCA     bool
+}
+
+// NewSignOptions returns the options requested in the spec of a Certificate
+func NewSignOptions(spec *certificatev1alpha2.CertificateSpec) *SignOptions {
+	options := &SignOptions{
+		Usages: spec.Usages,
+		IsCA:   spec.IsCA,
+	}
+	if spec.Duration != nil {
+		options.Duration = spec.Duration.Duration
+	}
+	return options
+}
+
+var keyUsages = map[certificatev1alpha2.KeyUsage]x509.KeyUsage{
+	certificatev1alpha2.UsageDigitalSignature:  x509.KeyUsageDigitalSignature,
+	certificatev1alpha2.UsageContentCommitment: x509.KeyUsageContentCommitment,
+	certificatev1alpha2.UsageKeyEncipherment:   x509.KeyUsageKeyEncipherment,
+	certificatev1alpha2.UsageDataEncipherment:  x509.KeyUsageDataEncipherment,
+	certificatev1alpha2.UsageKeyAgreement:      x509.KeyUsageKeyAgreement,
+	certificatev1alpha2.UsageCertSign:          x509.KeyUsageCertSign,
+	certificatev1alpha2.UsageCRLSign:           x509.KeyUsageCRLSign,
+	certificatev1alpha2.UsageEncipherOnly:      x509.KeyUsageEncipherOnly,
+	certificatev1alpha2.UsageDecipherOnly:      x509.KeyUsageDecipherOnly,
+}
+
+var extKeyUsages = map[certificatev1alpha2.KeyUsage]x509.ExtKeyUsage{
+	certificatev1alpha2.UsageServerAuth:      x509.ExtKeyUsageServerAuth,
+	certificatev1alpha2.UsageClientAuth:      x509.ExtKeyUsageClientAuth,
+	certificatev1alpha2.UsageCodeSigning:     x509.ExtKeyUsageCodeSigning,
+	certificatev1alpha2.UsageEmailProtection: x509.ExtKeyUsageEmailProtection,
+	certificatev1alpha2.UsageTimestamping:    x509.ExtKeyUsageTimeStamping,
+	certificatev1alpha2.UsageOCSPSigning:     x509.ExtKeyUsageOCSPSigning,
+}
+
+// defaultUsages returns the usages of certificates that do not request any:
+// client and server certificates, or CA certificates if `isCA` is set
+func defaultUsages(csr *x509.CertificateRequest, isCA bool) []certificatev1alpha2.KeyUsage {
+	if isCA {
+		return []certificatev1alpha2.KeyUsage{
+			certificatev1alpha2.UsageDigitalSignature,
+			certificatev1alpha2.UsageCertSign,
+			certificatev1alpha2.UsageCRLSign,
+		}
+	}
+
+	usages := []certificatev1alpha2.KeyUsage{certificatev1alpha2.UsageDigitalSignature}
+	// KeyEncipherment only applies to RSA keys, where the key is used to encrypt the TLS key exchange
+	if _, ok := csr.PublicKey.(*rsa.PublicKey); ok {
+		usages = append(usages, certificatev1alpha2.UsageKeyEncipherment)
+	}
+	return append(usages, certificatev1alpha2.UsageServerAuth, certificatev1alpha2.UsageClientAuth)
+}
+
+// parseUsages converts the usages to the key usage bits and the extended key usages of a certificate
+func parseUsages(usages []certificatev1alpha2.KeyUsage) (x509.KeyUsage, []x509.ExtKeyUsage, error) {
+	var keyUsage x509.KeyUsage
+	var extKeyUsage []x509.ExtKeyUsage
+
+	for _, usage := range usages {
+		if bit, ok := keyUsages[usage]; ok {
+			keyUsage |= bit
+			continue
+		}
+		if ext, ok := extKeyUsages[usage]; ok {
+			extKeyUsage = append(extKeyUsage, ext)
+			continue
+		}
+		return 0, nil, fmt.Errorf("unsupported usage '%s'", usage)
+	}
+	return keyUsage, extKeyUsage, nil
+}
+
+// CertificateUsages returns the usages that have been granted to the certificate
+func CertificateUsages(cert *x509.Certificate) []certificatev1alpha2.KeyUsage {
+	var usages []certificatev1alpha2.KeyUsage
+
+	// iterate over the ordered constants, so that the result is stable
+	for _, usage := range []certificatev1alpha2.KeyUsage{
+		certificatev1alpha2.UsageDigitalSignature,
+		certificatev1alpha2.UsageContentCommitment,
+		certificatev1alpha2.UsageKeyEncipherment,
+		certificatev1alpha2.UsageDataEncipherment,
+		certificatev1alpha2.UsageKeyAgreement,
+		certificatev1alpha2.UsageCertSign,
+		certificatev1alpha2.UsageCRLSign,
+		certificatev1alpha2.UsageEncipherOnly,
+		certificatev1alpha2.UsageDecipherOnly,
+	} {
+		if cert.KeyUsage&keyUsages[usage] != 0 {
+			usages = append(usages, usage)
+		}
+	}
+
+	for _, ext := range cert.ExtKeyUsage {
+		for usage, candidate := range extKeyUsages {
+			if candidate == ext {
+				usages = append(usages, usage)
+				break
+			}
+		}
+	}
+	return usages
+}
+
+// validateUsagesWithoutPolicy returns an error for the restricted usages, which can only be allowed by a policy
+func validateUsagesWithoutPolicy(usages []certificatev1alpha2.KeyUsage) error {
+	for _, usage := range usages {
+		for _, restricted := range policy.RestrictedUsages {
+			if string(usage) == restricted {
+				return fmt.Errorf("usage '%s' is not allowed without an issuance policy", usage)
+			}
+		}
+	}
+	return nil
+}
+
+// usageNames returns the usages as plain strings, as they are used by the issuance policy
+func usageNames(usages []certificatev1alpha2.KeyUsage) []string {
+	names := make([]string, 0, len(usages))
+	for _, usage := range usages {
+		names = append(names, string(usage))
+	}
+	return names
+}
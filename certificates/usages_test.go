@@ -0,0 +1,166 @@
+package certificates
+
+import (
+	"testing"
+	"time"
+
+	certificatev1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+	"github.com/CodingJzy/trireme-csr/policy"
+)
+
+func TestValidateRequestRestrictedUsages(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		usages  []certificatev1alpha2.KeyUsage
+		isCA    bool
+		wantErr bool
+	}{
+		{
+			name:   "default usages without policy",
+			usages: nil,
+		},
+		{
+			name:   "client auth without policy",
+			usages: []certificatev1alpha2.KeyUsage{certificatev1alpha2.UsageDigitalSignature, certificatev1alpha2.UsageClientAuth},
+		},
+		{
+			name:    "ocsp signing without policy",
+			usages:  []certificatev1alpha2.KeyUsage{certificatev1alpha2.UsageDigitalSignature, certificatev1alpha2.UsageOCSPSigning},
+			wantErr: true,
+		},
+		{
+			name:    "cert sign without policy",
+			usages:  []certificatev1alpha2.KeyUsage{certificatev1alpha2.UsageCertSign},
+			wantErr: true,
+		},
+		{
+			name:    "crl sign without policy",
+			usages:  []certificatev1alpha2.KeyUsage{certificatev1alpha2.UsageCRLSign},
+			wantErr: true,
+		},
+		{
+			name:    "CA without policy",
+			isCA:    true,
+			wantErr: true,
+		},
+		{
+			name:    "ocsp signing with a policy that allows any usage",
+			policy:  "{}",
+			usages:  []certificatev1alpha2.KeyUsage{certificatev1alpha2.UsageOCSPSigning},
+			wantErr: true,
+		},
+		{
+			name:   "ocsp signing allowed by the policy",
+			policy: "allowedUsages: ['digital signature', 'ocsp signing']",
+			usages: []certificatev1alpha2.KeyUsage{certificatev1alpha2.UsageDigitalSignature, certificatev1alpha2.UsageOCSPSigning},
+		},
+		{
+			name:    "cert sign for a leaf with allowCA",
+			policy:  "allowCA: true",
+			usages:  []certificatev1alpha2.KeyUsage{certificatev1alpha2.UsageCertSign},
+			wantErr: true,
+		},
+		{
+			name:   "CA with allowCA",
+			policy: "allowCA: true",
+			isCA:   true,
+		},
+		{
+			name:    "CA without allowCA",
+			policy:  "allowedUsages: ['digital signature', 'cert sign', 'crl sign']",
+			isCA:    true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := newTestIssuer(t)
+			if tt.policy != "" {
+				engine := policy.NewEngine()
+				if err := engine.Load([]byte(tt.policy)); err != nil {
+					t.Fatalf("failed to load policy: %s", err)
+				}
+				issuer.SetPolicy(engine)
+			}
+
+			csr := newTestCSR(t, newTestKey(t, "ecdsa"), "workload")
+			err := issuer.ValidateRequest(csr, &SignOptions{Usages: tt.usages, IsCA: tt.isCA})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignGrantedUsagesAndDuration(t *testing.T) {
+	issuer := newTestIssuer(t)
+	if err := issuer.SetDurations(time.Hour, 2*time.Hour); err != nil {
+		t.Fatalf("SetDurations() error = %s", err)
+	}
+	caNotAfter := issuer.currentCA().cert.NotAfter
+
+	tests := []struct {
+		name       string
+		options    *SignOptions
+		wantUsages []certificatev1alpha2.KeyUsage
+		wantMax    time.Duration
+	}{
+		{
+			name:       "defaults",
+			options:    &SignOptions{},
+			wantUsages: []certificatev1alpha2.KeyUsage{certificatev1alpha2.UsageDigitalSignature, certificatev1alpha2.UsageServerAuth, certificatev1alpha2.UsageClientAuth},
+			wantMax:    time.Hour,
+		},
+		{
+			name:       "client only, clamped to the maximum duration",
+			options:    &SignOptions{Duration: 24 * time.Hour, Usages: []certificatev1alpha2.KeyUsage{certificatev1alpha2.UsageDigitalSignature, certificatev1alpha2.UsageClientAuth}},
+			wantUsages: []certificatev1alpha2.KeyUsage{certificatev1alpha2.UsageDigitalSignature, certificatev1alpha2.UsageClientAuth},
+			wantMax:    2 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certPEM, err := issuer.Sign(newTestCSR(t, newTestKey(t, "ecdsa"), "workload"), tt.options)
+			if err != nil {
+				t.Fatalf("Sign() error = %s", err)
+			}
+			cert, err := parseCertificatePEM(certPEM)
+			if err != nil {
+				t.Fatalf("failed to parse certificate: %s", err)
+			}
+
+			usages := CertificateUsages(cert)
+			if len(usages) != len(tt.wantUsages) {
+				t.Fatalf("CertificateUsages() = %v, want %v", usages, tt.wantUsages)
+			}
+			for i := range usages {
+				if usages[i] != tt.wantUsages[i] {
+					t.Errorf("CertificateUsages() = %v, want %v", usages, tt.wantUsages)
+				}
+			}
+			if validity := cert.NotAfter.Sub(cert.NotBefore); validity > tt.wantMax {
+				t.Errorf("validity = %s, want at most %s", validity, tt.wantMax)
+			}
+			if cert.NotAfter.After(caNotAfter) {
+				t.Errorf("certificate expires after the CA")
+			}
+		})
+	}
+
+	// the CA of the test issuer expires within a day
+	if err := issuer.SetDurations(time.Hour, 72*time.Hour); err != nil {
+		t.Fatalf("SetDurations() error = %s", err)
+	}
+	certPEM, err := issuer.Sign(newTestCSR(t, newTestKey(t, "ecdsa"), "workload"), &SignOptions{Duration: 48 * time.Hour})
+	if err != nil {
+		t.Fatalf("Sign() error = %s", err)
+	}
+	cert, err := parseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %s", err)
+	}
+	if !cert.NotAfter.Equal(caNotAfter) {
+		t.Errorf("expiry = %s, want the expiry of the CA %s", cert.NotAfter, caNotAfter)
+	}
+}
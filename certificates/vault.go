@@ -0,0 +1,616 @@
+package certificates
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/CodingJzy/trireme-csr/policy"
+	"go.aporeto.io/trireme-lib/controller/pkg/pkiverifier"
+)
+
+// DefaultVaultPKIMount is the default path of the PKI secrets engine in Vault.
+const DefaultVaultPKIMount = "pki"
+
+// DefaultVaultRefreshInterval is the default interval at which the CA and the role get refreshed from Vault.
+const DefaultVaultRefreshInterval = 5 * time.Minute
+
+// vaultRefreshSkew is how long after its NotBefore a certificate of an unknown CA might still have been issued
+// by a new CA of the mount that the last refresh did not see, as Vault backdates NotBefore and clocks differ.
+const vaultRefreshSkew = 5 * time.Minute
+
+// VaultConfig holds the settings of a VaultIssuer
+type VaultConfig struct {
+	// Address is the URL of the Vault server
+	Address string
+	// Mount is the path of the PKI secrets engine, and Role is the PKI role that signs the certificates
+	Mount string
+	Role  string
+	// Token authenticates to Vault. If empty, the issuer logs in with the Kubernetes auth method as
+	// KubernetesAuthRole instead, with the service account token at ServiceAccountTokenPath.
+	Token                   string
+	KubernetesAuthMount     string
+	KubernetesAuthRole      string
+	ServiceAccountTokenPath string
+	// TLSConfig is used to connect to Vault, the system CAs are trusted if nil
+	TLSConfig *tls.Config
+	Timeout   time.Duration
+}
+
+// VaultIssuer forwards CSRs to the sign endpoint of a role of the Vault PKI secrets engine, so that
+// existing Vault managed CAs can issue the certificates. Requests are validated against the settings of
+// the role first, so that they get rejected with a clear reason instead of failing in Vault.
+// The CRL and the OCSP responses of its certificates are served by Vault.
+type VaultIssuer struct {
+	client   *vaultClient
+	mount    string
+	roleName string
+
+	// ca and role are refreshed from Vault, retiredCAs are the previous CAs of the mount whose
+	// certificates have not expired yet
+	lock        sync.RWMutex
+	ca          *caChain
+	caPEM       []byte
+	retiredCAs  []*caChain
+	role        *vaultRole
+	refreshedAt time.Time
+	// refresh asks the refresher for a refresh before the next interval
+	refresh chan struct{}
+
+	// tokenIssuer signs tokens, as the CA key never leaves Vault
+	tokenIssuer pkiverifier.PKITokenIssuer
+	// tokenOptions configure the tags and the validity of the tokens
+	tokenOptions *TokenOptions
+
+	minRSAKeySize   int
+	defaultDuration time.Duration
+	maxDuration     time.Duration
+
+	policy *policy.Engine
+}
+
+// vaultRole holds the settings of a Vault PKI role that apply to signed CSRs
+type vaultRole struct {
+	TTL                 int64    `json:"ttl"`
+	MaxTTL              int64    `json:"max_ttl"`
+	AllowAnyName        bool     `json:"allow_any_name"`
+	AllowedDomains      []string `json:"allowed_domains"`
+	AllowBareDomains    bool     `json:"allow_bare_domains"`
+	AllowSubdomains     bool     `json:"allow_subdomains"`
+	AllowGlobDomains    bool     `json:"allow_glob_domains"`
+	AllowIPSANs         bool     `json:"allow_ip_sans"`
+	AllowedURISANs      []string `json:"allowed_uri_sans"`
+	UseCSRCommonName    bool     `json:"use_csr_common_name"`
+	KeyType             string   `json:"key_type"`
+	KeyBits             int      `json:"key_bits"`
+	ServerFlag          bool     `json:"server_flag"`
+	ClientFlag          bool     `json:"client_flag"`
+	CodeSigningFlag     bool     `json:"code_signing_flag"`
+	EmailProtectionFlag bool     `json:"email_protection_flag"`
+	KeyUsage            []string `json:"key_usage"`
+	ExtKeyUsage         []string `json:"ext_key_usage"`
+}
+
+// vaultSignResponse is the data returned by the sign endpoint
+type vaultSignResponse struct {
+	Certificate  string `json:"certificate"`
+	SerialNumber string `json:"serial_number"`
+}
+
+// vaultKeyUsages are the names of the key usages in Vault roles
+var vaultKeyUsages = map[x509.KeyUsage]string{
+	x509.KeyUsageDigitalSignature:  "DigitalSignature",
+	x509.KeyUsageContentCommitment: "ContentCommitment",
+	x509.KeyUsageKeyEncipherment:   "KeyEncipherment",
+	x509.KeyUsageDataEncipherment:  "DataEncipherment",
+	x509.KeyUsageKeyAgreement:      "KeyAgreement",
+	x509.KeyUsageCertSign:          "CertSign",
+	x509.KeyUsageCRLSign:           "CRLSign",
+	x509.KeyUsageEncipherOnly:      "EncipherOnly",
+	x509.KeyUsageDecipherOnly:      "DecipherOnly",
+}
+
+// vaultExtKeyUsages are the names of the extended key usages in Vault roles
+var vaultExtKeyUsages = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageServerAuth:      "ServerAuth",
+	x509.ExtKeyUsageClientAuth:      "ClientAuth",
+	x509.ExtKeyUsageCodeSigning:     "CodeSigning",
+	x509.ExtKeyUsageEmailProtection: "EmailProtection",
+	x509.ExtKeyUsageTimeStamping:    "TimeStamping",
+	x509.ExtKeyUsageOCSPSigning:     "OCSPSigning",
+}
+
+// NewVaultIssuer creates an issuer that signs with the role of the Vault PKI secrets engine. The CA and the
+// role are fetched once, so that Vault must be reachable and the role must exist.
+func NewVaultIssuer(config *VaultConfig) (*VaultIssuer, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("no Vault address configured")
+	}
+	if config.Role == "" {
+		return nil, fmt.Errorf("no Vault PKI role configured")
+	}
+	if config.Token == "" && config.KubernetesAuthRole == "" {
+		return nil, fmt.Errorf("no Vault token or Kubernetes auth role configured")
+	}
+	mount := config.Mount
+	if mount == "" {
+		mount = DefaultVaultPKIMount
+	}
+
+	issuer := &VaultIssuer{
+		client:          newVaultClient(config),
+		mount:           strings.Trim(mount, "/"),
+		roleName:        config.Role,
+		tokenOptions:    &TokenOptions{},
+		minRSAKeySize:   DefaultMinRSAKeySize,
+		defaultDuration: DefaultCertificateDuration,
+		maxDuration:     DefaultCertificateDuration,
+		refresh:         make(chan struct{}, 1),
+	}
+	if err := issuer.Refresh(); err != nil {
+		return nil, err
+	}
+	return issuer, nil
+}
+
+// Refresh fetches the CA chain and the role from Vault. The previous CA keeps being trusted until it expires
+// if the mount switched to a new one.
+func (i *VaultIssuer) Refresh() error {
+	bundlePEM, err := i.client.getRaw("/v1/" + i.mount + "/ca_chain")
+	if err != nil {
+		return wrapTemporary(err, fmt.Errorf("failed to get CA chain from Vault: %s", err))
+	}
+	if len(bytes.TrimSpace(bundlePEM)) == 0 {
+		return fmt.Errorf("Vault PKI mount '%s' has no CA", i.mount)
+	}
+	chain, err := parseCAChain(bundlePEM)
+	if err != nil {
+		return fmt.Errorf("invalid CA chain from Vault: %s", err)
+	}
+
+	role := &vaultRole{}
+	if err := i.client.do(http.MethodGet, "/v1/"+i.mount+"/roles/"+i.roleName, nil, role); err != nil {
+		return wrapTemporary(err, fmt.Errorf("failed to get Vault PKI role '%s': %s", i.roleName, err))
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.ca != nil && !i.ca.cert.Equal(chain.cert) {
+		zap.L().Info("Vault signing CA changed", zap.String("subject", chain.cert.Subject.String()))
+		i.retiredCAs = append(i.retiredCAs, i.ca)
+	}
+	now := time.Now()
+	retiredCAs := i.retiredCAs[:0]
+	for _, retired := range i.retiredCAs {
+		if now.Before(retired.cert.NotAfter) && !retired.cert.Equal(chain.cert) {
+			retiredCAs = append(retiredCAs, retired)
+		}
+	}
+	i.retiredCAs = retiredCAs
+
+	i.ca = chain
+	i.caPEM = encodeCertificatePEM(chain.cert)
+	i.role = role
+	i.refreshedAt = time.Now()
+	return nil
+}
+
+// RunRefresher refreshes the CA and the role from Vault every `interval` until stopCh closes, and whenever a
+// certificate of an unknown CA shows up, as Vault might have switched to a new CA since the last refresh.
+// It is the only place that refreshes, so that validations never wait for Vault.
+func (i *VaultIssuer) RunRefresher(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		case <-i.refresh:
+		}
+
+		if err := i.Refresh(); err != nil {
+			zap.L().Error("Error refreshing Vault issuer, keeping the current CA and role", zap.Error(err))
+		}
+	}
+}
+
+// requestRefresh asks the refresher for a refresh without blocking
+func (i *VaultIssuer) requestRefresh() {
+	select {
+	case i.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// current returns the current CA chain and role
+func (i *VaultIssuer) current() (*caChain, *vaultRole) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return i.ca, i.role
+}
+
+// SetDurations sets the default and the maximum validity of issued certificates. The max TTL of the role
+// still applies on top of them.
+func (i *VaultIssuer) SetDurations(defaultDuration, maxDuration time.Duration) error {
+	if defaultDuration <= 0 || maxDuration <= 0 {
+		return fmt.Errorf("certificate durations must be positive")
+	}
+	if defaultDuration > maxDuration {
+		return fmt.Errorf("default certificate duration %s is greater than the maximum of %s", defaultDuration, maxDuration)
+	}
+	i.defaultDuration = defaultDuration
+	i.maxDuration = maxDuration
+	return nil
+}
+
+// SetMinRSAKeySize sets the minimum size in bits of RSA keys in CSRs.
+func (i *VaultIssuer) SetMinRSAKeySize(bits int) error {
+	if bits < DefaultMinRSAKeySize {
+		return fmt.Errorf("minimum RSA key size must be at least %d", DefaultMinRSAKeySize)
+	}
+	i.minRSAKeySize = bits
+	return nil
+}
+
+// SetTokenSigningKey sets the ECDSA key to sign tokens with, which is required as the CA key stays in Vault.
+// Token verifiers must then trust the public key of this key.
+func (i *VaultIssuer) SetTokenSigningKey(key crypto.PrivateKey) error {
+	ecdsaKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return fmt.Errorf("token signing key must be an ECDSA key: %T", key)
+	}
+	i.tokenIssuer = pkiverifier.NewPKIIssuer(ecdsaKey)
+	return nil
+}
+
+// SetTokenOptions sets the tags and the validity of the tokens.
+func (i *VaultIssuer) SetTokenOptions(options *TokenOptions) error {
+	if err := options.validate(); err != nil {
+		return err
+	}
+	i.tokenOptions = options
+	return nil
+}
+
+// ValidateTokenIssuer returns an error if no token signing key has been set.
+func (i *VaultIssuer) ValidateTokenIssuer() error {
+	if i.tokenIssuer == nil {
+		return fmt.Errorf("the signing CA key is kept in Vault, which can not sign tokens: a separate ECDSA token signing key is required")
+	}
+	return nil
+}
+
+// SetPolicy sets the issuance policy engine that every CSR gets validated against, in addition to the role.
+func (i *VaultIssuer) SetPolicy(engine *policy.Engine) {
+	i.policy = engine
+}
+
+// ValidateRequest verifies that the CSR is valid, and is allowed by the Vault role and the issuance policy.
+// CA certificates can not be issued, as the sign endpoint of a role only issues leaf certificates.
+func (i *VaultIssuer) ValidateRequest(csr *x509.CertificateRequest, options *SignOptions) error {
+	if err := csr.CheckSignature(); err != nil {
+		return err
+	}
+
+	if err := validatePublicKey(csr.PublicKey, i.minRSAKeySize); err != nil {
+		return err
+	}
+
+	if options.Duration < 0 {
+		return fmt.Errorf("invalid duration %s", options.Duration)
+	}
+	if options.IsCA {
+		return fmt.Errorf("CA certificates can not be issued by a Vault PKI role")
+	}
+	usages := options.Usages
+	if len(usages) == 0 {
+		usages = defaultUsages(csr, false)
+	}
+	keyUsage, extKeyUsage, err := parseUsages(usages)
+	if err != nil {
+		return err
+	}
+
+	_, role := i.current()
+	if err := role.validate(csr, keyUsage, extKeyUsage); err != nil {
+		return fmt.Errorf("Vault PKI role '%s' does not allow the request: %s", i.roleName, err)
+	}
+
+	if i.policy == nil {
+		return validateUsagesWithoutPolicy(usages)
+	}
+	return i.policy.Validate(csr, usageNames(usages), false, options.Duration)
+}
+
+// validate verifies that the role allows the key, the names and the usages of the CSR
+func (r *vaultRole) validate(csr *x509.CertificateRequest, keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage) error {
+	if err := r.validateKey(csr.PublicKey); err != nil {
+		return err
+	}
+
+	if r.UseCSRCommonName && csr.Subject.CommonName != "" && !r.allowsName(csr.Subject.CommonName) {
+		return fmt.Errorf("common name '%s' is not allowed", csr.Subject.CommonName)
+	}
+	for _, name := range csr.DNSNames {
+		if !r.allowsName(name) {
+			return fmt.Errorf("DNS name '%s' is not allowed", name)
+		}
+	}
+	if len(csr.IPAddresses) > 0 && !r.AllowIPSANs {
+		return fmt.Errorf("IP addresses are not allowed")
+	}
+	for _, uri := range csr.URIs {
+		allowed := false
+		for _, pattern := range r.AllowedURISANs {
+			if globMatch(pattern, uri.String()) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("URI '%s' is not allowed", uri)
+		}
+	}
+
+	for bit, name := range vaultKeyUsages {
+		if keyUsage&bit != 0 && !containsString(r.KeyUsage, name) {
+			return fmt.Errorf("key usage %s is not allowed", name)
+		}
+	}
+	for _, usage := range extKeyUsage {
+		if !r.allowsExtKeyUsage(usage) {
+			return fmt.Errorf("extended key usage %s is not allowed", vaultExtKeyUsages[usage])
+		}
+	}
+	return nil
+}
+
+// validateKey verifies that the public key matches the key type and the key bits of the role
+func (r *vaultRole) validateKey(publicKey crypto.PublicKey) error {
+	switch r.KeyType {
+	case "", "any":
+		return nil
+	case "rsa":
+		key, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("the role only allows RSA keys")
+		}
+		if size := key.N.BitLen(); size < r.KeyBits {
+			return fmt.Errorf("RSA key size %d is smaller than the %d bits of the role", size, r.KeyBits)
+		}
+	case "ec":
+		key, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("the role only allows ECDSA keys")
+		}
+		if size := key.Curve.Params().BitSize; r.KeyBits != 0 && size != r.KeyBits {
+			return fmt.Errorf("ECDSA curve size %d does not match the %d bits of the role", size, r.KeyBits)
+		}
+	case "ed25519":
+		if _, ok := publicKey.(ed25519.PublicKey); !ok {
+			return fmt.Errorf("the role only allows Ed25519 keys")
+		}
+	default:
+		return fmt.Errorf("unsupported key type '%s' of the role", r.KeyType)
+	}
+	return nil
+}
+
+// allowsName returns true if the name is allowed by the allowed domains of the role
+func (r *vaultRole) allowsName(name string) bool {
+	if r.AllowAnyName {
+		return true
+	}
+	for _, domain := range r.AllowedDomains {
+		if r.AllowBareDomains && name == domain {
+			return true
+		}
+		if r.AllowSubdomains && strings.HasSuffix(name, "."+domain) {
+			return true
+		}
+		if r.AllowGlobDomains && globMatch(domain, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsExtKeyUsage returns true if the role issues certificates with the extended key usage
+func (r *vaultRole) allowsExtKeyUsage(usage x509.ExtKeyUsage) bool {
+	switch usage {
+	case x509.ExtKeyUsageServerAuth:
+		return r.ServerFlag || containsString(r.ExtKeyUsage, vaultExtKeyUsages[usage])
+	case x509.ExtKeyUsageClientAuth:
+		return r.ClientFlag || containsString(r.ExtKeyUsage, vaultExtKeyUsages[usage])
+	case x509.ExtKeyUsageCodeSigning:
+		return r.CodeSigningFlag || containsString(r.ExtKeyUsage, vaultExtKeyUsages[usage])
+	case x509.ExtKeyUsageEmailProtection:
+		return r.EmailProtectionFlag || containsString(r.ExtKeyUsage, vaultExtKeyUsages[usage])
+	default:
+		return containsString(r.ExtKeyUsage, vaultExtKeyUsages[usage])
+	}
+}
+
+// Sign sends the CSR to the sign endpoint of the role. The validity is clamped to the maximum duration of
+// the issuer, to the max TTL of the role, and to the validity of the CA.
+func (i *VaultIssuer) Sign(csr *x509.CertificateRequest, options *SignOptions) ([]byte, error) {
+	ca, role := i.current()
+
+	duration := i.defaultDuration
+	if role.TTL > 0 {
+		duration = time.Duration(role.TTL) * time.Second
+	}
+	if options.Duration > 0 {
+		duration = options.Duration
+	}
+	if duration > i.maxDuration {
+		duration = i.maxDuration
+	}
+	if maxTTL := time.Duration(role.MaxTTL) * time.Second; maxTTL > 0 && duration > maxTTL {
+		duration = maxTTL
+	}
+	if remaining := time.Until(ca.cert.NotAfter); duration > remaining {
+		duration = remaining
+	}
+
+	signResponse := &vaultSignResponse{}
+	err := i.client.do(http.MethodPost, "/v1/"+i.mount+"/sign/"+i.roleName, map[string]string{
+		"csr":    string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr.Raw})),
+		"ttl":    fmt.Sprintf("%ds", int64(duration.Seconds())),
+		"format": "pem",
+	}, signResponse)
+	if err != nil {
+		return nil, wrapTemporary(err, fmt.Errorf("Failed to generate Cert: %s", err))
+	}
+
+	certificatePem := bytes.TrimSpace([]byte(signResponse.Certificate))
+	if _, err := parseCertificatePEM(certificatePem); err != nil {
+		return nil, fmt.Errorf("invalid certificate from Vault: %s", err)
+	}
+	return certificatePem, nil
+}
+
+// IssueToken generates a valid token for the cert given as parameter, with the tags of the token options
+// taken from the certificate and the attributes of its request. It returns the token and its expiry.
+func (i *VaultIssuer) IssueToken(cert *x509.Certificate, attributes *TokenAttributes) ([]byte, time.Time, error) {
+	if i.tokenIssuer == nil {
+		return nil, time.Time{}, fmt.Errorf("no token signing key configured")
+	}
+	return i.tokenOptions.issue(i.tokenIssuer, cert, attributes)
+}
+
+// ValidateCert validates if the certificate has been signed by the CA of the Vault mount, or by one of its
+// previous CAs, and if we can verify the certificate chain with it, up to the root CA. If `ca` is provided,
+// the CA certificate is used instead. Returns an error if it cannot be validated.
+func (i *VaultIssuer) ValidateCert(cert, ca *x509.Certificate) error {
+	if ca != nil {
+		return verifyWithCA(cert, ca)
+	}
+
+	chain, err := i.chainFor(cert)
+	if err != nil {
+		return err
+	}
+	return chain.verify(cert)
+}
+
+// chainFor returns the CA chain that issued the certificate. If none of the known ones issued it, but it has been
+// issued after the last refresh, Vault might have switched to a new CA since then: the refresher is asked for a
+// refresh, and the error is temporary, so that the caller retries without waiting for Vault.
+func (i *VaultIssuer) chainFor(cert *x509.Certificate) (*caChain, error) {
+	i.lock.RLock()
+	chains := append([]*caChain{i.ca}, i.retiredCAs...)
+	refreshedAt := i.refreshedAt
+	i.lock.RUnlock()
+	for _, chain := range chains {
+		if chain.issued(cert) {
+			return chain, nil
+		}
+	}
+
+	err := fmt.Errorf("certificate has not been issued by the CA of the Vault PKI mount '%s'", i.mount)
+	if refreshedAt.Before(cert.NotBefore.Add(vaultRefreshSkew)) {
+		i.requestRefresh()
+		return nil, &temporaryError{err: fmt.Errorf("%s, refreshing the CA", err)}
+	}
+	return nil, err
+}
+
+// GetCACert returns the PEM encoded CA certificate of the Vault mount.
+func (i *VaultIssuer) GetCACert() []byte {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return i.caPEM
+}
+
+// GetChain returns the PEM encoded intermediate CA certificates that issued certificates chain up through,
+// starting with the CA of the Vault mount. It is empty if the CA is the root CA.
+func (i *VaultIssuer) GetChain() []byte {
+	ca, _ := i.current()
+	return ca.chainPEM
+}
+
+// ChainFor returns the PEM encoded intermediate CA certificates of the CA that issued the certificate.
+// `retiring` is true if it has been issued by a previous CA of the Vault mount, and should be re-issued.
+func (i *VaultIssuer) ChainFor(cert *x509.Certificate) (chainPEM []byte, retiring bool, err error) {
+	chain, err := i.chainFor(cert)
+	if err != nil {
+		return nil, false, err
+	}
+	ca, _ := i.current()
+	return chain.chainPEM, chain != ca, nil
+}
+
+// GetRootCACert returns the PEM encoded root CA certificates that issued certificates chain up to, starting
+// with the one of the current CA, followed by the ones of the previous CAs.
+func (i *VaultIssuer) GetRootCACert() []byte {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return rootsPEM(append([]*caChain{i.ca}, i.retiredCAs...))
+}
+
+// Healthy returns an error if the CA of the Vault mount has expired, or if Vault can not be authenticated to.
+func (i *VaultIssuer) Healthy() error {
+	ca, _ := i.current()
+	if err := checkCAValidity(ca.cert); err != nil {
+		return err
+	}
+	if _, err := i.client.currentToken(); err != nil {
+		return fmt.Errorf("unable to authenticate to Vault: %s", err)
+	}
+	return nil
+}
+
+// Revoke revokes the certificate in Vault. Vault sets the revocation time itself and does not record
+// the reason. Revoking a certificate that is already revoked has no effect. It must only be called from the
+// workers of the leader, as it sends a request to Vault.
+func (i *VaultIssuer) Revoke(cert *x509.Certificate, revokedAt time.Time, reason int) error {
+	if _, err := i.chainFor(cert); err != nil {
+		return err
+	}
+
+	serial := vaultSerial(cert.SerialNumber)
+	err := i.client.do(http.MethodPost, "/v1/"+i.mount+"/revoke", map[string]string{
+		"serial_number": serial,
+	}, nil)
+	if err != nil {
+		return wrapTemporary(err, fmt.Errorf("failed to revoke certificate in Vault: %s", err))
+	}
+	zap.L().Info("Certificate revoked in Vault", zap.String("serial", serial), zap.Int("reason", reason))
+	return nil
+}
+
+// GetCRL returns the CRL of the Vault mount, DER encoded.
+func (i *VaultIssuer) GetCRL() ([]byte, error) {
+	crl, err := i.client.getRaw("/v1/" + i.mount + "/crl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CRL from Vault: %s", err)
+	}
+	return crl, nil
+}
+
+// vaultSerial formats a serial number the way Vault does, as colon separated hex bytes
+func vaultSerial(serial *big.Int) string {
+	serialBytes := serial.Bytes()
+	parts := make([]string, len(serialBytes))
+	for i, b := range serialBytes {
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+	return strings.Join(parts, ":")
+}
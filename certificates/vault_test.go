@@ -0,0 +1,410 @@
+package certificates
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeVault is an in-process stand-in for the Vault HTTP API, with a PKI mount `pki` that has a role `test`,
+// and the Kubernetes auth method
+type fakeVault struct {
+	t *testing.T
+
+	lock   sync.Mutex
+	caPEM  []byte
+	caCert *x509.Certificate
+	caKey  crypto.Signer
+	role   map[string]interface{}
+	token  string
+	jwt    string
+	// signStatus is returned by the sign endpoint instead of signing if set
+	signStatus int
+	logins     int
+	ttls       []string
+	revoked    []string
+}
+
+// newFakeVault starts a Vault stand-in with a new CA, whose role allows subdomains of example.com
+func newFakeVault(t *testing.T) (*fakeVault, *httptest.Server) {
+	t.Helper()
+
+	vault := &fakeVault{
+		t:     t,
+		token: "s.token",
+		jwt:   "service-account-token",
+		role: map[string]interface{}{
+			"max_ttl":          3600,
+			"allowed_domains":  []string{"example.com"},
+			"allow_subdomains": true,
+			"key_type":         "any",
+			"server_flag":      true,
+			"client_flag":      true,
+			"key_usage":        []string{"DigitalSignature", "KeyEncipherment"},
+		},
+	}
+	vault.rotateCA()
+	return vault, httptest.NewServer(vault)
+}
+
+// rotateCA switches the mount to a new CA
+func (v *fakeVault) rotateCA() {
+	key := newTestKey(v.t, "ecdsa")
+	caPEM, caCert := newTestCA(v.t, key, fmt.Sprintf("vault-ca-%d", time.Now().UnixNano()))
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.caPEM, v.caCert, v.caKey = caPEM, caCert, key
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if req.URL.Path == "/v1/auth/kubernetes/login" {
+		login := map[string]string{}
+		if err := json.NewDecoder(req.Body).Decode(&login); err != nil || login["role"] != "csr" || login["jwt"] != v.jwt {
+			v.writeError(w, http.StatusBadRequest, "invalid login")
+			return
+		}
+		v.logins++
+		v.writeJSON(w, map[string]interface{}{
+			"auth": map[string]interface{}{"client_token": v.token, "lease_duration": 3600},
+		})
+		return
+	}
+	if req.Header.Get("X-Vault-Token") != v.token {
+		v.writeError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	switch {
+	case req.Method == http.MethodGet && req.URL.Path == "/v1/pki/ca_chain":
+		w.Write(v.caPEM)
+	case req.Method == http.MethodGet && req.URL.Path == "/v1/pki/roles/test":
+		v.writeJSON(w, map[string]interface{}{"data": v.role})
+	case req.Method == http.MethodPost && req.URL.Path == "/v1/pki/sign/test":
+		v.sign(w, req)
+	case req.Method == http.MethodPost && req.URL.Path == "/v1/pki/revoke":
+		revoke := map[string]string{}
+		if err := json.NewDecoder(req.Body).Decode(&revoke); err != nil {
+			v.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		v.revoked = append(v.revoked, revoke["serial_number"])
+		v.writeJSON(w, map[string]interface{}{"data": map[string]interface{}{"revocation_time": time.Now().Unix()}})
+	case req.Method == http.MethodGet && req.URL.Path == "/v1/pki/crl":
+		crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+			Number:     big.NewInt(1),
+			ThisUpdate: time.Now(),
+			NextUpdate: time.Now().Add(time.Hour),
+		}, v.caCert, v.caKey)
+		if err != nil {
+			v.writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Write(crl)
+	default:
+		v.writeError(w, http.StatusNotFound, "unsupported path "+req.URL.Path)
+	}
+}
+
+// sign signs the CSR of the request with the CA for the requested TTL
+func (v *fakeVault) sign(w http.ResponseWriter, req *http.Request) {
+	if v.signStatus != 0 {
+		v.writeError(w, v.signStatus, http.StatusText(v.signStatus))
+		return
+	}
+
+	signRequest := map[string]string{}
+	if err := json.NewDecoder(req.Body).Decode(&signRequest); err != nil {
+		v.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	block, _ := pem.Decode([]byte(signRequest["csr"]))
+	if block == nil {
+		v.writeError(w, http.StatusBadRequest, "no CSR")
+		return
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		v.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ttl, err := time.ParseDuration(signRequest["ttl"])
+	if err != nil {
+		v.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	v.ttls = append(v.ttls, signRequest["ttl"])
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		v.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: csr.Subject.CommonName},
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-30 * time.Second),
+		NotAfter:     time.Now().Add(ttl),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}, v.caCert, csr.PublicKey, v.caKey)
+	if err != nil {
+		v.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	v.writeJSON(w, map[string]interface{}{
+		"data": map[string]interface{}{
+			"certificate":   string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+			"serial_number": vaultSerial(serial),
+		},
+	})
+}
+
+func (v *fakeVault) writeJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		v.t.Errorf("failed to write response: %s", err)
+	}
+}
+
+func (v *fakeVault) writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string][]string{"errors": {message}})
+}
+
+// newTestVaultIssuer creates a VaultIssuer for the Vault stand-in, authenticated with its token
+func newTestVaultIssuer(t *testing.T, server *httptest.Server) *VaultIssuer {
+	t.Helper()
+
+	issuer, err := NewVaultIssuer(&VaultConfig{Address: server.URL, Role: "test", Token: "s.token"})
+	if err != nil {
+		t.Fatalf("NewVaultIssuer() error = %s", err)
+	}
+	return issuer
+}
+
+// signTestVaultCert validates and signs a CSR for a new ECDSA key with the Vault issuer
+func signTestVaultCert(t *testing.T, issuer *VaultIssuer, commonName string) *x509.Certificate {
+	t.Helper()
+
+	key := newTestKey(t, "ecdsa")
+	if err := issuer.ValidateRequest(newTestCSR(t, key, commonName), &SignOptions{}); err != nil {
+		t.Fatalf("ValidateRequest() error = %s", err)
+	}
+	return signTestCert(t, issuer, key, commonName)
+}
+
+func TestVaultIssuerSign(t *testing.T) {
+	vault, server := newFakeVault(t)
+	defer server.Close()
+	issuer := newTestVaultIssuer(t, server)
+
+	if string(issuer.GetCACert()) != string(vault.caPEM) {
+		t.Errorf("GetCACert() differs from the CA of the mount")
+	}
+
+	cert := signTestVaultCert(t, issuer, "workload")
+	if err := issuer.ValidateCert(cert, nil); err != nil {
+		t.Errorf("ValidateCert() error = %s", err)
+	}
+	if _, retiring, err := issuer.ChainFor(cert); err != nil || retiring {
+		t.Errorf("ChainFor() = retiring %v, %v, want not retiring", retiring, err)
+	}
+	// the duration of the issuer is clamped to the max TTL of the role
+	if len(vault.ttls) != 1 || vault.ttls[0] != "3600s" {
+		t.Errorf("requested TTLs = %v, want the max TTL of the role", vault.ttls)
+	}
+
+	csr := newTestCSR(t, newTestKey(t, "ecdsa"), "workload")
+	csr.DNSNames = []string{"workload.example.org"}
+	if err := issuer.ValidateRequest(csr, &SignOptions{}); err == nil {
+		t.Errorf("ValidateRequest() accepted a DNS name that the role does not allow")
+	}
+	if err := issuer.ValidateRequest(newTestCSR(t, newTestKey(t, "ecdsa"), "workload"), &SignOptions{IsCA: true}); err == nil {
+		t.Errorf("ValidateRequest() accepted a CA certificate")
+	}
+}
+
+func TestVaultIssuerTemporaryErrors(t *testing.T) {
+	vault, server := newFakeVault(t)
+	issuer := newTestVaultIssuer(t, server)
+	csr := newTestCSR(t, newTestKey(t, "ecdsa"), "workload")
+
+	tests := []struct {
+		status    int
+		temporary bool
+	}{
+		{status: http.StatusServiceUnavailable, temporary: true},
+		{status: http.StatusInternalServerError, temporary: true},
+		{status: http.StatusTooManyRequests, temporary: true},
+		{status: http.StatusBadRequest, temporary: false},
+	}
+	for _, tt := range tests {
+		vault.lock.Lock()
+		vault.signStatus = tt.status
+		vault.lock.Unlock()
+
+		_, err := issuer.Sign(csr, &SignOptions{})
+		if err == nil {
+			t.Fatalf("Sign() succeeded with status %d", tt.status)
+		}
+		if IsTemporary(err) != tt.temporary {
+			t.Errorf("Sign() error with status %d is temporary: %v, want %v", tt.status, IsTemporary(err), tt.temporary)
+		}
+	}
+
+	server.Close()
+	if _, err := issuer.Sign(csr, &SignOptions{}); err == nil || !IsTemporary(err) {
+		t.Errorf("Sign() error = %v with Vault down, want a temporary error", err)
+	}
+}
+
+func TestVaultIssuerCARotation(t *testing.T) {
+	vault, server := newFakeVault(t)
+	defer server.Close()
+	issuer := newTestVaultIssuer(t, server)
+	oldCert := signTestVaultCert(t, issuer, "old")
+
+	vault.rotateCA()
+	newCert := signTestVaultCert(t, issuer, "new")
+
+	// the new CA is not known before the next refresh, which is requested without waiting for it
+	_, _, err := issuer.ChainFor(newCert)
+	if err == nil || !IsTemporary(err) {
+		t.Fatalf("ChainFor() error = %v for a certificate of a new CA, want a temporary error", err)
+	}
+	if len(issuer.refresh) != 1 {
+		t.Errorf("ChainFor() did not request a refresh")
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go issuer.RunRefresher(time.Hour, stopCh)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, retiring, err := issuer.ChainFor(newCert)
+		if err == nil {
+			if retiring {
+				t.Errorf("ChainFor() reports a certificate of the new CA as retiring")
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ChainFor() error = %s after the requested refresh", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, retiring, err := issuer.ChainFor(oldCert); err != nil || !retiring {
+		t.Errorf("ChainFor() = retiring %v, %v for a certificate of the previous CA, want retiring", retiring, err)
+	}
+	if err := issuer.ValidateCert(oldCert, nil); err != nil {
+		t.Errorf("ValidateCert() error = %s for a certificate of the previous CA", err)
+	}
+
+	// certificates of other CAs that were issued before the last refresh fail for good
+	otherKey := newTestKey(t, "ecdsa")
+	_, otherCA := newTestCA(t, otherKey, "other-ca")
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "other"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}, otherCA, newTestKey(t, "ecdsa").Public(), otherKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err)
+	}
+	otherCert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %s", err)
+	}
+	if err := issuer.ValidateCert(otherCert, nil); err == nil || IsTemporary(err) {
+		t.Errorf("ValidateCert() error = %v for a certificate of another CA, want a permanent error", err)
+	}
+}
+
+func TestVaultIssuerKubernetesLogin(t *testing.T) {
+	vault, server := newFakeVault(t)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	jwtPath := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(jwtPath, []byte(vault.jwt+"\n"), 0600); err != nil {
+		t.Fatalf("failed to write service account token: %s", err)
+	}
+
+	issuer, err := NewVaultIssuer(&VaultConfig{
+		Address:                 server.URL,
+		Role:                    "test",
+		KubernetesAuthRole:      "csr",
+		ServiceAccountTokenPath: jwtPath,
+	})
+	if err != nil {
+		t.Fatalf("NewVaultIssuer() error = %s", err)
+	}
+	signTestVaultCert(t, issuer, "workload")
+
+	// a revoked token gets replaced with a new login
+	vault.lock.Lock()
+	vault.token = "s.other"
+	vault.lock.Unlock()
+	signTestVaultCert(t, issuer, "workload")
+
+	vault.lock.Lock()
+	defer vault.lock.Unlock()
+	if vault.logins != 2 {
+		t.Errorf("logged in %d times, want 2", vault.logins)
+	}
+}
+
+func TestVaultIssuerRevoke(t *testing.T) {
+	vault, server := newFakeVault(t)
+	defer server.Close()
+	issuer := newTestVaultIssuer(t, server)
+	cert := signTestVaultCert(t, issuer, "workload")
+
+	if err := issuer.Revoke(cert, time.Now(), 1); err != nil {
+		t.Fatalf("Revoke() error = %s", err)
+	}
+	vault.lock.Lock()
+	revoked := vault.revoked
+	vault.lock.Unlock()
+	if len(revoked) != 1 || revoked[0] != vaultSerial(cert.SerialNumber) {
+		t.Errorf("revoked serials = %v, want %s", revoked, vaultSerial(cert.SerialNumber))
+	}
+
+	der, err := issuer.GetCRL()
+	if err != nil {
+		t.Fatalf("GetCRL() error = %s", err)
+	}
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		t.Fatalf("failed to parse CRL: %s", err)
+	}
+	if err := crl.CheckSignatureFrom(vault.caCert); err != nil {
+		t.Errorf("CRL has not been signed by the CA of the mount: %s", err)
+	}
+}
@@ -0,0 +1,284 @@
+package certificates
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultVaultTimeout is the default timeout of the requests to Vault.
+const DefaultVaultTimeout = 10 * time.Second
+
+// DefaultVaultKubernetesAuthMount is the default path of the Kubernetes auth method in Vault.
+const DefaultVaultKubernetesAuthMount = "kubernetes"
+
+// DefaultServiceAccountTokenPath is where Kubernetes mounts the token of the service account of a pod.
+const DefaultServiceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// maxVaultResponseSize is the maximum size of the responses read from Vault
+const maxVaultResponseSize = 1 << 20
+
+// vaultClient sends authenticated requests to the Vault HTTP API. It logs in with the Kubernetes auth method
+// if no token is configured, and logs in again before the token expires or once Vault rejects it.
+type vaultClient struct {
+	address    string
+	httpClient *http.Client
+
+	authMount string
+	authRole  string
+	jwtPath   string
+
+	tokenLock   sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// vaultResponse is the envelope of the JSON responses of Vault
+type vaultResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Auth   *vaultAuth      `json:"auth"`
+	Errors []string        `json:"errors"`
+}
+
+// vaultAuth is the token returned by a login
+type vaultAuth struct {
+	ClientToken   string `json:"client_token"`
+	LeaseDuration int64  `json:"lease_duration"`
+}
+
+// vaultError is an error response of Vault
+type vaultError struct {
+	status int
+	errors []string
+}
+
+func (e *vaultError) Error() string {
+	if len(e.errors) == 0 {
+		return fmt.Sprintf("vault returned %d", e.status)
+	}
+	return fmt.Sprintf("vault returned %d: %s", e.status, strings.Join(e.errors, ", "))
+}
+
+// Temporary returns true for server errors and rate limiting, which can go away when the request is retried.
+// Vault is also unavailable with 5xx while it is sealed or in standby.
+func (e *vaultError) Temporary() bool {
+	return e.status >= http.StatusInternalServerError || e.status == http.StatusTooManyRequests
+}
+
+// newVaultClient creates a client of the Vault server of `config`, which authenticates with its token if set,
+// or else with the Kubernetes auth method.
+func newVaultClient(config *VaultConfig) *vaultClient {
+	authMount := config.KubernetesAuthMount
+	if authMount == "" {
+		authMount = DefaultVaultKubernetesAuthMount
+	}
+	jwtPath := config.ServiceAccountTokenPath
+	if jwtPath == "" {
+		jwtPath = DefaultServiceAccountTokenPath
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = DefaultVaultTimeout
+	}
+
+	return &vaultClient{
+		address: strings.TrimSuffix(config.Address, "/"),
+		httpClient: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				TLSClientConfig: config.TLSConfig,
+			},
+		},
+		authMount: strings.Trim(authMount, "/"),
+		authRole:  config.KubernetesAuthRole,
+		jwtPath:   jwtPath,
+		token:     config.Token,
+	}
+}
+
+// canLogin returns true if the client logs in with the Kubernetes auth method, instead of using a fixed token
+func (c *vaultClient) canLogin() bool {
+	return c.authRole != ""
+}
+
+// currentToken returns the Vault token, and logs in first if there is none or if it is about to expire
+func (c *vaultClient) currentToken() (string, error) {
+	c.tokenLock.Lock()
+	defer c.tokenLock.Unlock()
+
+	if !c.canLogin() {
+		if c.token == "" {
+			return "", fmt.Errorf("no Vault token or Kubernetes auth role configured")
+		}
+		return c.token, nil
+	}
+	if c.token != "" && (c.tokenExpiry.IsZero() || time.Now().Before(c.tokenExpiry)) {
+		return c.token, nil
+	}
+
+	jwt, err := ioutil.ReadFile(c.jwtPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to read service account token: %s", err)
+	}
+	body, err := json.Marshal(map[string]string{
+		"role": c.authRole,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	})
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.send(http.MethodPost, "/v1/auth/"+c.authMount+"/login", "", body)
+	if err != nil {
+		return "", wrapTemporary(err, fmt.Errorf("failed to log in to Vault: %s", err))
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return "", fmt.Errorf("failed to log in to Vault: no token returned")
+	}
+
+	c.token = resp.Auth.ClientToken
+	c.tokenExpiry = time.Time{}
+	if resp.Auth.LeaseDuration > 0 {
+		// log in again well before the token expires, so that no request fails in between
+		lease := time.Duration(resp.Auth.LeaseDuration) * time.Second
+		c.tokenExpiry = time.Now().Add(lease * 4 / 5)
+	}
+	zap.L().Debug("Logged in to Vault", zap.String("role", c.authRole), zap.Int64("leaseDuration", resp.Auth.LeaseDuration))
+	return c.token, nil
+}
+
+// dropToken forgets the token, so that the next request logs in again
+func (c *vaultClient) dropToken() {
+	c.tokenLock.Lock()
+	c.token = ""
+	c.tokenLock.Unlock()
+}
+
+// do sends an authenticated request, and decodes the data of the response into `data` if not nil.
+// A rejected token is replaced once with a new login.
+func (c *vaultClient) do(method, path string, request interface{}, data interface{}) error {
+	var body []byte
+	if request != nil {
+		var err error
+		body, err = json.Marshal(request)
+		if err != nil {
+			return err
+		}
+	}
+
+	token, err := c.currentToken()
+	if err != nil {
+		return err
+	}
+	resp, err := c.send(method, path, token, body)
+	if vaultErr, ok := err.(*vaultError); ok && vaultErr.status == http.StatusForbidden && c.canLogin() {
+		c.dropToken()
+		if token, err = c.currentToken(); err != nil {
+			return err
+		}
+		resp, err = c.send(method, path, token, body)
+	}
+	if err != nil {
+		return err
+	}
+
+	if data == nil {
+		return nil
+	}
+	if len(resp.Data) == 0 {
+		return fmt.Errorf("vault returned no data for %s", path)
+	}
+	return json.Unmarshal(resp.Data, data)
+}
+
+// send sends a request with the token if set, and decodes the JSON response
+func (c *vaultClient) send(method, path, token string, body []byte) (*vaultResponse, error) {
+	respBody, err := c.sendRaw(method, path, token, body)
+	if err != nil {
+		return nil, err
+	}
+	resp := &vaultResponse{}
+	if len(respBody) == 0 {
+		return resp, nil
+	}
+	if err := json.Unmarshal(respBody, resp); err != nil {
+		return nil, fmt.Errorf("invalid response from Vault: %s", err)
+	}
+	return resp, nil
+}
+
+// getRaw sends an authenticated GET request, and returns the raw response, e.g. of the PEM and DER endpoints
+func (c *vaultClient) getRaw(path string) ([]byte, error) {
+	token, err := c.currentToken()
+	if err != nil {
+		return nil, err
+	}
+	return c.sendRaw(http.MethodGet, path, token, nil)
+}
+
+// sendRaw sends a request with the token if set, and returns the response body.
+// Error responses are returned as a *vaultError, and transport errors are temporary.
+func (c *vaultClient) sendRaw(method, path, token string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.address+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, &temporaryError{err: err}
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxVaultResponseSize))
+	if err != nil {
+		return nil, &temporaryError{err: err}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		vaultErr := &vaultError{status: resp.StatusCode}
+		errResp := &vaultResponse{}
+		if json.Unmarshal(respBody, errResp) == nil {
+			vaultErr.errors = errResp.Errors
+		}
+		return nil, vaultErr
+	}
+	return respBody, nil
+}
+
+// NewVaultTLSConfig returns the TLS configuration to connect to Vault, which trusts the CA of `caPath` if set
+// instead of the system CAs.
+func NewVaultTLSConfig(caPath string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caPath == "" {
+		return tlsConfig, nil
+	}
+	caPEM, err := ioutil.ReadFile(caPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read Vault CA: %s", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificate found in Vault CA %s", caPath)
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto"
+	"crypto/x509"
 	"fmt"
 	"os"
 	"os/signal"
@@ -64,6 +66,25 @@ func main() {
 
 	fmt.Printf("Received Token: %+v ", token)
 
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	certManager.AddRenewalCallback(func(cert *x509.Certificate, key crypto.PrivateKey, token []byte) {
+		fmt.Printf("Renewed Cert: %+v ", cert)
+	})
+	err = certManager.StartRenewal(0.7, 0.1, time.Minute, stopCh)
+	if err != nil {
+		fmt.Printf("Error starting renewal %s", err)
+	}
+
+	certManager.AddTokenCallback(func(token []byte) {
+		fmt.Printf("Refreshed Token: %+v ", token)
+	})
+	err = certManager.StartTokenWatch(30*time.Second, stopCh)
+	if err != nil {
+		fmt.Printf("Error starting token watch %s", err)
+	}
+
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
 	zap.L().Info("Everything started. Waiting for Stop signal")
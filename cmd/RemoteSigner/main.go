@@ -0,0 +1,75 @@
+// RemoteSigner is a reference signing service for the remote signer of trireme-csr. It keeps the CA key on
+// its local disk, and signs the digests of the controller over mutual TLS.
+package main
+
+import (
+	"crypto"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+	"go.uber.org/zap"
+
+	"github.com/CodingJzy/trireme-csr/certificates"
+	"github.com/CodingJzy/trireme-csr/remotesigner"
+)
+
+func main() {
+	listenAddress := flag.String("ListenAddress", ":8443", "Listen address of the signing service.")
+	key := flag.String("Key", "", "Path to the PEM encoded signing key.")
+	keyPassFile := flag.String("KeyPassFile", "", "Path to a file holding the password for the signing key.")
+	tlsCert := flag.String("TLSCert", "", "Path to the TLS certificate of the signing service.")
+	tlsKey := flag.String("TLSKey", "", "Path to the TLS key of the signing service.")
+	tlsClientCA := flag.String("TLSClientCA", "", "Path to the CA that issues the client certificates of the controllers.")
+	flag.Parse()
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		panic(err)
+	}
+	zap.ReplaceGlobals(logger)
+
+	if *key == "" || *tlsCert == "" || *tlsKey == "" || *tlsClientCA == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var keyPass string
+	if *keyPassFile != "" {
+		pass, err := ioutil.ReadFile(*keyPassFile)
+		if err != nil {
+			zap.L().Fatal("Error reading key password file", zap.Error(err))
+		}
+		keyPass = strings.TrimRight(string(pass), "\r\n")
+	}
+
+	privateKey, err := certificates.ReadPrivateKeyPEM(*key, keyPass)
+	if err != nil {
+		zap.L().Fatal("Error loading signing key", zap.Error(err))
+	}
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		zap.L().Fatal("Signing key can not be used to sign")
+	}
+
+	handler, err := remotesigner.NewServer(signer)
+	if err != nil {
+		zap.L().Fatal("Error creating signing service", zap.Error(err))
+	}
+	tlsConfig, err := remotesigner.ServerTLSConfig(*tlsCert, *tlsKey, *tlsClientCA)
+	if err != nil {
+		zap.L().Fatal("Error loading TLS configuration", zap.Error(err))
+	}
+
+	server := &http.Server{
+		Addr:      *listenAddress,
+		Handler:   handler,
+		TLSConfig: tlsConfig,
+	}
+	zap.L().Info("Signing service started", zap.String("address", *listenAddress))
+	if err := server.ListenAndServeTLS("", ""); err != nil {
+		zap.L().Fatal("Error serving signing service", zap.Error(err))
+	}
+}
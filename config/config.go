@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 
@@ -13,15 +15,161 @@ import (
 // DefaultKubeConfigLocation is the default location of the KubeConfig file.
 const DefaultKubeConfigLocation = "/.kube/config"
 
+// DefaultWorkers is the default number of workers processing Certificate objects.
+const DefaultWorkers = 2
+
+// DefaultCertificateDuration is the default validity of issued certificates.
+const DefaultCertificateDuration = 365 * 24 * time.Hour
+
+// DefaultMinRSAKeySize is the default minimum size in bits of RSA keys in CSRs.
+const DefaultMinRSAKeySize = 2048
+
+// DefaultMetricsAddress is the default listen address of the metrics endpoint.
+const DefaultMetricsAddress = ":9090"
+
+// DefaultHealthAddress is the default listen address of the health and version endpoints.
+const DefaultHealthAddress = ":8080"
+
+// DefaultCRLAddress is the default listen address of the CRL endpoint.
+const DefaultCRLAddress = ":8081"
+
+// DefaultApprovalWebhookAddress is the default listen address of the approval webhook.
+const DefaultApprovalWebhookAddress = ":8443"
+
+// MinApproverKeySize is the minimum size in bytes of the key that the approvers of CertificateApprovals get signed with.
+const MinApproverKeySize = 32
+
+// DefaultCRLUpdateInterval is the default interval at which the CRL gets regenerated.
+const DefaultCRLUpdateInterval = time.Hour
+
+// DefaultRevocationConfigMap is the default ConfigMap, as namespace/name, that the revocations get persisted in.
+const DefaultRevocationConfigMap = "default/trireme-csr-revocations"
+
+// DefaultOCSPAddress is the default listen address of the OCSP responder.
+const DefaultOCSPAddress = ":8082"
+
+// DefaultOCSPResponseValidity is the default validity of the OCSP responses.
+const DefaultOCSPResponseValidity = time.Hour
+
+// DefaultSigningCAReloadInterval is the default interval at which the signing CA files get reloaded.
+const DefaultSigningCAReloadInterval = 10 * time.Second
+
+// DefaultSigningCASecretPassKey is the default key of the signing CA password in its Secret.
+const DefaultSigningCASecretPassKey = "passphrase"
+
+// DefaultSigningRemoteSignerTimeout is the default timeout of the requests to the remote signing service.
+const DefaultSigningRemoteSignerTimeout = 10 * time.Second
+
+// Default Vault settings.
+const (
+	DefaultVaultPKIMount            = "pki"
+	DefaultVaultKubernetesAuthMount = "kubernetes"
+	DefaultVaultTimeout             = 10 * time.Second
+	DefaultVaultRefreshInterval     = 5 * time.Minute
+)
+
+// DefaultPolicyConfigMapKey is the default key of the issuance policy in its ConfigMap.
+const DefaultPolicyConfigMapKey = "policy.yaml"
+
+// DefaultPolicyReloadInterval is the default interval at which the issuance policy file gets reloaded.
+const DefaultPolicyReloadInterval = 10 * time.Second
+
+// Default leader election settings.
+const (
+	DefaultLeaderElectionNamespace     = "default"
+	DefaultLeaderElectionLeaseName     = "trireme-csr"
+	DefaultLeaderElectionLeaseDuration = 15 * time.Second
+	DefaultLeaderElectionRenewDeadline = 10 * time.Second
+	DefaultLeaderElectionRetryPeriod   = 2 * time.Second
+)
+
 // Configuration contains all the User Parameter for Trireme-CSR.
 type Configuration struct {
 	KubeconfigPath string
 
-	SigningCACert        string
-	SigningCACertData    []byte
-	SigningCACertKey     string
-	SigningCACertKeyData []byte
-	SigningCACertKeyPass string
+	SigningCACert               string
+	SigningCACertData           []byte
+	SigningCACertKey            string
+	SigningCACertKeyData        []byte
+	SigningCACertKeyPass        string
+	SigningCACertKeyPassFile    string
+	SigningCACertReloadInterval time.Duration
+	SigningCASecret             string
+	SigningCASecretPassKey      string
+
+	SigningRemoteSigner        string
+	SigningRemoteSignerTLSCert string
+	SigningRemoteSignerTLSKey  string
+	SigningRemoteSignerTLSCA   string
+	SigningRemoteSignerTimeout time.Duration
+	TrustedCAs                 []string
+
+	VaultAddress             string
+	VaultPKIMount            string
+	VaultRole                string
+	VaultTokenFile           string
+	VaultToken               string
+	VaultKubernetesAuthMount string
+	VaultKubernetesAuthRole  string
+	VaultCACert              string
+	VaultTimeout             time.Duration
+	VaultRefreshInterval     time.Duration
+
+	SigningSignatureAlgorithm string
+
+	TokenSigningKey         string
+	TokenSigningKeyPass     string
+	TokenSigningKeyPassFile string
+	TokenValidity           time.Duration
+	TokenStaticTags         []string
+	TokenLabelKeys          []string
+	TokenAnnotationKeys     []string
+	TokenSubjectFields      []string
+	TokenSANs               []string
+	TokenAllowedKeys        []string
+	TokenDeniedKeys         []string
+
+	Workers int
+
+	MinRSAKeySize int
+
+	CertificateDuration    time.Duration
+	MaxCertificateDuration time.Duration
+
+	ApprovalRequired       bool
+	AutoApproveProfiles    []string
+	ApproverKeyFile        string
+	ApproverKey            []byte
+	ApprovalWebhookAddress string
+	ApprovalWebhookTLSCert string
+	ApprovalWebhookTLSKey  string
+
+	LeaderElection              bool
+	LeaderElectionNamespace     string
+	LeaderElectionLeaseName     string
+	LeaderElectionLeaseDuration time.Duration
+	LeaderElectionRenewDeadline time.Duration
+	LeaderElectionRetryPeriod   time.Duration
+
+	MetricsAddress string
+	HealthAddress  string
+
+	CRLAddress          string
+	CRLUpdateInterval   time.Duration
+	RevocationConfigMap string
+
+	OCSPAddress                string
+	OCSPResponderURL           string
+	OCSPResponseValidity       time.Duration
+	OCSPSigningCert            string
+	OCSPSigningCertKey         string
+	OCSPSigningCertKeyPass     string
+	OCSPSigningCertKeyPassFile string
+
+	PolicyFile           string
+	PolicyConfigMap      string
+	PolicyConfigMapKey   string
+	PolicyReloadInterval time.Duration
 
 	LogFormat string
 	LogLevel  string
@@ -42,9 +190,78 @@ func LoadConfig() (*Configuration, error) {
 	flag.String("LogLevel", "", "Log level. Default to info (trace//debug//info//warn//error//fatal)")
 	flag.String("LogFormat", "", "Log Format. Default to human")
 
-	flag.String("SigningCacert", "", "Path to the CA that will issue certificates.")
+	flag.String("SigningCacert", "", "Path to the CA that will issue certificates. Can be a bundle of the CA followed by its intermediates and root CA.")
 	flag.String("SigningCacertKey", "", "Path to the CA key that will issue certificates.")
-	flag.String("SigningCacertKeyPass", "", "Password for the signing CA.")
+	flag.String("SigningCacertKeyPass", "", "Password for the signing CA. Prefer SigningCacertKeyPassFile, as arguments are visible to other processes.")
+	flag.String("SigningCacertKeyPassFile", "", "Path to a file holding the password for the signing CA, e.g. a mounted Secret key. Supports legacy encrypted PEM and encrypted PKCS#8 keys.")
+	flag.Duration("SigningCacertReloadInterval", DefaultSigningCAReloadInterval, "Interval at which the signing CA files are reloaded if they changed. 0 to disable.")
+	flag.String("SigningCaSecret", "", "Secret holding the signing CA in tls.crt and tls.key, as namespace/name. Replaces the signing CA files, and gets watched for updates.")
+	flag.String("SigningCaSecretPassKey", DefaultSigningCASecretPassKey, "Key of the optional password for the signing CA in its Secret.")
+	flag.String("SigningRemoteSigner", "", "URL of a signing service that keeps the signing CA key, instead of loading it from SigningCacertKey.")
+	flag.String("SigningRemoteSignerTLSCert", "", "Path to the client certificate for the signing service.")
+	flag.String("SigningRemoteSignerTLSKey", "", "Path to the client key for the signing service.")
+	flag.String("SigningRemoteSignerTLSCA", "", "Path to the CA that issued the certificate of the signing service.")
+	flag.Duration("SigningRemoteSignerTimeout", DefaultSigningRemoteSignerTimeout, "Timeout of the requests to the signing service.")
+	flag.String("VaultAddress", "", "URL of a Vault server whose PKI secrets engine signs certificates, instead of the signing CA.")
+	flag.String("VaultPKIMount", DefaultVaultPKIMount, "Path of the PKI secrets engine in Vault.")
+	flag.String("VaultRole", "", "PKI role that signs certificates. Its allowed names, key types, usages and TTLs are enforced on Certificate requests.")
+	flag.String("VaultTokenFile", "", "Path to a file holding the Vault token. Exclusive with VaultKubernetesAuthRole.")
+	flag.String("VaultKubernetesAuthMount", DefaultVaultKubernetesAuthMount, "Path of the Kubernetes auth method in Vault.")
+	flag.String("VaultKubernetesAuthRole", "", "Role to log in to Vault as with the Kubernetes auth method, using the service account token of the pod.")
+	flag.String("VaultCACert", "", "Path to the CA that issued the certificate of Vault. Defaults to the system CAs.")
+	flag.Duration("VaultTimeout", DefaultVaultTimeout, "Timeout of the requests to Vault.")
+	flag.Duration("VaultRefreshInterval", DefaultVaultRefreshInterval, "Interval at which the CA and the role get refreshed from Vault.")
+	flag.StringSlice("TrustedCAs", []string{}, "Paths to the CA bundles of retiring signing CAs. Their certificates stay valid until they expire, but get flagged for re-issue, and can not be revoked.")
+	flag.String("SigningSignatureAlgorithm", "", "Algorithm to sign certificates with, e.g. SHA384-RSAPSS for an RSA CA. Defaults to one matching the signing CA key.")
+	flag.String("TokenSigningKey", "", "Path to a separate ECDSA key that signs tokens. Required if the signing CA key is not an ECDSA key.")
+	flag.String("TokenSigningKeyPass", "", "Password for the token signing key. Prefer TokenSigningKeyPassFile, as arguments are visible to other processes.")
+	flag.String("TokenSigningKeyPassFile", "", "Path to a file holding the password for the token signing key.")
+	flag.Duration("TokenValidity", 0, "Validity of the tokens. 0 to expire them with their certificate, which also caps the validity.")
+	flag.StringSlice("TokenStaticTags", []string{}, "Tags added to every token, as key=value. They take precedence over the tags of the requests.")
+	flag.StringSlice("TokenLabelKeys", []string{}, "Patterns of the Certificate label keys to copy into tokens as tags, where * matches any characters.")
+	flag.StringSlice("TokenAnnotationKeys", []string{}, "Patterns of the Certificate annotation keys to copy into tokens as tags, where * matches any characters.")
+	flag.StringSlice("TokenSubjectFields", []string{}, "Subject fields of the certificates to copy into tokens as tags (commonName//organization//organizationalUnit//country//province//locality//serialNumber).")
+	flag.StringSlice("TokenSANs", []string{}, "Types of the SANs of the certificates to copy into tokens as tags (dns//email//ip//uri).")
+	flag.StringSlice("TokenAllowedKeys", []string{}, "Patterns of the tag keys that requests can set. Defaults to all keys.")
+	flag.StringSlice("TokenDeniedKeys", []string{}, "Patterns of the tag keys that requests can not set.")
+
+	flag.Int("Workers", DefaultWorkers, "Number of workers processing Certificate objects in parallel.")
+	flag.Int("MinRSAKeySize", DefaultMinRSAKeySize, "Minimum size in bits of RSA keys in Certificate requests. Must be at least 2048.")
+	flag.Duration("CertificateDuration", DefaultCertificateDuration, "Validity of issued certificates that do not request a duration.")
+	flag.Duration("MaxCertificateDuration", DefaultCertificateDuration, "Maximum validity of issued certificates. Requested durations are clamped to it.")
+
+	flag.Bool("ApprovalRequired", false, "Require an approval before Certificate requests get signed.")
+	flag.StringSlice("AutoApproveProfiles", []string{}, "Profiles whose Certificate requests are approved automatically when approvals are required.")
+	flag.String("ApproverKeyFile", "", "Path to a file holding the key that the approval webhook signs the approvers of CertificateApprovals with. Required for CertificateApprovals to be taken into account.")
+	flag.String("ApprovalWebhookAddress", DefaultApprovalWebhookAddress, "Listen address of the approval webhook, which records the authenticated approver of CertificateApprovals. Empty to disable.")
+	flag.String("ApprovalWebhookTLSCert", "", "Path to the serving certificate of the approval webhook.")
+	flag.String("ApprovalWebhookTLSKey", "", "Path to the serving key of the approval webhook.")
+
+	flag.String("MetricsAddress", DefaultMetricsAddress, "Listen address of the metrics endpoint. Empty to disable.")
+	flag.String("HealthAddress", DefaultHealthAddress, "Listen address of the health and version endpoints. Empty to disable.")
+	flag.String("CRLAddress", DefaultCRLAddress, "Listen address of the CRL endpoint. Empty to disable.")
+	flag.Duration("CRLUpdateInterval", DefaultCRLUpdateInterval, "Interval at which the CRL gets regenerated.")
+	flag.String("RevocationConfigMap", DefaultRevocationConfigMap, "ConfigMap that the revocations get persisted in, as namespace/name.")
+
+	flag.String("OCSPAddress", DefaultOCSPAddress, "Listen address of the OCSP responder. Empty to disable.")
+	flag.String("OCSPResponderURL", "", "URL of the OCSP responder to embed in issued certificates. Empty to not embed it.")
+	flag.Duration("OCSPResponseValidity", DefaultOCSPResponseValidity, "Validity of the OCSP responses.")
+	flag.String("OCSPSigningCert", "", "Path to a delegated OCSP signing certificate. Defaults to signing with the CA.")
+	flag.String("OCSPSigningCertKey", "", "Path to the key of the delegated OCSP signing certificate.")
+	flag.String("OCSPSigningCertKeyPass", "", "Password for the key of the delegated OCSP signing certificate. Prefer OCSPSigningCertKeyPassFile, as arguments are visible to other processes.")
+	flag.String("OCSPSigningCertKeyPassFile", "", "Path to a file holding the password for the key of the delegated OCSP signing certificate.")
+
+	flag.String("PolicyFile", "", "Path to a YAML issuance policy file which gets reloaded on changes.")
+	flag.String("PolicyConfigMap", "", "ConfigMap holding the issuance policy, as namespace/name. It gets reloaded on changes.")
+	flag.String("PolicyConfigMapKey", DefaultPolicyConfigMapKey, "Key of the issuance policy in its ConfigMap.")
+	flag.Duration("PolicyReloadInterval", DefaultPolicyReloadInterval, "Interval at which the issuance policy file gets checked for changes.")
+
+	flag.Bool("LeaderElection", false, "Enable Lease based leader election, so that only one replica processes Certificates.")
+	flag.String("LeaderElectionNamespace", DefaultLeaderElectionNamespace, "Namespace of the leader election Lease.")
+	flag.String("LeaderElectionLeaseName", DefaultLeaderElectionLeaseName, "Name of the leader election Lease.")
+	flag.Duration("LeaderElectionLeaseDuration", DefaultLeaderElectionLeaseDuration, "Duration that followers wait before trying to acquire a Lease that is not renewed.")
+	flag.Duration("LeaderElectionRenewDeadline", DefaultLeaderElectionRenewDeadline, "Duration that the leader retries renewing the Lease before giving up leadership.")
+	flag.Duration("LeaderElectionRetryPeriod", DefaultLeaderElectionRetryPeriod, "Duration between two attempts to acquire or renew the Lease.")
 
 	// Setting up default configuration
 	viper.SetDefault("KubeconfigPath", "")
@@ -54,6 +271,76 @@ func LoadConfig() (*Configuration, error) {
 	viper.SetDefault("SigningCacert", "")
 	viper.SetDefault("SigningCacertKey", "")
 	viper.SetDefault("SigningCacertKeyPass", "")
+	viper.SetDefault("SigningCacertKeyPassFile", "")
+	viper.SetDefault("SigningCacertReloadInterval", DefaultSigningCAReloadInterval)
+	viper.SetDefault("SigningCaSecret", "")
+	viper.SetDefault("SigningCaSecretPassKey", DefaultSigningCASecretPassKey)
+	viper.SetDefault("SigningRemoteSigner", "")
+	viper.SetDefault("SigningRemoteSignerTLSCert", "")
+	viper.SetDefault("SigningRemoteSignerTLSKey", "")
+	viper.SetDefault("SigningRemoteSignerTLSCA", "")
+	viper.SetDefault("SigningRemoteSignerTimeout", DefaultSigningRemoteSignerTimeout)
+	viper.SetDefault("TrustedCAs", []string{})
+	viper.SetDefault("VaultAddress", "")
+	viper.SetDefault("VaultPKIMount", DefaultVaultPKIMount)
+	viper.SetDefault("VaultRole", "")
+	viper.SetDefault("VaultTokenFile", "")
+	viper.SetDefault("VaultKubernetesAuthMount", DefaultVaultKubernetesAuthMount)
+	viper.SetDefault("VaultKubernetesAuthRole", "")
+	viper.SetDefault("VaultCACert", "")
+	viper.SetDefault("VaultTimeout", DefaultVaultTimeout)
+	viper.SetDefault("VaultRefreshInterval", DefaultVaultRefreshInterval)
+	viper.SetDefault("SigningSignatureAlgorithm", "")
+	viper.SetDefault("TokenSigningKey", "")
+	viper.SetDefault("TokenSigningKeyPass", "")
+	viper.SetDefault("TokenSigningKeyPassFile", "")
+	viper.SetDefault("TokenValidity", 0)
+	viper.SetDefault("TokenStaticTags", []string{})
+	viper.SetDefault("TokenLabelKeys", []string{})
+	viper.SetDefault("TokenAnnotationKeys", []string{})
+	viper.SetDefault("TokenSubjectFields", []string{})
+	viper.SetDefault("TokenSANs", []string{})
+	viper.SetDefault("TokenAllowedKeys", []string{})
+	viper.SetDefault("TokenDeniedKeys", []string{})
+
+	viper.SetDefault("Workers", DefaultWorkers)
+
+	viper.SetDefault("MinRSAKeySize", DefaultMinRSAKeySize)
+	viper.SetDefault("CertificateDuration", DefaultCertificateDuration)
+	viper.SetDefault("MaxCertificateDuration", DefaultCertificateDuration)
+
+	viper.SetDefault("ApprovalRequired", false)
+	viper.SetDefault("AutoApproveProfiles", []string{})
+	viper.SetDefault("ApproverKeyFile", "")
+	viper.SetDefault("ApprovalWebhookAddress", DefaultApprovalWebhookAddress)
+	viper.SetDefault("ApprovalWebhookTLSCert", "")
+	viper.SetDefault("ApprovalWebhookTLSKey", "")
+
+	viper.SetDefault("MetricsAddress", DefaultMetricsAddress)
+	viper.SetDefault("HealthAddress", DefaultHealthAddress)
+	viper.SetDefault("CRLAddress", DefaultCRLAddress)
+	viper.SetDefault("CRLUpdateInterval", DefaultCRLUpdateInterval)
+	viper.SetDefault("RevocationConfigMap", DefaultRevocationConfigMap)
+
+	viper.SetDefault("OCSPAddress", DefaultOCSPAddress)
+	viper.SetDefault("OCSPResponderURL", "")
+	viper.SetDefault("OCSPResponseValidity", DefaultOCSPResponseValidity)
+	viper.SetDefault("OCSPSigningCert", "")
+	viper.SetDefault("OCSPSigningCertKey", "")
+	viper.SetDefault("OCSPSigningCertKeyPass", "")
+	viper.SetDefault("OCSPSigningCertKeyPassFile", "")
+
+	viper.SetDefault("PolicyFile", "")
+	viper.SetDefault("PolicyConfigMap", "")
+	viper.SetDefault("PolicyConfigMapKey", DefaultPolicyConfigMapKey)
+	viper.SetDefault("PolicyReloadInterval", DefaultPolicyReloadInterval)
+
+	viper.SetDefault("LeaderElection", false)
+	viper.SetDefault("LeaderElectionNamespace", DefaultLeaderElectionNamespace)
+	viper.SetDefault("LeaderElectionLeaseName", DefaultLeaderElectionLeaseName)
+	viper.SetDefault("LeaderElectionLeaseDuration", DefaultLeaderElectionLeaseDuration)
+	viper.SetDefault("LeaderElectionRenewDeadline", DefaultLeaderElectionRenewDeadline)
+	viper.SetDefault("LeaderElectionRetryPeriod", DefaultLeaderElectionRetryPeriod)
 
 	// Binding ENV variables
 	// Each config will be of format TRIREME_XYZ as env variable, where XYZ
@@ -92,6 +379,160 @@ func validateConfig(config *Configuration) error {
 		config.KubeconfigPath = ""
 	}
 
+	if config.Workers < 1 {
+		return fmt.Errorf("invalid number of workers: %d", config.Workers)
+	}
+
+	if config.MinRSAKeySize < DefaultMinRSAKeySize {
+		return fmt.Errorf("invalid minimum RSA key size: %d is smaller than %d", config.MinRSAKeySize, DefaultMinRSAKeySize)
+	}
+
+	if config.CertificateDuration <= 0 || config.MaxCertificateDuration <= 0 {
+		return fmt.Errorf("invalid certificate durations: %s and %s", config.CertificateDuration, config.MaxCertificateDuration)
+	}
+	if config.CertificateDuration > config.MaxCertificateDuration {
+		return fmt.Errorf("certificate duration %s must not be greater than the maximum of %s", config.CertificateDuration, config.MaxCertificateDuration)
+	}
+
+	if config.TokenValidity < 0 {
+		return fmt.Errorf("invalid token validity: %s", config.TokenValidity)
+	}
+
+	if config.CRLUpdateInterval <= 0 {
+		return fmt.Errorf("invalid CRL update interval: %s", config.CRLUpdateInterval)
+	}
+	if parts := strings.Split(config.RevocationConfigMap, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("invalid revocation ConfigMap '%s': must be namespace/name", config.RevocationConfigMap)
+	}
+
+	if config.OCSPResponseValidity <= 0 {
+		return fmt.Errorf("invalid OCSP response validity: %s", config.OCSPResponseValidity)
+	}
+	if (config.OCSPSigningCert == "") != (config.OCSPSigningCertKey == "") {
+		return fmt.Errorf("OCSP signing certificate and key must be configured together")
+	}
+
+	if config.PolicyFile != "" && config.PolicyConfigMap != "" {
+		return fmt.Errorf("issuance policy must be configured either from a file or from a ConfigMap")
+	}
+	if config.PolicyReloadInterval <= 0 {
+		return fmt.Errorf("invalid policy reload interval: %s", config.PolicyReloadInterval)
+	}
+	if config.PolicyConfigMap != "" {
+		if parts := strings.Split(config.PolicyConfigMap, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid policy ConfigMap '%s': must be namespace/name", config.PolicyConfigMap)
+		}
+	}
+
+	if config.LeaderElection {
+		if config.LeaderElectionNamespace == "" || config.LeaderElectionLeaseName == "" {
+			return fmt.Errorf("leader election requires a Lease namespace and name")
+		}
+		if config.LeaderElectionLeaseDuration <= config.LeaderElectionRenewDeadline {
+			return fmt.Errorf("leader election lease duration must be greater than the renew deadline")
+		}
+		if config.LeaderElectionRenewDeadline <= config.LeaderElectionRetryPeriod {
+			return fmt.Errorf("leader election renew deadline must be greater than the retry period")
+		}
+	}
+
+	// approvals are only taken into account with a verified approver, which is recorded by the approval webhook
+	if config.ApprovalRequired && config.ApproverKeyFile == "" {
+		return fmt.Errorf("approvals require an approver key file, so that the approvers of CertificateApprovals can be verified")
+	}
+	if config.ApproverKeyFile != "" {
+		key, err := ioutil.ReadFile(config.ApproverKeyFile)
+		if err != nil {
+			return fmt.Errorf("unable to read approver key file: %s", err.Error())
+		}
+		config.ApproverKey = []byte(strings.TrimSpace(string(key)))
+		if len(config.ApproverKey) < MinApproverKeySize {
+			return fmt.Errorf("approver key must be at least %d bytes long", MinApproverKeySize)
+		}
+		if config.ApprovalWebhookAddress != "" && (config.ApprovalWebhookTLSCert == "" || config.ApprovalWebhookTLSKey == "") {
+			return fmt.Errorf("approval webhook requires a TLS certificate and key")
+		}
+	}
+
+	passwords := []struct {
+		name string
+		pass *string
+		file string
+	}{
+		{name: "signing CA", pass: &config.SigningCACertKeyPass, file: config.SigningCACertKeyPassFile},
+		{name: "token signing key", pass: &config.TokenSigningKeyPass, file: config.TokenSigningKeyPassFile},
+		{name: "OCSP signing key", pass: &config.OCSPSigningCertKeyPass, file: config.OCSPSigningCertKeyPassFile},
+	}
+	for _, password := range passwords {
+		if err := readPassFile(password.name, password.pass, password.file); err != nil {
+			return err
+		}
+	}
+
+	if config.SigningCACertReloadInterval < 0 {
+		return fmt.Errorf("invalid signing CA reload interval: %s", config.SigningCACertReloadInterval)
+	}
+
+	// Vault signs with its own CA, so that no signing CA is read at all
+	if config.VaultAddress != "" {
+		if config.SigningCACert != "" || config.SigningCACertKey != "" || config.SigningCASecret != "" || config.SigningRemoteSigner != "" {
+			return fmt.Errorf("signing CA must not be configured with Vault")
+		}
+		if len(config.TrustedCAs) > 0 || config.SigningSignatureAlgorithm != "" || config.OCSPSigningCert != "" {
+			return fmt.Errorf("trusted CAs, signature algorithm and OCSP signing certificate are managed by Vault")
+		}
+		if config.VaultRole == "" {
+			return fmt.Errorf("Vault requires a PKI role")
+		}
+		if config.TokenSigningKey == "" {
+			return fmt.Errorf("Vault requires a token signing key, as the CA key stays in Vault")
+		}
+		if (config.VaultTokenFile == "") == (config.VaultKubernetesAuthRole == "") {
+			return fmt.Errorf("Vault authentication must be configured either with a token file or with a Kubernetes auth role")
+		}
+		if config.VaultTimeout <= 0 || config.VaultRefreshInterval <= 0 {
+			return fmt.Errorf("invalid Vault timeout %s or refresh interval %s", config.VaultTimeout, config.VaultRefreshInterval)
+		}
+		if config.VaultTokenFile != "" {
+			token, err := ioutil.ReadFile(config.VaultTokenFile)
+			if err != nil {
+				return fmt.Errorf("unable to read Vault token file: %s", err.Error())
+			}
+			config.VaultToken = strings.TrimSpace(string(token))
+		}
+		return nil
+	}
+
+	// the signing CA key stays in the signing service, only the CA certificate is read
+	if config.SigningRemoteSigner != "" {
+		if config.SigningCACertKey != "" || config.SigningCASecret != "" {
+			return fmt.Errorf("signing CA key must not be configured with a remote signer")
+		}
+		if config.SigningRemoteSignerTLSCert == "" || config.SigningRemoteSignerTLSKey == "" || config.SigningRemoteSignerTLSCA == "" {
+			return fmt.Errorf("remote signer requires a TLS client certificate, key and CA")
+		}
+		if config.SigningRemoteSignerTimeout <= 0 {
+			return fmt.Errorf("invalid remote signer timeout: %s", config.SigningRemoteSignerTimeout)
+		}
+		signingcadata, err := ioutil.ReadFile(config.SigningCACert)
+		if err != nil {
+			return fmt.Errorf("unable to read signing CA file: %s", err.Error())
+		}
+		config.SigningCACertData = signingcadata
+		return nil
+	}
+
+	// the signing CA is read from its Secret through the Kubernetes API instead of from files
+	if config.SigningCASecret != "" {
+		if config.SigningCACert != "" || config.SigningCACertKey != "" {
+			return fmt.Errorf("signing CA must be configured either from files or from a Secret")
+		}
+		if parts := strings.Split(config.SigningCASecret, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid signing CA Secret '%s': must be namespace/name", config.SigningCASecret)
+		}
+		return nil
+	}
+
 	signingcadata, err := ioutil.ReadFile(config.SigningCACert)
 	if err != nil {
 		return fmt.Errorf("unable to read signing CA file: %s", err.Error())
@@ -107,3 +548,21 @@ func validateConfig(config *Configuration) error {
 
 	return nil
 }
+
+// readPassFile sets the password of the key with the given name from the file, if one is configured.
+// The password must not be configured directly as well.
+func readPassFile(name string, pass *string, passFile string) error {
+	if passFile == "" {
+		return nil
+	}
+	if *pass != "" {
+		return fmt.Errorf("%s password must be configured either directly or from a file", name)
+	}
+	data, err := ioutil.ReadFile(passFile)
+	if err != nil {
+		return fmt.Errorf("unable to read %s password file: %s", name, err.Error())
+	}
+	// files usually end with a newline, which is never part of the password
+	*pass = strings.TrimRight(string(data), "\r\n")
+	return nil
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadPassFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "passfile")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		data    string
+		pass    string
+		want    string
+		wantErr bool
+	}{
+		{name: "newline", data: "correct horse\n", want: "correct horse"},
+		{name: "crlf", data: "correct horse\r\n", want: "correct horse"},
+		{name: "no newline", data: " correct horse ", want: " correct horse "},
+		{name: "both configured", data: "correct horse\n", pass: "other", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passFile := filepath.Join(dir, tt.name)
+			if err := ioutil.WriteFile(passFile, []byte(tt.data), 0600); err != nil {
+				t.Fatalf("failed to write password file: %s", err)
+			}
+
+			pass := tt.pass
+			err := readPassFile("test key", &pass, passFile)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("readPassFile() succeeded with a password configured directly as well")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readPassFile() error = %s", err)
+			}
+			if pass != tt.want {
+				t.Errorf("readPassFile() = %q, want %q", pass, tt.want)
+			}
+		})
+	}
+
+	pass := "direct"
+	if err := readPassFile("test key", &pass, ""); err != nil || pass != "direct" {
+		t.Errorf("readPassFile() without a file = %q, %v, want the direct password", pass, err)
+	}
+	if err := readPassFile("test key", new(string), filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("readPassFile() succeeded with a missing file")
+	}
+}
+
+func TestValidateConfigPassFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "passfile")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	passFile := filepath.Join(dir, "pass")
+	if err := ioutil.WriteFile(passFile, []byte("correct horse\n"), 0600); err != nil {
+		t.Fatalf("failed to write password file: %s", err)
+	}
+
+	config := &Configuration{
+		Workers:                    DefaultWorkers,
+		MinRSAKeySize:              DefaultMinRSAKeySize,
+		CertificateDuration:        DefaultCertificateDuration,
+		MaxCertificateDuration:     DefaultCertificateDuration,
+		CRLUpdateInterval:          DefaultCRLUpdateInterval,
+		RevocationConfigMap:        DefaultRevocationConfigMap,
+		OCSPResponseValidity:       DefaultOCSPResponseValidity,
+		PolicyReloadInterval:       DefaultPolicyReloadInterval,
+		SigningCACertKeyPassFile:   passFile,
+		TokenSigningKeyPassFile:    passFile,
+		OCSPSigningCertKeyPassFile: passFile,
+	}
+	// the validation fails later on, as no signing CA is configured, but the passwords have been read by then
+	_ = validateConfig(config)
+	for name, pass := range map[string]string{
+		"SigningCACertKeyPass":   config.SigningCACertKeyPass,
+		"TokenSigningKeyPass":    config.TokenSigningKeyPass,
+		"OCSPSigningCertKeyPass": config.OCSPSigningCertKeyPass,
+	} {
+		if pass != "correct horse" {
+			t.Errorf("%s = %q, want the password of the file", name, pass)
+		}
+	}
+}
+
+func TestValidateConfigApproverKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "approverkey")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := strings.Repeat("k", MinApproverKeySize)
+	keyFile := filepath.Join(dir, "key")
+	if err := ioutil.WriteFile(keyFile, []byte(key+"\n"), 0600); err != nil {
+		t.Fatalf("failed to write key file: %s", err)
+	}
+	shortKeyFile := filepath.Join(dir, "short")
+	if err := ioutil.WriteFile(shortKeyFile, []byte("short"), 0600); err != nil {
+		t.Fatalf("failed to write key file: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		update  func(config *Configuration)
+		wantErr bool
+	}{
+		{
+			name:   "no approvals",
+			update: func(config *Configuration) {},
+		},
+		{
+			name:    "approvals without a key",
+			update:  func(config *Configuration) { config.ApprovalRequired = true },
+			wantErr: true,
+		},
+		{
+			name: "approvals with a key and the webhook",
+			update: func(config *Configuration) {
+				config.ApprovalRequired = true
+				config.ApproverKeyFile = keyFile
+				config.ApprovalWebhookTLSCert = "tls.crt"
+				config.ApprovalWebhookTLSKey = "tls.key"
+			},
+		},
+		{
+			name: "key with the webhook served elsewhere",
+			update: func(config *Configuration) {
+				config.ApproverKeyFile = keyFile
+				config.ApprovalWebhookAddress = ""
+			},
+		},
+		{
+			name: "webhook without TLS",
+			update: func(config *Configuration) {
+				config.ApproverKeyFile = keyFile
+			},
+			wantErr: true,
+		},
+		{
+			name: "short key",
+			update: func(config *Configuration) {
+				config.ApproverKeyFile = shortKeyFile
+				config.ApprovalWebhookTLSCert = "tls.crt"
+				config.ApprovalWebhookTLSKey = "tls.key"
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing key file",
+			update: func(config *Configuration) {
+				config.ApproverKeyFile = filepath.Join(dir, "missing")
+				config.ApprovalWebhookAddress = ""
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Configuration{
+				Workers:                DefaultWorkers,
+				MinRSAKeySize:          DefaultMinRSAKeySize,
+				CertificateDuration:    DefaultCertificateDuration,
+				MaxCertificateDuration: DefaultCertificateDuration,
+				CRLUpdateInterval:      DefaultCRLUpdateInterval,
+				RevocationConfigMap:    DefaultRevocationConfigMap,
+				OCSPResponseValidity:   DefaultOCSPResponseValidity,
+				PolicyReloadInterval:   DefaultPolicyReloadInterval,
+				ApprovalWebhookAddress: DefaultApprovalWebhookAddress,
+				SigningCASecret:        "default/signing-ca",
+			}
+			tt.update(config)
+			err := validateConfig(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && config.ApproverKeyFile != "" && string(config.ApproverKey) != key {
+				t.Errorf("ApproverKey = %q, want the key of the file", config.ApproverKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	certificatev1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+)
+
+// AutoApprovedReason is the reason of the approvals given by the controller itself
+const AutoApprovedReason = "AutoApproved"
+
+// ApprovalPolicy defines which Cert requests need a manual approval before they get signed.
+type ApprovalPolicy struct {
+	// Required enables the approval gate. If it is not set, all requests are approved automatically.
+	Required bool
+	// AutoApproveProfiles lists the profiles whose requests are approved automatically.
+	AutoApproveProfiles []string
+	// ApproverKey verifies the approvers that the approval webhook recorded on CertificateApprovals. Without it,
+	// no CertificateApproval is taken into account.
+	ApproverKey []byte
+}
+
+// isAutoApproved returns true if requests for the profile do not need a manual approval
+func (p ApprovalPolicy) isAutoApproved(profile string) bool {
+	if !p.Required {
+		return true
+	}
+	for _, autoApproveProfile := range p.AutoApproveProfiles {
+		if autoApproveProfile == profile {
+			return true
+		}
+	}
+	return false
+}
+
+// approvalDecision is the state of the approval of a Cert request
+type approvalDecision int
+
+const (
+	approvalPending approvalDecision = iota
+	approvalApproved
+	approvalDenied
+)
+
+// getApproval returns the approval decision for the Cert request, and the condition that it is based on.
+// Decisions are only taken from the CertificateApproval of the same name, or of the Certificate that a renewal
+// renews, and only if it has been taken for the current request. Approvers are authorized by being allowed to
+// create CertificateApprovals, and never need to write the status of Cert requests. The recorded approver is the
+// authenticated user that the approval webhook signed into the CertificateApproval, which is ignored otherwise.
+func (c *CertificateController) getApproval(certRequest *certificatev1alpha2.Certificate) (approvalDecision, *certificatev1alpha2.CertificateCondition) {
+	names := []string{certRequest.Name}
+	if name := requestName(certRequest); name != certRequest.Name {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		approval, err := c.approvalLister.Get(name)
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				zap.L().Error("Error getting CertificateApproval", zap.Error(err), zap.String("name", name))
+			}
+			continue
+		}
+		if approval.Spec.RequestHash != certRequest.Spec.GetRequestHash() {
+			continue
+		}
+		if err := verifyApproval(c.approvalPolicy.ApproverKey, approval); err != nil {
+			zap.L().Warn("Ignoring CertificateApproval with an unverified approver", zap.Error(err), zap.String("name", approval.Name), zap.String("approver", approval.Spec.Approver))
+			c.recorder.Eventf(certRequest, corev1.EventTypeWarning, EventReasonInvalidApproval, "CertificateApproval '%s' is ignored: %s", approval.Name, err.Error())
+			continue
+		}
+		condition := &certificatev1alpha2.CertificateCondition{
+			Type:           approval.Spec.Decision,
+			Reason:         approval.Spec.Reason,
+			Message:        approval.Spec.Message,
+			Approver:       approval.Spec.Approver,
+			LastUpdateTime: approval.CreationTimestamp,
+		}
+		switch approval.Spec.Decision {
+		case certificatev1alpha2.CertificateDenied:
+			return approvalDenied, condition
+		case certificatev1alpha2.CertificateApproved:
+			return approvalApproved, condition
+		}
+		zap.L().Warn("CertificateApproval has an unknown decision", zap.String("name", approval.Name), zap.String("decision", string(approval.Spec.Decision)))
+	}
+	if c.approvalPolicy.isAutoApproved(certRequest.Spec.Profile) {
+		return approvalApproved, &certificatev1alpha2.CertificateCondition{
+			Type:           certificatev1alpha2.CertificateApproved,
+			Reason:         AutoApprovedReason,
+			Message:        "The request has been approved automatically by the policy of its profile",
+			Approver:       controllerAgentName,
+			LastUpdateTime: metav1.Now(),
+		}
+	}
+	return approvalPending, nil
+}
+
+// onApproval queues the Cert request that a CertificateApproval has been created or updated for, and the
+// renewals of the Certificate of the same name
+func (c *CertificateController) onApproval(obj interface{}) {
+	approval, ok := obj.(*certificatev1alpha2.CertificateApproval)
+	if !ok {
+		zap.L().Sugar().Errorf("Received wrong object type in CertificateApproval event: '%T", obj)
+		return
+	}
+	c.queue.Add(approval.Name)
+
+	renewals, err := c.certificateLister.List(labels.SelectorFromSet(labels.Set{certificatev1alpha2.RenewalOfLabel: approval.Name}))
+	if err != nil {
+		zap.L().Error("Error listing renewals", zap.Error(err), zap.String("name", approval.Name))
+		return
+	}
+	for _, renewal := range renewals {
+		c.enqueue(renewal)
+	}
+}
+
+// updateCertDenied is called when the request has been denied by an approver
+func (c *CertificateController) updateCertDenied(certRequestObj *certificatev1alpha2.Certificate, denial *certificatev1alpha2.CertificateCondition) error {
+	message := fmt.Sprintf("Request has been denied by '%s': %s: %s", denial.Approver, denial.Reason, denial.Message)
+	reason := certificatev1alpha2.StatusReasonProcessedRejectedDenied
+	return c.updateStatus(certRequestObj, corev1.EventTypeWarning, reason, message, func(certRequest *certificatev1alpha2.Certificate) {
+		certRequest.Status.Phase = certificatev1alpha2.CertificateRejected
+		certRequest.Status.RequestHash = certRequest.Spec.GetRequestHash()
+		certRequest.Status.Conditions = []certificatev1alpha2.CertificateCondition{*denial}
+		certRequest.Status.Reason = reason
+		certRequest.Status.Message = message
+	})
+}
+
+func (c *CertificateController) updateCertPending(certRequestObj *certificatev1alpha2.Certificate) error {
+	message := "The request is valid and waits for an approval before it gets signed."
+	return c.updateStatus(certRequestObj, corev1.EventTypeNormal, EventReasonPendingApproval, message, func(certRequest *certificatev1alpha2.Certificate) {
+		certRequest.Status.Phase = certificatev1alpha2.CertificatePending
+		certRequest.Status.RequestHash = certRequest.Spec.GetRequestHash()
+		certRequest.Status.Reason = certificatev1alpha2.StatusReasonPendingApproval
+		certRequest.Status.Message = message
+	})
+}
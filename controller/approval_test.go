@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	certificatev1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+	certificatelisterv1alpha2 "github.com/CodingJzy/trireme-csr/pkg/client/listers/certmanager.k8s.io/v1alpha2"
+)
+
+// testApproverKey is the key that the approvers of the test CertificateApprovals are signed with
+var testApproverKey = []byte("0123456789abcdef0123456789abcdef")
+
+// newApprovalTestController returns a controller that requires approvals, with the given Cert requests and approvals
+func newApprovalTestController(t *testing.T, certRequests []*certificatev1alpha2.Certificate, approvals []*certificatev1alpha2.CertificateApproval) *CertificateController {
+	t.Helper()
+
+	certIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, certRequest := range certRequests {
+		if err := certIndexer.Add(certRequest); err != nil {
+			t.Fatalf("failed to add Cert request: %s", err)
+		}
+	}
+	approvalIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, approval := range approvals {
+		if err := approvalIndexer.Add(approval); err != nil {
+			t.Fatalf("failed to add CertificateApproval: %s", err)
+		}
+	}
+
+	return &CertificateController{
+		certificateLister: certificatelisterv1alpha2.NewCertificateLister(certIndexer),
+		approvalLister:    certificatelisterv1alpha2.NewCertificateApprovalLister(approvalIndexer),
+		approvalPolicy:    ApprovalPolicy{Required: true, ApproverKey: testApproverKey},
+		queue:             workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		recorder:          record.NewFakeRecorder(100),
+	}
+}
+
+// newTestCertRequest returns a Cert request with the given name and renewal label for a request
+func newTestCertRequest(name, renewalOf, request string) *certificatev1alpha2.Certificate {
+	certRequest := &certificatev1alpha2.Certificate{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       certificatev1alpha2.CertificateSpec{Request: []byte(request)},
+	}
+	if renewalOf != "" {
+		certRequest.Labels = map[string]string{certificatev1alpha2.RenewalOfLabel: renewalOf}
+	}
+	return certRequest
+}
+
+// newTestApproval returns the decision for the Cert request under the given name, with an approver that has been
+// recorded by the approval webhook
+func newTestApproval(name string, certRequest *certificatev1alpha2.Certificate, decision certificatev1alpha2.CertificateConditionType) *certificatev1alpha2.CertificateApproval {
+	approval := &certificatev1alpha2.CertificateApproval{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: certificatev1alpha2.CertificateApprovalSpec{
+			Decision:    decision,
+			RequestHash: certRequest.Spec.GetRequestHash(),
+			Approver:    "admin",
+		},
+	}
+	approval.Annotations = map[string]string{certificatev1alpha2.ApproverSignatureAnnotation: signApproval(testApproverKey, approval)}
+	return approval
+}
+
+func TestGetApprovalRenewal(t *testing.T) {
+	renewal := newTestCertRequest("node-1", "node", "renewal")
+
+	tests := []struct {
+		name      string
+		approvals []*certificatev1alpha2.CertificateApproval
+		want      approvalDecision
+	}{
+		{
+			name: "no approval",
+			want: approvalPending,
+		},
+		{
+			name:      "approval of the renewal",
+			approvals: []*certificatev1alpha2.CertificateApproval{newTestApproval("node-1", renewal, certificatev1alpha2.CertificateApproved)},
+			want:      approvalApproved,
+		},
+		{
+			name:      "approval of the renewed certificate",
+			approvals: []*certificatev1alpha2.CertificateApproval{newTestApproval("node", renewal, certificatev1alpha2.CertificateApproved)},
+			want:      approvalApproved,
+		},
+		{
+			name:      "denial of the renewed certificate",
+			approvals: []*certificatev1alpha2.CertificateApproval{newTestApproval("node", renewal, certificatev1alpha2.CertificateDenied)},
+			want:      approvalDenied,
+		},
+		{
+			name:      "approval of the renewed certificate for another request",
+			approvals: []*certificatev1alpha2.CertificateApproval{newTestApproval("node", newTestCertRequest("node", "", "original"), certificatev1alpha2.CertificateApproved)},
+			want:      approvalPending,
+		},
+		{
+			name: "approval without a recorded approver",
+			approvals: []*certificatev1alpha2.CertificateApproval{func() *certificatev1alpha2.CertificateApproval {
+				approval := newTestApproval("node-1", renewal, certificatev1alpha2.CertificateApproved)
+				approval.Annotations = nil
+				return approval
+			}()},
+			want: approvalPending,
+		},
+		{
+			name: "approval with a claimed approver",
+			approvals: []*certificatev1alpha2.CertificateApproval{func() *certificatev1alpha2.CertificateApproval {
+				approval := newTestApproval("node-1", renewal, certificatev1alpha2.CertificateApproved)
+				approval.Spec.Approver = "security-officer"
+				return approval
+			}()},
+			want: approvalPending,
+		},
+		{
+			name: "denial turned into an approval",
+			approvals: []*certificatev1alpha2.CertificateApproval{func() *certificatev1alpha2.CertificateApproval {
+				approval := newTestApproval("node-1", renewal, certificatev1alpha2.CertificateDenied)
+				approval.Spec.Decision = certificatev1alpha2.CertificateApproved
+				return approval
+			}()},
+			want: approvalPending,
+		},
+		{
+			name:      "approval of another certificate",
+			approvals: []*certificatev1alpha2.CertificateApproval{newTestApproval("other", renewal, certificatev1alpha2.CertificateApproved)},
+			want:      approvalPending,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newApprovalTestController(t, nil, tt.approvals)
+			got, condition := c.getApproval(renewal)
+			if got != tt.want {
+				t.Errorf("getApproval() = %d, want %d", got, tt.want)
+			}
+			if condition != nil && condition.Approver != "admin" {
+				t.Errorf("getApproval() approver = '%s', want the recorded approver 'admin'", condition.Approver)
+			}
+		})
+	}
+}
+
+func TestOnApprovalQueuesRenewals(t *testing.T) {
+	original := newTestCertRequest("node", "", "original")
+	renewal := newTestCertRequest("node-1", "node", "renewal")
+	other := newTestCertRequest("other-1", "other", "other")
+	c := newApprovalTestController(t, []*certificatev1alpha2.Certificate{original, renewal, other}, nil)
+
+	c.onApproval(newTestApproval("node", renewal, certificatev1alpha2.CertificateApproved))
+
+	queued := map[string]bool{}
+	for c.queue.Len() > 0 {
+		key, _ := c.queue.Get()
+		queued[key.(string)] = true
+		c.queue.Done(key)
+	}
+	if len(queued) != 2 || !queued["node"] || !queued["node-1"] {
+		t.Errorf("onApproval() queued %v, want node and node-1", queued)
+	}
+}
+
+func TestIssuerAllowsRenewal(t *testing.T) {
+	spec := &certificatev1alpha2.IssuerSpec{AllowedCertificates: []string{"node"}}
+
+	if !issuerAllows(spec, newTestCertRequest("node-1", "node", "renewal")) {
+		t.Errorf("issuerAllows() = false for a renewal of an allowed certificate")
+	}
+	if issuerAllows(spec, newTestCertRequest("node-1", "", "request")) {
+		t.Errorf("issuerAllows() = true for a certificate that is not allowed")
+	}
+	if !issuerAllows(nil, newTestCertRequest("node-1", "", "request")) {
+		t.Errorf("issuerAllows() = false for the default issuer")
+	}
+}
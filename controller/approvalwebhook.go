@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	certificatev1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+)
+
+// maxAdmissionReviewSize is the maximum size of an AdmissionReview that the approval webhook accepts.
+const maxAdmissionReviewSize = 1024 * 1024
+
+// signApproval returns the signature of the decision and the approver of the CertificateApproval with the key
+func signApproval(key []byte, approval *certificatev1alpha2.CertificateApproval) string {
+	// the fields are encoded as a JSON array, so that they can not be shifted into each other
+	fields, _ := json.Marshal([]string{
+		approval.Name,
+		approval.Spec.RequestHash,
+		string(approval.Spec.Decision),
+		approval.Spec.Reason,
+		approval.Spec.Message,
+		approval.Spec.Approver,
+	})
+	mac := hmac.New(sha256.New, key)
+	mac.Write(fields)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyApproval returns an error if the approver of the CertificateApproval has not been signed with the key,
+// which is the case if it has not been recorded by the approval webhook, or has been changed since.
+func verifyApproval(key []byte, approval *certificatev1alpha2.CertificateApproval) error {
+	if len(key) == 0 {
+		return fmt.Errorf("no approver key is configured to verify approvers")
+	}
+	signature, ok := approval.Annotations[certificatev1alpha2.ApproverSignatureAnnotation]
+	if !ok {
+		return fmt.Errorf("approver has not been recorded by the approval webhook")
+	}
+	if !hmac.Equal([]byte(signature), []byte(signApproval(key, approval))) {
+		return fmt.Errorf("approver signature does not match the approval")
+	}
+	return nil
+}
+
+// jsonPatchOperation is an operation of an RFC 6902 JSON patch
+type jsonPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// ApprovalWebhook returns the handler of a mutating admission webhook for CertificateApprovals. It records the
+// authenticated user of the request as the approver, and signs the decision and the approver with the key, so that
+// the controller only takes decisions whose approver it can verify. It must be registered for the creation and the
+// update of CertificateApprovals, and served over TLS.
+func ApprovalWebhook(key []byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxAdmissionReviewSize))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("unable to read AdmissionReview: %s", err), http.StatusBadRequest)
+			return
+		}
+		review := &admissionv1beta1.AdmissionReview{}
+		if err := json.Unmarshal(body, review); err != nil || review.Request == nil {
+			http.Error(w, "invalid AdmissionReview", http.StatusBadRequest)
+			return
+		}
+
+		review.Response = admitApproval(key, review.Request)
+		review.Response.UID = review.Request.UID
+		review.Request = nil
+		response, err := json.Marshal(review)
+		if err != nil {
+			zap.L().Error("Error encoding AdmissionReview", zap.Error(err))
+			http.Error(w, "unable to encode AdmissionReview", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(response)
+	})
+}
+
+// admitApproval admits the creation or update of a CertificateApproval with a patch that records and signs its approver
+func admitApproval(key []byte, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	if request.Operation != admissionv1beta1.Create && request.Operation != admissionv1beta1.Update {
+		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+	}
+
+	approval := &certificatev1alpha2.CertificateApproval{}
+	if err := json.Unmarshal(request.Object.Raw, approval); err != nil {
+		return deniedResponse(fmt.Sprintf("invalid CertificateApproval: %s", err))
+	}
+	if request.UserInfo.Username == "" {
+		return deniedResponse("CertificateApprovals can only be written by authenticated users")
+	}
+
+	approval.Spec.Approver = request.UserInfo.Username
+	annotations := map[string]string{}
+	for k, v := range approval.Annotations {
+		annotations[k] = v
+	}
+	annotations[certificatev1alpha2.ApproverSignatureAnnotation] = signApproval(key, approval)
+
+	// add replaces members that already exist
+	patch, err := json.Marshal([]jsonPatchOperation{
+		{Op: "add", Path: "/metadata/annotations", Value: annotations},
+		{Op: "add", Path: "/spec/approver", Value: approval.Spec.Approver},
+	})
+	if err != nil {
+		return deniedResponse(fmt.Sprintf("unable to encode patch: %s", err))
+	}
+	zap.L().Info("Recording approver of CertificateApproval", zap.String("name", approval.Name), zap.String("approver", approval.Spec.Approver), zap.String("decision", string(approval.Spec.Decision)))
+
+	patchType := admissionv1beta1.PatchTypeJSONPatch
+	return &admissionv1beta1.AdmissionResponse{
+		Allowed:   true,
+		Patch:     patch,
+		PatchType: &patchType,
+	}
+}
+
+func deniedResponse(message string) *admissionv1beta1.AdmissionResponse {
+	return &admissionv1beta1.AdmissionResponse{
+		Allowed: false,
+		Result:  &metav1.Status{Message: message},
+	}
+}
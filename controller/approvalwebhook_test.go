@@ -0,0 +1,201 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	certificatev1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+)
+
+// reviewApproval sends the operation on the CertificateApproval by the user to the approval webhook, and returns
+// its response
+func reviewApproval(t *testing.T, approval *certificatev1alpha2.CertificateApproval, operation admissionv1beta1.Operation, username string) *admissionv1beta1.AdmissionResponse {
+	t.Helper()
+
+	raw, err := json.Marshal(approval)
+	if err != nil {
+		t.Fatalf("failed to encode CertificateApproval: %s", err)
+	}
+	body, err := json.Marshal(&admissionv1beta1.AdmissionReview{
+		Request: &admissionv1beta1.AdmissionRequest{
+			UID:       types.UID("review"),
+			Operation: operation,
+			UserInfo:  authenticationv1.UserInfo{Username: username},
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode AdmissionReview: %s", err)
+	}
+
+	w := httptest.NewRecorder()
+	ApprovalWebhook(testApproverKey).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("approval webhook returned status %d", w.Code)
+	}
+	review := &admissionv1beta1.AdmissionReview{}
+	if err := json.Unmarshal(w.Body.Bytes(), review); err != nil {
+		t.Fatalf("failed to decode AdmissionReview: %s", err)
+	}
+	if review.Response == nil {
+		t.Fatalf("approval webhook returned no response")
+	}
+	if review.Response.UID != "review" {
+		t.Errorf("response UID = '%s', want the UID of the request", review.Response.UID)
+	}
+	return review.Response
+}
+
+// applyApprovalPatch applies the patch of the approval webhook to the CertificateApproval. It only supports the
+// operations that the webhook creates.
+func applyApprovalPatch(t *testing.T, approval *certificatev1alpha2.CertificateApproval, response *admissionv1beta1.AdmissionResponse) {
+	t.Helper()
+
+	if response.PatchType == nil || *response.PatchType != admissionv1beta1.PatchTypeJSONPatch {
+		t.Fatalf("response has no JSON patch")
+	}
+	var operations []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(response.Patch, &operations); err != nil {
+		t.Fatalf("failed to decode patch: %s", err)
+	}
+	for _, operation := range operations {
+		if operation.Op != "add" {
+			t.Fatalf("unexpected patch operation '%s'", operation.Op)
+		}
+		var err error
+		switch operation.Path {
+		case "/metadata/annotations":
+			approval.Annotations = nil
+			err = json.Unmarshal(operation.Value, &approval.Annotations)
+		case "/spec/approver":
+			err = json.Unmarshal(operation.Value, &approval.Spec.Approver)
+		default:
+			t.Fatalf("unexpected patch path '%s'", operation.Path)
+		}
+		if err != nil {
+			t.Fatalf("failed to decode patch value of '%s': %s", operation.Path, err)
+		}
+	}
+}
+
+func TestApprovalWebhookRecordsApprover(t *testing.T) {
+	approval := &certificatev1alpha2.CertificateApproval{
+		ObjectMeta: metav1.ObjectMeta{Name: "node", Annotations: map[string]string{"team": "nodes"}},
+		Spec: certificatev1alpha2.CertificateApprovalSpec{
+			Decision:    certificatev1alpha2.CertificateApproved,
+			RequestHash: "hash",
+			Approver:    "security-officer",
+		},
+	}
+	if err := verifyApproval(testApproverKey, approval); err == nil {
+		t.Fatalf("verifyApproval() accepted an approval that has not been reviewed")
+	}
+
+	for _, operation := range []admissionv1beta1.Operation{admissionv1beta1.Create, admissionv1beta1.Update} {
+		t.Run(string(operation), func(t *testing.T) {
+			reviewed := approval.DeepCopy()
+			response := reviewApproval(t, reviewed, operation, "alice")
+			if !response.Allowed {
+				t.Fatalf("approval webhook denied the approval: %v", response.Result)
+			}
+			applyApprovalPatch(t, reviewed, response)
+
+			// the claimed approver is replaced by the authenticated user
+			if reviewed.Spec.Approver != "alice" {
+				t.Errorf("approver = '%s', want the authenticated user 'alice'", reviewed.Spec.Approver)
+			}
+			if reviewed.Annotations["team"] != "nodes" {
+				t.Errorf("patch dropped the other annotations: %v", reviewed.Annotations)
+			}
+			if err := verifyApproval(testApproverKey, reviewed); err != nil {
+				t.Errorf("verifyApproval() error = %s for a reviewed approval", err)
+			}
+			if err := verifyApproval([]byte("another key of at least 32 bytes"), reviewed); err == nil {
+				t.Errorf("verifyApproval() accepted an approval signed with another key")
+			}
+			if err := verifyApproval(nil, reviewed); err == nil {
+				t.Errorf("verifyApproval() accepted an approval without a key")
+			}
+
+			// any change after the review invalidates the approval
+			changes := map[string]func(approval *certificatev1alpha2.CertificateApproval){
+				"approver":     func(approval *certificatev1alpha2.CertificateApproval) { approval.Spec.Approver = "security-officer" },
+				"decision":     func(approval *certificatev1alpha2.CertificateApproval) { approval.Spec.Decision = "Denied" },
+				"request hash": func(approval *certificatev1alpha2.CertificateApproval) { approval.Spec.RequestHash = "other" },
+				"name":         func(approval *certificatev1alpha2.CertificateApproval) { approval.Name = "other" },
+			}
+			for name, change := range changes {
+				changed := reviewed.DeepCopy()
+				change(changed)
+				if err := verifyApproval(testApproverKey, changed); err == nil {
+					t.Errorf("verifyApproval() accepted an approval whose %s changed after the review", name)
+				}
+			}
+		})
+	}
+}
+
+func TestApprovalWebhookRequests(t *testing.T) {
+	approval := &certificatev1alpha2.CertificateApproval{ObjectMeta: metav1.ObjectMeta{Name: "node"}}
+
+	if response := reviewApproval(t, approval, admissionv1beta1.Create, ""); response.Allowed {
+		t.Errorf("approval webhook allowed an approval of an anonymous user")
+	}
+	if response := reviewApproval(t, approval, admissionv1beta1.Delete, "alice"); !response.Allowed || response.Patch != nil {
+		t.Errorf("approval webhook = allowed %v with patch %s for a deletion, want allowed without patch", response.Allowed, response.Patch)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		body   []byte
+		want   int
+	}{
+		{name: "GET", method: http.MethodGet, want: http.StatusMethodNotAllowed},
+		{name: "invalid AdmissionReview", method: http.MethodPost, body: []byte("{"), want: http.StatusBadRequest},
+		{name: "AdmissionReview without request", method: http.MethodPost, body: []byte("{}"), want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ApprovalWebhook(testApproverKey).ServeHTTP(w, httptest.NewRequest(tt.method, "/", bytes.NewReader(tt.body)))
+			if w.Code != tt.want {
+				t.Errorf("approval webhook returned status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetApprovalWithoutApproverKey(t *testing.T) {
+	certRequest := newTestCertRequest("node", "", "request")
+	c := newApprovalTestController(t, nil, []*certificatev1alpha2.CertificateApproval{newTestApproval("node", certRequest, certificatev1alpha2.CertificateApproved)})
+	c.approvalPolicy.ApproverKey = nil
+
+	if got, _ := c.getApproval(certRequest); got != approvalPending {
+		t.Errorf("getApproval() = %d without an approver key, want %d", got, approvalPending)
+	}
+	recorder := c.recorder.(*record.FakeRecorder)
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, EventReasonInvalidApproval) {
+			t.Errorf("recorded event '%s', want %s", event, EventReasonInvalidApproval)
+		}
+	default:
+		t.Errorf("no event has been recorded for the ignored approval")
+	}
+}
@@ -4,36 +4,132 @@ import (
 	"bytes"
 	"crypto/x509"
 	"fmt"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/CodingJzy/trireme-csr/certificates"
+	"github.com/CodingJzy/trireme-csr/metrics"
 	"go.aporeto.io/tg/tglib"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubeinformers "k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
 
 	certificatev1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
 	certificateclient "github.com/CodingJzy/trireme-csr/pkg/client/clientset/versioned"
 	certificateinformers "github.com/CodingJzy/trireme-csr/pkg/client/informers/externalversions"
 	certificateinformerv1alpha2 "github.com/CodingJzy/trireme-csr/pkg/client/informers/externalversions/certmanager.k8s.io/v1alpha2"
+	certificatelisterv1alpha2 "github.com/CodingJzy/trireme-csr/pkg/client/listers/certmanager.k8s.io/v1alpha2"
+)
+
+const (
+	// retryBaseDelay is the delay after the first failure of a Cert request before it gets retried
+	retryBaseDelay = 500 * time.Millisecond
+	// retryMaxDelay is the maximum delay between two retries of the same Cert request
+	retryMaxDelay = 5 * time.Minute
+
+	// ResyncPeriod is the resync period of the informers that the controller is built on
+	ResyncPeriod = 30 * time.Second
 )
 
 // CertificateController contains all the logic to implement the issuance of certificates.
 type CertificateController struct {
 	certificateClient   certificateclient.Interface
 	certificateInformer certificateinformerv1alpha2.CertificateInformer
-	issuer              certificates.Issuer
+	certificateLister   certificatelisterv1alpha2.CertificateLister
+	issuerInformer      certificateinformerv1alpha2.IssuerInformer
+	issuerLister        certificatelisterv1alpha2.IssuerLister
+	approvalInformer    certificateinformerv1alpha2.CertificateApprovalInformer
+	approvalLister      certificatelisterv1alpha2.CertificateApprovalLister
+	secretInformer      coreinformers.SecretInformer
+	secretLister        corelisters.SecretLister
+	kubeClient          kubernetes.Interface
+	recorder            record.EventRecorder
+	approvalPolicy      ApprovalPolicy
+
+	// issuer is the default issuer, which signs the Cert requests that do not reference an Issuer
+	issuer certificates.Issuer
+
+	// issuers holds the issuers built from the Issuer resources by name
+	issuers      map[string]*issuerEntry
+	issuersLock  sync.RWMutex
+	issuerConfig IssuerConfig
+
+	// revocations persists the revocations of all issuers
+	revocations *RevocationStore
+
+	// queue holds the names of the Cert requests that need to be reconciled.
+	// Failed reconciliations are requeued with an exponential backoff.
+	queue workqueue.RateLimitingInterface
+	// issuerQueue holds the names of the Issuers that need to be loaded, reloaded or removed. It is processed on
+	// every replica, so that all of them serve the CRLs and OCSP responses of all issuers.
+	issuerQueue workqueue.RateLimitingInterface
+	// issuerStatusQueue holds the names of the Issuers whose status needs to be updated
+	issuerStatusQueue workqueue.RateLimitingInterface
+
+	// watchdog tracks the state of the controller loop for the health checks
+	watchdog *watchdog
 }
 
 // NewCertificateController generates the new CertificateController
-func NewCertificateController(certificateClient certificateclient.Interface, certificateInformerFactory certificateinformers.SharedInformerFactory, issuer certificates.Issuer) *CertificateController {
+func NewCertificateController(certificateClient certificateclient.Interface, kubeClient kubernetes.Interface, certificateInformerFactory certificateinformers.SharedInformerFactory, kubeInformerFactory kubeinformers.SharedInformerFactory, issuer certificates.Issuer, approvalPolicy ApprovalPolicy, issuerConfig IssuerConfig, revocations *RevocationStore) *CertificateController {
 
 	certificateInformer := certificateInformerFactory.Certmanager().V1alpha2().Certificates()
+	issuerInformer := certificateInformerFactory.Certmanager().V1alpha2().Issuers()
+	approvalInformer := certificateInformerFactory.Certmanager().V1alpha2().CertificateApprovals()
+	secretInformer := kubeInformerFactory.Core().V1().Secrets()
 
 	c := &CertificateController{
 		certificateClient:   certificateClient,
 		certificateInformer: certificateInformer,
+		certificateLister:   certificateInformer.Lister(),
+		issuerInformer:      issuerInformer,
+		issuerLister:        issuerInformer.Lister(),
+		approvalInformer:    approvalInformer,
+		approvalLister:      approvalInformer.Lister(),
+		secretInformer:      secretInformer,
+		secretLister:        secretInformer.Lister(),
+		kubeClient:          kubeClient,
+		recorder:            newEventRecorder(kubeClient),
+		approvalPolicy:      approvalPolicy,
 		issuer:              issuer,
+		issuers:             map[string]*issuerEntry{},
+		issuerConfig:        issuerConfig,
+		revocations:         revocations,
+		queue: workqueue.NewNamedRateLimitingQueue(
+			workqueue.NewItemExponentialFailureRateLimiter(retryBaseDelay, retryMaxDelay),
+			"certificates",
+		),
+		issuerQueue: workqueue.NewNamedRateLimitingQueue(
+			workqueue.NewItemExponentialFailureRateLimiter(retryBaseDelay, retryMaxDelay),
+			"issuers",
+		),
+		issuerStatusQueue: workqueue.NewNamedRateLimitingQueue(
+			workqueue.NewItemExponentialFailureRateLimiter(retryBaseDelay, retryMaxDelay),
+			"issuer-status",
+		),
+		watchdog: newWatchdog(),
+	}
+
+	// every replica hands the persisted revocations to its issuers
+	revocations.onChange = c.applyRevocations
+
+	// index the Cert requests by serial, so that we can answer for the issued certificates
+	err := certificateInformer.Informer().AddIndexers(cache.Indexers{serialIndex: serialIndexFunc})
+	if err != nil {
+		zap.L().Error("Error adding serial index to Certificate informer", zap.Error(err))
 	}
 
 	certificateInformer.Informer().AddEventHandler(
@@ -44,21 +140,64 @@ func NewCertificateController(certificateClient certificateclient.Interface, cer
 		},
 	)
 
+	issuerInformer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    c.onIssuerAdd,
+			UpdateFunc: c.onIssuerUpdate,
+			DeleteFunc: c.onIssuerDelete,
+		},
+	)
+
+	// the Secrets of the Issuers are read from the cache, and their changes reload the Issuers that reference them
+	secretInformer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    c.onSecretAdd,
+			UpdateFunc: c.onSecretUpdate,
+			DeleteFunc: c.onSecretDelete,
+		},
+	)
+
+	approvalInformer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    c.onApproval,
+			UpdateFunc: func(oldObj, newObj interface{}) { c.onApproval(newObj) },
+		},
+	)
+
 	return c
 }
 
-// Run starts the certificateWatcher.
-func (c *CertificateController) Run(stopCh <-chan struct{}) error {
+// Run starts the certificateWatcher with the given number of workers and blocks until stopCh is closed.
+func (c *CertificateController) Run(workers int, stopCh <-chan struct{}) error {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+	defer c.issuerStatusQueue.ShutDown()
+
 	zap.L().Info("start watching Certificates objects")
+	c.watchdog.setStarted()
 
 	// wait for caches to sync
-	ok := cache.WaitForCacheSync(stopCh, c.certificateInformer.Informer().HasSynced)
+	ok := cache.WaitForCacheSync(stopCh, c.certificateInformer.Informer().HasSynced, c.issuerInformer.Informer().HasSynced, c.approvalInformer.Informer().HasSynced)
 	if !ok {
 		return fmt.Errorf("error while waiting for caches to sync")
 	}
+	c.watchdog.setSynced()
+
+	if workers < 1 {
+		workers = 1
+	}
+	zap.L().Info("starting workers", zap.Int("workers", workers))
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.runWorker, time.Second, stopCh)
+	}
+	// the issuers have been loaded on every replica, so that their status is only reported once we lead
+	c.enqueueIssuerStatuses()
+	go wait.Until(c.runIssuerStatusWorker, time.Second, stopCh)
+	go wait.Until(c.sendHeartbeat, heartbeatInterval, stopCh)
 
 	// now wait until the stopCh closes
 	<-stopCh
+	zap.L().Info("shutting down workers")
 	return nil
 }
 
@@ -68,87 +207,9 @@ func (c *CertificateController) onAdd(obj interface{}) {
 		zap.L().Sugar().Errorf("Received wrong object type in adding Cert event: '%T", obj)
 		return
 	}
-
-	switch certRequest.Status.Phase {
-	case certificatev1alpha2.CertificateSigned:
-		zap.L().Debug("Added Cert request has already been processed and a certificate was issued", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-		// if a cert is added as signed, we should validate it before we allow this status like this from scratch
-		// 1. check if the CSR was actually valid
-		csr, err := certRequest.GetCertificateRequest()
-		if err != nil {
-			c.updateCertRejected(
-				certRequest,
-				certificatev1alpha2.StatusReasonProcessedRejectedInvalidCSR,
-				fmt.Errorf("changing phase to '%s': failed to get CSR: %s", certificatev1alpha2.CertificateRejected, err.Error()),
-			)
-			return
-		}
-		err = c.issuer.ValidateRequest(csr)
-		if err != nil {
-			c.updateCertRejected(
-				certRequest,
-				certificatev1alpha2.StatusReasonProcessedRejectedInvalidCSR,
-				fmt.Errorf("changing phase to '%s': failed to validate CSR: %s", certificatev1alpha2.CertificateRejected, err.Error()),
-			)
-			return
-		}
-		// 2. check if the issued cert is valid
-		cert, err := certRequest.GetCertificate()
-		if err != nil {
-			c.updateCertRejected(
-				certRequest,
-				certificatev1alpha2.StatusReasonProcessedRejectedInvalidCerts,
-				fmt.Errorf("changing phase to '%s': failed to get Certificate: %s", certificatev1alpha2.CertificateRejected, err.Error()),
-			)
-			return
-		}
-		// as this has been added as a new object, we retrieve the CA from the object here for validation,
-		// as chances are that the CA cert is not the cert of the running CA
-		ca, err := certRequest.GetCACertificate()
-		if err != nil {
-			c.updateCertRejected(
-				certRequest,
-				certificatev1alpha2.StatusReasonProcessedRejectedInvalidCerts,
-				fmt.Errorf("changing phase to '%s': failed to get CA Certificate: %s", certificatev1alpha2.CertificateRejected, err.Error()),
-			)
-			return
-		}
-		err = c.issuer.ValidateCert(cert, ca)
-		if err != nil {
-			c.updateCertRejected(
-				certRequest,
-				certificatev1alpha2.StatusReasonProcessedRejectedInvalidCerts,
-				fmt.Errorf("changing phase to '%s': failed to validate signed certificate: %s", certificatev1alpha2.CertificateRejected, err.Error()),
-			)
-		}
-		// it is a valid object, nothing more to be done
-
-	case certificatev1alpha2.CertificateRejected:
-		zap.L().Debug("Added Cert request has already been processed and was rejected", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-
-	case certificatev1alpha2.CertificateSubmitted:
-		zap.L().Info("Added Cert request: processing Cert request", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-		// we definitely want to process this request when the object has been created with the Submitted Phase
-		c.process(certRequest)
-
-	case certificatev1alpha2.CertificateUnknown:
-		// nothing has to be done when we are in the 'Unknown' phase at Adding time
-		zap.L().Debug("Added Cert request: nothing has to be done for this Cert request", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-
-	default:
-		// this means that a phase is missing, which should be the default when one creates an object
-		// check if we have a spec, if yes, move it to the submitted phase
-		if certRequest.Spec.Request != nil && len(certRequest.Spec.Request) > 0 {
-			zap.L().Debug("Added Cert request: phase missing, but spec exists -> got just submitted", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-			c.updateCertSubmitted(certRequest)
-			return
-		}
-
-		// there is no spec, so we move it to the Unknown phase
-		// the user will have to add a spec, so that it can get moved into the submitted phase
-		zap.L().Debug("Added Cert request: phase missing, but no spec -> unrecognized phase", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-		c.updateCertUnknown(certRequest)
-	}
+	c.watchdog.observeEvent()
+	zap.L().Debug("Added Cert request", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
+	c.enqueue(certRequest)
 }
 
 func (c *CertificateController) onUpdate(oldObj, newObj interface{}) {
@@ -162,6 +223,7 @@ func (c *CertificateController) onUpdate(oldObj, newObj interface{}) {
 		zap.L().Sugar().Errorf("Received wrong object type in updating Cert event for old object: '%T", oldObj)
 		return
 	}
+	c.watchdog.observeEvent()
 
 	// the periodic resync of the controller will send update events
 	// a different resource version of the same certificate means that
@@ -171,114 +233,336 @@ func (c *CertificateController) onUpdate(oldObj, newObj interface{}) {
 		return
 	}
 
+	zap.L().Debug("Updated Cert request", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
+	c.enqueue(certRequest)
+}
+
+func (c *CertificateController) onDelete(obj interface{}) {
+	certRequest, ok := obj.(*certificatev1alpha2.Certificate)
+	if !ok {
+		zap.L().Sugar().Errorf("Received wrong object type in adding Cert event: '%T", obj)
+		return
+	}
+	c.watchdog.observeEvent()
+	zap.L().Debug("Deleting Cert event", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
+}
+
+// enqueue adds the key of the Cert request to the work queue
+func (c *CertificateController) enqueue(certRequest *certificatev1alpha2.Certificate) {
+	key, err := cache.MetaNamespaceKeyFunc(certRequest)
+	if err != nil {
+		zap.L().Error("Error computing key for Cert request", zap.Error(err), zap.String("name", certRequest.Name))
+		return
+	}
+	c.queue.Add(key)
+}
+
+// enqueueAfter adds the key of the Cert request to the work queue once `delay` has passed
+func (c *CertificateController) enqueueAfter(certRequest *certificatev1alpha2.Certificate, delay time.Duration) {
+	key, err := cache.MetaNamespaceKeyFunc(certRequest)
+	if err != nil {
+		zap.L().Error("Error computing key for Cert request", zap.Error(err), zap.String("name", certRequest.Name))
+		return
+	}
+	c.queue.AddAfter(key, delay)
+}
+
+// runWorker processes items of the work queue until it gets shut down
+func (c *CertificateController) runWorker() {
+	for c.processNextItem() {
+	}
+}
+
+// processNextItem reconciles the next item of the work queue. It returns false once the queue has been shut down.
+func (c *CertificateController) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	name := key.(string)
+	if name == heartbeatKey {
+		c.watchdog.beat()
+		c.queue.Forget(key)
+		return true
+	}
+	c.watchdog.markBusy(name)
+	defer c.watchdog.markIdle(name)
+
+	err := c.reconcile(name)
+	if err != nil {
+		zap.L().Warn("Error reconciling Cert request, retrying", zap.Error(err), zap.String("name", name), zap.Int("retries", c.queue.NumRequeues(key)))
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+// reconcile brings the Cert request with the given name to the state that its current phase requires.
+// It only looks at the current state of the object, and must therefore be safe to call any number of times.
+// An error is returned if the reconciliation must be retried.
+func (c *CertificateController) reconcile(name string) error {
+	certRequest, err := c.certificateLister.Get(name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			zap.L().Debug("Cert request does not exist anymore", zap.String("name", name))
+			return nil
+		}
+		return err
+	}
+
+	// objects written before the request hash was recorded have none, and would look like new requests.
+	// Their status has been computed for the request they hold, so we only record its hash.
+	if certRequest.Status.RequestHash == "" && len(certRequest.Spec.Request) > 0 {
+		switch certRequest.Status.Phase {
+		case certificatev1alpha2.CertificateSigned, certificatev1alpha2.CertificateRevoked, certificatev1alpha2.CertificateRejected, certificatev1alpha2.CertificateUnknown:
+			zap.L().Debug("Cert request has no request hash, recording it", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
+			return c.updateRequestHash(certRequest)
+		}
+	}
+
 	switch certRequest.Status.Phase {
 	case certificatev1alpha2.CertificateSigned:
-		zap.L().Debug("Updated Cert request has been processed and a certificate was issued", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-		// Signed is a final state in the update phase
-		// the only thing that we will do is to validate the certs again, to ensure that this is not a rogue update
-		cert, err := certRequest.GetCertificate()
-		if err != nil {
-			c.updateCertRejected(
-				certRequest,
-				certificatev1alpha2.StatusReasonProcessedRejectedInvalidCerts,
-				fmt.Errorf("changing phase to '%s': failed to get Certificate: %s", certificatev1alpha2.CertificateRejected, err.Error()),
-			)
-			return
+		zap.L().Debug("Cert request has been processed and a certificate was issued", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
+		// the certificate can only be moved further to the revoked phase
+		if reason, ok := certRequest.GetRevocationRequest(); ok {
+			revoked, err := c.revoke(certRequest, reason)
+			if revoked || err != nil {
+				return err
+			}
 		}
-		err = c.issuer.ValidateCert(cert, nil)
-		if err != nil {
-			c.updateCertRejected(
-				certRequest,
-				certificatev1alpha2.StatusReasonProcessedRejectedInvalidCerts,
-				fmt.Errorf("changing phase to '%s': failed to validate signed certificate: %s", certificatev1alpha2.CertificateRejected, err.Error()),
-			)
+		// a new request has to be processed again, the certificate that has been issued stays valid until it expires
+		if certRequest.RequestChanged() {
+			return c.updateCertSubmitted(certRequest)
 		}
-		// it is a valid object, nothing more to be done
+		// otherwise, the only thing that we will do is to validate the certs again, to ensure that this is not a rogue update
+		return c.validateSigned(certRequest)
+
+	case certificatev1alpha2.CertificateRevoked:
+		// Revoked is a final state, we only make sure that the revocation has been persisted
+		zap.L().Debug("Cert request has been revoked", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
+		return c.persistRevocation(certRequest)
 
 	case certificatev1alpha2.CertificateRejected:
-		zap.L().Debug("Update Cert request has been processed and was rejected", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
+		zap.L().Debug("Cert request has been processed and was rejected", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
 		// check if a new spec was submitted, and move to the submitted phase if yes
-		if certRequest.Spec.Request != nil && len(certRequest.Spec.Request) > 0 && !bytes.Equal(oldCertRequest.Spec.Request, certRequest.Spec.Request) {
-			c.updateCertSubmitted(certRequest)
+		if len(certRequest.Spec.Request) > 0 && certRequest.RequestChanged() {
+			return c.updateCertSubmitted(certRequest)
 		}
+		return nil
 
 	case certificatev1alpha2.CertificateUnknown:
-		zap.L().Debug("Updated Cert request: nothing has to be done for this Cert request", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
+		zap.L().Debug("Cert request: nothing has to be done for this Cert request", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
 		// check if a spec has been submitted, and move it to the submitted phase if yes
-		if certRequest.Spec.Request != nil && len(certRequest.Spec.Request) > 0 && !bytes.Equal(oldCertRequest.Spec.Request, certRequest.Spec.Request) {
-			c.updateCertSubmitted(certRequest)
+		if len(certRequest.Spec.Request) > 0 && certRequest.RequestChanged() {
+			return c.updateCertSubmitted(certRequest)
 		}
+		return nil
 
 	case certificatev1alpha2.CertificateSubmitted:
-		zap.L().Info("Updated Cert request: processing Cert request", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-		c.process(certRequest)
+		zap.L().Info("Processing Cert request", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
+		defer metrics.ObserveProcessDuration(time.Now())
+		return c.process(certRequest)
+
+	case certificatev1alpha2.CertificatePending:
+		// we only need to look at it again once an approval decision has been taken, or once the request changed,
+		// which needs a new decision
+		zap.L().Debug("Cert request is pending approval", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
+		if certRequest.RequestChanged() {
+			return c.updateCertSubmitted(certRequest)
+		}
+		if decision, _ := c.getApproval(certRequest); decision == approvalPending {
+			return nil
+		}
+		defer metrics.ObserveProcessDuration(time.Now())
+		return c.process(certRequest)
 
 	default:
-		// this means that a phase is missing, so we move it to the Unknown phase
-		// as we honestly don't understand how we ended up in this state :)
-		zap.L().Warn("Updated Cert request: unrecognized phase", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-		c.updateCertUnknown(certRequest)
+		// this means that a phase is missing, which should be the default when one creates an object
+		// check if we have a spec, if yes, move it to the submitted phase
+		if len(certRequest.Spec.Request) > 0 {
+			zap.L().Debug("Cert request: phase missing, but spec exists -> got just submitted", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
+			return c.updateCertSubmitted(certRequest)
+		}
+
+		// there is no spec, so we move it to the Unknown phase
+		// the user will have to add a spec, so that it can get moved into the submitted phase
+		zap.L().Debug("Cert request: phase missing, but no spec -> unrecognized phase", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
+		return c.updateCertUnknown(certRequest)
 	}
 }
 
-func (c *CertificateController) onDelete(obj interface{}) {
-	certRequest, ok := obj.(*certificatev1alpha2.Certificate)
-	if !ok {
-		zap.L().Sugar().Errorf("Received wrong object type in adding Cert event: '%T", obj)
-		return
+// validateSigned validates the CSR and the issued certificate of a Cert request in the `Signed` phase,
+// and moves it to the `Rejected` phase if any of them is not valid.
+func (c *CertificateController) validateSigned(certRequest *certificatev1alpha2.Certificate) error {
+	// 1. check if the CSR was actually valid
+	csr, err := certRequest.GetCertificateRequest()
+	if err != nil {
+		return c.updateCertInvalid(
+			certRequest,
+			certificatev1alpha2.StatusReasonProcessedRejectedInvalidCSR,
+			fmt.Errorf("changing phase to '%s': failed to get CSR: %s", certificatev1alpha2.CertificateRejected, err.Error()),
+		)
 	}
-	zap.L().Debug("Deleting Cert event", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
+	// the issuance policy only applies when signing, so that changing it does not reject already signed certificates
+	err = csr.CheckSignature()
+	if err != nil {
+		c.recorder.Eventf(certRequest, corev1.EventTypeWarning, EventReasonValidationFailed, "Failed to validate CSR of signed certificate: %s", err.Error())
+		return c.updateCertInvalid(
+			certRequest,
+			certificatev1alpha2.StatusReasonProcessedRejectedInvalidCSR,
+			fmt.Errorf("changing phase to '%s': failed to validate CSR: %s", certificatev1alpha2.CertificateRejected, err.Error()),
+		)
+	}
+
+	// 2. check if the issued cert is valid
+	cert, err := certRequest.GetCertificate()
+	if err != nil {
+		return c.updateCertInvalid(
+			certRequest,
+			certificatev1alpha2.StatusReasonProcessedRejectedInvalidCerts,
+			fmt.Errorf("changing phase to '%s': failed to get Certificate: %s", certificatev1alpha2.CertificateRejected, err.Error()),
+		)
+	}
+	// we can not tell anymore if this object has just been added or updated,
+	// so we never trust the CA from the object, and only validate against the CA of the issuer
+	issuer, _, err := c.issuerFor(certRequest)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			zap.L().Warn("Issuer of signed Cert request does not exist anymore", zap.String("name", certRequest.Name), zap.String("issuer", issuerName(certRequest)))
+			return nil
+		}
+		return err
+	}
+	err = issuer.ValidateCert(cert, nil)
+	if certificates.IsTemporary(err) {
+		return fmt.Errorf("temporary error validating signed certificate: %s", err)
+	}
+	if err != nil {
+		c.recorder.Eventf(certRequest, corev1.EventTypeWarning, EventReasonValidationFailed, "Failed to validate signed certificate: %s", err.Error())
+		return c.updateCertInvalid(
+			certRequest,
+			certificatev1alpha2.StatusReasonProcessedRejectedInvalidCerts,
+			fmt.Errorf("changing phase to '%s': failed to validate signed certificate: %s", certificatev1alpha2.CertificateRejected, err.Error()),
+		)
+	}
+
+	// 3. the status could have been written through the main resource, so we do not trust any of its values,
+	// and check that the certificate was issued for the requested key, and that the CAs are the ones we publish
+	if !bytes.Equal(cert.RawSubjectPublicKeyInfo, csr.RawSubjectPublicKeyInfo) {
+		return c.updateCertInvalid(
+			certRequest,
+			certificatev1alpha2.StatusReasonProcessedRejectedInvalidCerts,
+			fmt.Errorf("changing phase to '%s': signed certificate does not match the public key of the CSR", certificatev1alpha2.CertificateRejected),
+		)
+	}
+	chain, retiring, err := issuer.ChainFor(cert)
+	if certificates.IsTemporary(err) {
+		return fmt.Errorf("temporary error finding the chain of the signed certificate: %s", err)
+	}
+	if err != nil {
+		return c.updateCertInvalid(
+			certRequest,
+			certificatev1alpha2.StatusReasonProcessedRejectedInvalidCerts,
+			fmt.Errorf("changing phase to '%s': failed to find the chain of the signed certificate: %s", certificatev1alpha2.CertificateRejected, err.Error()),
+		)
+	}
+	// the certificate has been validated against our chains, so CAs that do not match, like the ones published
+	// before a CA rotation, only need to be replaced, and certificates of retiring CAs get flagged for re-issue
+	if !bytes.Equal(bytes.TrimSpace(certRequest.Status.Ca), bytes.TrimSpace(issuer.GetRootCACert())) ||
+		!bytes.Equal(bytes.TrimSpace(certRequest.Status.Chain), bytes.TrimSpace(chain)) ||
+		certRequest.Status.ReissueRequired != retiring {
+		return c.updateCertChain(certRequest, issuer.GetRootCACert(), chain, retiring)
+	}
+
+	// 4. it is a valid object, so the only thing left is to keep its token fresh
+	return c.refreshToken(certRequest, issuer, cert)
 }
 
-// process is called from a `Submitted` phase event from `onUpdate` or `onAdd` to process the request
-func (c *CertificateController) process(certRequest *certificatev1alpha2.Certificate) {
+// process is called from `reconcile` for a Cert request in the `Submitted` or `Pending` phase to process the request
+func (c *CertificateController) process(certRequest *certificatev1alpha2.Certificate) error {
 	// Load CSR
 	csr, err := certRequest.GetCertificateRequest()
 	if err != nil {
 		zap.L().Error("Error loading CSR", zap.Error(err), zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-		c.updateCertRejected(
+		return c.updateCertRejected(
 			certRequest,
 			certificatev1alpha2.StatusReasonProcessedRejectedInvalidCSR,
 			fmt.Errorf("Error loading CSR: %s", err.Error()),
 		)
-		return
 	}
 
-	// Validate CSR
+	// Get the issuer that the request references
+	issuer, issuerSpec, err := c.issuerFor(certRequest)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return c.updateCertRejected(
+				certRequest,
+				certificatev1alpha2.StatusReasonProcessedRejected,
+				fmt.Errorf("Issuer '%s' does not exist", issuerName(certRequest)),
+			)
+		}
+		// the Issuer exists, so we retry until it has been loaded
+		return err
+	}
+	if !issuerAllows(issuerSpec, certRequest) {
+		return c.updateCertRejected(
+			certRequest,
+			certificatev1alpha2.StatusReasonProcessedRejected,
+			fmt.Errorf("Issuer '%s' does not allow Certificate '%s' in its allowedCertificates", issuerName(certRequest), requestName(certRequest)),
+		)
+	}
+
+	// Validate CSR and the requested options
 	zap.L().Info("Validating cert request", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-	err = c.issuer.ValidateRequest(csr)
+	signOptions := certificates.NewSignOptions(&certRequest.Spec)
+	applyProfile(signOptions, issuerSpec, certRequest.Spec.Profile)
+	err = issuer.ValidateRequest(csr, signOptions)
 	if err != nil {
 		zap.L().Error("CSR has not been validated", zap.Error(err), zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-		c.updateCertRejected(
+		c.recorder.Eventf(certRequest, corev1.EventTypeWarning, EventReasonValidationFailed, "Failed to validate CSR: %s", err.Error())
+		return c.updateCertRejected(
 			certRequest,
 			certificatev1alpha2.StatusReasonProcessedRejectedInvalidCSR,
 			fmt.Errorf("Failed to validate CSR: %s", err.Error()),
 		)
-		return
 	}
 	zap.L().Info("Cert request has been accepted", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
 
-	// Check Key type: currently it *must* be an ECDSA key
-	if csr.PublicKeyAlgorithm != x509.ECDSA {
-		zap.L().Error("CSR is generated from an unsupported key type", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-		c.updateCertRejected(
-			certRequest,
-			certificatev1alpha2.StatusReasonProcessedRejectedInvalidCSR,
-			fmt.Errorf("Unsupported Key Type (only ECDSA keys are supported)"),
-		)
-		return
+	// Check approval: the request is only signed once it has been approved
+	decision, approval := c.getApproval(certRequest)
+	switch decision {
+	case approvalDenied:
+		zap.L().Info("Cert request has been denied", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion), zap.String("approver", approval.Approver))
+		return c.updateCertDenied(certRequest, approval)
+	case approvalPending:
+		zap.L().Info("Cert request needs an approval", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
+		if certRequest.Status.Phase == certificatev1alpha2.CertificatePending {
+			return nil
+		}
+		return c.updateCertPending(certRequest)
 	}
+	zap.L().Info("Cert request has been approved", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion), zap.String("approver", approval.Approver))
 
 	// Sign CSR
-	cert, err := c.issuer.Sign(csr)
+	cert, err := issuer.Sign(csr, signOptions)
 	if err != nil {
+		metrics.ObserveIssuerError(metrics.IssuerOperationSign)
+		// the signing goes over the network with a remote signer or Vault, so it gets retried with rate limiting
+		// instead of rejecting a valid request
+		if certificates.IsTemporary(err) {
+			c.recorder.Eventf(certRequest, corev1.EventTypeWarning, EventReasonSigningFailed, "Signing failed, retrying: %s", err.Error())
+			return fmt.Errorf("temporary error signing CSR: %s", err)
+		}
 		zap.L().Error("Error signing CSR", zap.Error(err), zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-		c.updateCertRejected(
+		return c.updateCertRejected(
 			certRequest,
 			certificatev1alpha2.StatusReasonProcessedRejected,
 			fmt.Errorf("Failed to sign CSR: %s", err.Error()),
 		)
-		return
 	}
 	zap.L().Info("Cert successfully generated", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
 
@@ -286,92 +570,178 @@ func (c *CertificateController) process(certRequest *certificatev1alpha2.Certifi
 	x509Cert, err := tglib.ReadCertificatePEMFromData(cert)
 	if err != nil {
 		zap.L().Error("Error loading x509 Cert", zap.Error(err), zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-		c.updateCertRejected(
+		return c.updateCertRejected(
 			certRequest,
 			certificatev1alpha2.StatusReasonProcessedRejected,
 			fmt.Errorf("Error loading x509 Cert: %s", err.Error()),
 		)
-		return
 	}
 
-	// issue token
-	token, err := c.issuer.IssueToken(x509Cert)
-	if err != nil {
-		zap.L().Error("Error Issuing compact PKI token", zap.Error(err), zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-		c.updateCertRejected(
-			certRequest,
-			certificatev1alpha2.StatusReasonProcessedRejected,
-			fmt.Errorf("Error Issuing compact PKI token: %s", err.Error()),
-		)
-		return
+	// issue token, which is only possible for ECDSA keys
+	var token []byte
+	var tokenNotAfter time.Time
+	if certificates.SupportsToken(x509Cert) {
+		token, tokenNotAfter, err = issuer.IssueToken(x509Cert, tokenAttributes(certRequest))
+		if err != nil {
+			zap.L().Error("Error Issuing compact PKI token", zap.Error(err), zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
+			metrics.ObserveIssuerError(metrics.IssuerOperationIssueToken)
+			return c.updateCertRejected(
+				certRequest,
+				certificatev1alpha2.StatusReasonProcessedRejected,
+				fmt.Errorf("Error Issuing compact PKI token: %s", err.Error()),
+			)
+		}
+	} else {
+		zap.L().Info("Cert is issued without a token, as its key is not an ECDSA key", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
 	}
 
 	zap.L().Debug("Cert and token successfully generated", zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion), zap.ByteString("cert", cert))
 
 	// last but not least, update our object with the signed cert
-	c.updateCertSigned(certRequest, cert, token)
+	return c.updateCertSigned(certRequest, issuer, cert, x509Cert, token, tokenNotAfter, approval)
 }
 
-func (c *CertificateController) updateCertSubmitted(certRequestObj *certificatev1alpha2.Certificate) {
-	certRequest := certRequestObj.DeepCopy()
-	certRequest.Status.Phase = certificatev1alpha2.CertificateSubmitted
-	certRequest.Status.Reason = certificatev1alpha2.StatusReasonSubmitted
-	certRequest.Status.Message = "The request contains a certificate request. Submitting certificate request for processing."
+func (c *CertificateController) updateCertSubmitted(certRequestObj *certificatev1alpha2.Certificate) error {
+	message := "The request contains a certificate request. Submitting certificate request for processing."
+	return c.updateStatus(certRequestObj, corev1.EventTypeNormal, EventReasonSubmitted, message, func(certRequest *certificatev1alpha2.Certificate) {
+		certRequest.Status.Phase = certificatev1alpha2.CertificateSubmitted
+		certRequest.Status.RequestHash = certRequest.Spec.GetRequestHash()
+		// decisions only apply to the request they were taken for
+		certRequest.Status.Conditions = nil
+		certRequest.Status.ApprovedBy = ""
+		certRequest.Status.ApprovedAt = nil
+		certRequest.Status.Reason = certificatev1alpha2.StatusReasonSubmitted
+		certRequest.Status.Message = message
+	})
+}
 
-	// we can not use UpdateStatus until this issue gets resolved afaik
-	// https://github.com/kubernetes/kubernetes/issues/38113
-	_, err := c.certificateClient.CertmanagerV1alpha2().Certificates().Update(certRequest)
-	if err != nil {
-		zap.L().Error("Error Updating the Certificate ressource", zap.Error(err), zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-		return
-	}
+func (c *CertificateController) updateCertUnknown(certRequestObj *certificatev1alpha2.Certificate) error {
+	message := "The request has not been processed by the controller yet. Submit a valid CSR in the spec to submit this CSR for processing."
+	return c.updateStatus(certRequestObj, corev1.EventTypeWarning, EventReasonUnknown, message, func(certRequest *certificatev1alpha2.Certificate) {
+		certRequest.Status.Phase = certificatev1alpha2.CertificateUnknown
+		certRequest.Status.RequestHash = certRequest.Spec.GetRequestHash()
+		certRequest.Status.Reason = certificatev1alpha2.StatusReasonUnprocessed
+		certRequest.Status.Message = message
+	})
 }
 
-func (c *CertificateController) updateCertUnknown(certRequestObj *certificatev1alpha2.Certificate) {
-	certRequest := certRequestObj.DeepCopy()
-	certRequest.Status.Phase = certificatev1alpha2.CertificateUnknown
-	certRequest.Status.Reason = certificatev1alpha2.StatusReasonUnprocessed
-	certRequest.Status.Message = "The request has not been processed by the controller yet. Submit a valid CSR in the spec to submit this CSR for processing."
+// updateCertRejected is called when the request that has been processed got rejected
+func (c *CertificateController) updateCertRejected(certRequestObj *certificatev1alpha2.Certificate, reason string, rejectErr error) error {
+	return c.updateStatus(certRequestObj, corev1.EventTypeWarning, reason, rejectErr.Error(), func(certRequest *certificatev1alpha2.Certificate) {
+		certRequest.Status.Phase = certificatev1alpha2.CertificateRejected
+		certRequest.Status.RequestHash = certRequest.Spec.GetRequestHash()
+		certRequest.Status.Reason = reason
+		certRequest.Status.Message = rejectErr.Error()
+	})
+}
 
-	// we can not use UpdateStatus until this issue gets resolved afaik
-	// https://github.com/kubernetes/kubernetes/issues/38113
-	_, err := c.certificateClient.CertmanagerV1alpha2().Certificates().Update(certRequest)
-	if err != nil {
-		zap.L().Error("Error Updating the Certificate ressource", zap.Error(err), zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-		return
-	}
+// updateCertInvalid is called when the certificate of a signed Cert request is not valid anymore.
+// It keeps the hash of the request that the certificate has been issued for, so that a request which
+// has changed in the meantime gets submitted again.
+func (c *CertificateController) updateCertInvalid(certRequestObj *certificatev1alpha2.Certificate, reason string, rejectErr error) error {
+	return c.updateStatus(certRequestObj, corev1.EventTypeWarning, reason, rejectErr.Error(), func(certRequest *certificatev1alpha2.Certificate) {
+		certRequest.Status.Phase = certificatev1alpha2.CertificateRejected
+		certRequest.Status.Reason = reason
+		certRequest.Status.Message = rejectErr.Error()
+	})
 }
 
-func (c *CertificateController) updateCertRejected(certRequestObj *certificatev1alpha2.Certificate, reason string, rejectErr error) {
-	certRequest := certRequestObj.DeepCopy()
-	certRequest.Status.Phase = certificatev1alpha2.CertificateRejected
-	certRequest.Status.Reason = reason
-	certRequest.Status.Message = rejectErr.Error()
+// updateRequestHash records the hash of the request of a Cert request that does not have one yet
+func (c *CertificateController) updateRequestHash(certRequestObj *certificatev1alpha2.Certificate) error {
+	return c.updateStatus(certRequestObj, "", "", "", func(certRequest *certificatev1alpha2.Certificate) {
+		certRequest.Status.RequestHash = certRequest.Spec.GetRequestHash()
+	})
+}
 
-	// we can not use UpdateStatus until this issue gets resolved afaik
-	// https://github.com/kubernetes/kubernetes/issues/38113
-	_, err := c.certificateClient.CertmanagerV1alpha2().Certificates().Update(certRequest)
-	if err != nil {
-		zap.L().Error("Error Updating the Certificate ressource", zap.Error(err), zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-		return
+// updateCertSigned is called when a request has been successfully processed/approved/signed
+func (c *CertificateController) updateCertSigned(certRequestObj *certificatev1alpha2.Certificate, issuer certificates.Issuer, cert []byte, x509Cert *x509.Certificate, token []byte, tokenNotAfter time.Time, approval *certificatev1alpha2.CertificateCondition) error {
+	message := "CSR has been processed and approved, and the Certificate has been signed and issued"
+	return c.updateStatus(certRequestObj, corev1.EventTypeNormal, EventReasonSigned, message, func(certRequest *certificatev1alpha2.Certificate) {
+		certRequest.Status.Certificate = cert
+		certRequest.Status.Ca = issuer.GetRootCACert()
+		certRequest.Status.Chain = issuer.GetChain()
+		certRequest.Status.ReissueRequired = false
+		setToken(certRequest, token, tokenNotAfter)
+		certRequest.Status.Conditions = []certificatev1alpha2.CertificateCondition{*approval}
+		certRequest.Status.ApprovedBy = approval.Approver
+		certRequest.Status.ApprovedAt = approval.LastUpdateTime.DeepCopy()
+		// record what has actually been granted, which can differ from the request
+		notBefore := metav1.NewTime(x509Cert.NotBefore)
+		notAfter := metav1.NewTime(x509Cert.NotAfter)
+		certRequest.Status.NotBefore = &notBefore
+		certRequest.Status.NotAfter = &notAfter
+		certRequest.Status.Usages = certificates.CertificateUsages(x509Cert)
+		certRequest.Status.IsCA = x509Cert.IsCA
+		certRequest.Status.Phase = certificatev1alpha2.CertificateSigned
+		certRequest.Status.RequestHash = certRequest.Spec.GetRequestHash()
+		certRequest.Status.Reason = certificatev1alpha2.StatusReasonProcessedApprovedSignedIssued
+		certRequest.Status.Message = message
+	})
+}
+
+// updateCertChain replaces the published root CAs and chain of a signed certificate with the ones of its issuer,
+// and flags it for re-issue if it has been issued by a retiring CA
+func (c *CertificateController) updateCertChain(certRequestObj *certificatev1alpha2.Certificate, ca, chain []byte, retiring bool) error {
+	eventType, eventReason := corev1.EventTypeNormal, EventReasonChainUpdated
+	message := "CA certificates did not match the chain of the issuer, and have been replaced"
+	if retiring {
+		eventType, eventReason = corev1.EventTypeWarning, EventReasonReissueRequired
+		message = "Certificate has been issued by a retiring CA, and must be re-issued"
 	}
+	return c.updateStatus(certRequestObj, eventType, eventReason, message, func(certRequest *certificatev1alpha2.Certificate) {
+		certRequest.Status.Ca = ca
+		certRequest.Status.Chain = chain
+		certRequest.Status.ReissueRequired = retiring
+	})
 }
 
-// updateCertSigned is called when a request has been successfully processed/approved/signed
-func (c *CertificateController) updateCertSigned(certRequestObj *certificatev1alpha2.Certificate, cert, token []byte) {
+// updateStatus applies `mutate` to a copy of the Cert request and writes its status through the status subresource.
+// On a conflict, the update is retried against a fresh read of the object, as long as the phase and the request
+// that the new status has been computed from did not change in the meantime. If they did, the update is dropped,
+// as the change will trigger a new reconciliation anyway.
+// Once the status has been written, an event of type `eventType` is recorded on the object, unless `eventReason` is empty.
+func (c *CertificateController) updateStatus(certRequestObj *certificatev1alpha2.Certificate, eventType, eventReason, eventMessage string, mutate func(certRequest *certificatev1alpha2.Certificate)) error {
+	phase := certRequestObj.Status.Phase
+	requestHash := certRequestObj.Spec.GetRequestHash()
+
+	var updated *certificatev1alpha2.Certificate
 	certRequest := certRequestObj.DeepCopy()
-	certRequest.Status.Certificate = cert
-	certRequest.Status.Ca = c.issuer.GetCACert()
-	certRequest.Status.Token = token
-	certRequest.Status.Phase = certificatev1alpha2.CertificateSigned
-	certRequest.Status.Reason = certificatev1alpha2.StatusReasonProcessedApprovedSignedIssued
-	certRequest.Status.Message = "CSR has been processed and approved, and the Certificate has been signed and issued"
-
-	// we can not use UpdateStatus until this issue gets resolved afaik
-	// https://github.com/kubernetes/kubernetes/issues/38113
-	_, err := c.certificateClient.CertmanagerV1alpha2().Certificates().Update(certRequest)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		candidate := certRequest.DeepCopy()
+		mutate(candidate)
+
+		result, err := c.certificateClient.CertmanagerV1alpha2().Certificates().UpdateStatus(candidate)
+		if err == nil {
+			updated = result
+			return nil
+		}
+		if !errors.IsConflict(err) {
+			return err
+		}
+
+		fresh, getErr := c.certificateClient.CertmanagerV1alpha2().Certificates().Get(certRequest.Name, metav1.GetOptions{})
+		if getErr != nil {
+			return getErr
+		}
+		if fresh.Status.Phase != phase || fresh.Spec.GetRequestHash() != requestHash {
+			zap.L().Debug("Cert request changed while updating its status, dropping update", zap.String("name", fresh.Name), zap.String("resource_version", fresh.ResourceVersion))
+			return nil
+		}
+		certRequest = fresh
+		return err
+	})
 	if err != nil {
-		zap.L().Error("Error Updating the Certificate ressource", zap.Error(err), zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
-		return
+		if errors.IsNotFound(err) {
+			zap.L().Debug("Cert request has been deleted while updating its status", zap.String("name", certRequest.Name))
+			return nil
+		}
+		zap.L().Error("Error Updating the Certificate status", zap.Error(err), zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
+		return fmt.Errorf("failed to update status of Certificate '%s': %s", certRequest.Name, err.Error())
 	}
+
+	if updated != nil && eventReason != "" {
+		metrics.ObserveStatusUpdate(string(updated.Status.Phase), updated.Status.Reason)
+		c.recorder.Event(updated, eventType, eventReason, eventMessage)
+	}
+	return nil
 }
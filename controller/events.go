@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"go.uber.org/zap"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	certificatescheme "github.com/CodingJzy/trireme-csr/pkg/client/clientset/versioned/scheme"
+)
+
+// controllerAgentName is the component name used as the source of the events.
+const controllerAgentName = "trireme-csr"
+
+// Event reasons for the events recorded on Certificate objects. Events for rejections
+// use the `StatusReason*` value of the status as their reason.
+const (
+	EventReasonSubmitted         = "Submitted"
+	EventReasonSigned            = "Signed"
+	EventReasonUnknown           = "Unknown"
+	EventReasonValidationFailed  = "ValidationFailed"
+	EventReasonRevoked           = "Revoked"
+	EventReasonInvalidRevocation = "InvalidRevocation"
+	EventReasonPendingApproval   = "PendingApproval"
+	EventReasonChainUpdated      = "ChainUpdated"
+	EventReasonReissueRequired   = "ReissueRequired"
+	EventReasonTokenRefreshed    = "TokenRefreshed"
+	EventReasonSigningFailed     = "SigningFailed"
+	EventReasonInvalidApproval   = "InvalidApproval"
+)
+
+func init() {
+	// the event recorder needs to know our types to build references to them
+	certificatescheme.AddToScheme(scheme.Scheme)
+}
+
+// newEventRecorder creates an event recorder which sends events to the Kubernetes API.
+func newEventRecorder(kubeClient kubernetes.Interface) record.EventRecorder {
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(zap.L().Sugar().Debugf)
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	return eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: controllerAgentName})
+}
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// workerTimeout is the time after which a worker that is still busy with the same Cert request is considered stuck,
+	// and after which the workers are considered dead if none of them picked up a heartbeat.
+	workerTimeout = 5 * time.Minute
+
+	// heartbeatInterval is the interval at which a heartbeat is added to the work queue
+	heartbeatInterval = 30 * time.Second
+	// heartbeatKey is the work queue key of the heartbeat. It is not a valid object name, so it never clashes with a Cert request.
+	heartbeatKey = "$heartbeat"
+
+	// informerTimeout is the time after which the informer is considered stuck if it did not deliver any event,
+	// while it holds objects that must be delivered again on every resync
+	informerTimeout = 10 * ResyncPeriod
+)
+
+// watchdog keeps track of the Cert requests that workers are currently busy with,
+// of the last heartbeat that a worker picked up, and of the last event that the informer delivered.
+type watchdog struct {
+	sync.Mutex
+	started   bool
+	synced    bool
+	busy      map[string]time.Time
+	heartbeat time.Time
+	lastEvent time.Time
+}
+
+func newWatchdog() *watchdog {
+	return &watchdog{
+		busy: map[string]time.Time{},
+	}
+}
+
+func (w *watchdog) setStarted() {
+	w.Lock()
+	defer w.Unlock()
+	w.started = true
+	w.heartbeat = time.Now()
+}
+
+func (w *watchdog) setSynced() {
+	w.Lock()
+	defer w.Unlock()
+	w.synced = true
+}
+
+func (w *watchdog) markBusy(name string) {
+	w.Lock()
+	defer w.Unlock()
+	w.busy[name] = time.Now()
+}
+
+func (w *watchdog) markIdle(name string) {
+	w.Lock()
+	defer w.Unlock()
+	delete(w.busy, name)
+}
+
+func (w *watchdog) beat() {
+	w.Lock()
+	defer w.Unlock()
+	w.heartbeat = time.Now()
+}
+
+func (w *watchdog) observeEvent() {
+	w.Lock()
+	defer w.Unlock()
+	w.lastEvent = time.Now()
+}
+
+// sendHeartbeat adds the heartbeat to the work queue, where it gets picked up by the next free worker
+func (c *CertificateController) sendHeartbeat() {
+	c.queue.Add(heartbeatKey)
+}
+
+// Healthy returns an error if one of the workers is stuck, if none of the workers picked up a heartbeat for too long,
+// or if the informer stopped delivering events. It is meant to be used as a liveness check.
+func (c *CertificateController) Healthy() error {
+	c.watchdog.Lock()
+	defer c.watchdog.Unlock()
+
+	for name, since := range c.watchdog.busy {
+		if time.Since(since) > workerTimeout {
+			return fmt.Errorf("worker has been busy with Cert request '%s' since %s", name, since.Format(time.RFC3339))
+		}
+	}
+	if c.watchdog.synced && time.Since(c.watchdog.heartbeat) > workerTimeout {
+		return fmt.Errorf("no worker picked up a heartbeat since %s", c.watchdog.heartbeat.Format(time.RFC3339))
+	}
+
+	// the informer runs on every replica, and delivers all its objects again on every resync
+	informer := c.certificateInformer.Informer()
+	if informer.HasSynced() && len(informer.GetStore().ListKeys()) > 0 && time.Since(c.watchdog.lastEvent) > informerTimeout {
+		return fmt.Errorf("informer did not deliver any event since %s", c.watchdog.lastEvent.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Ready returns an error if the controller is not able to process Cert requests yet or anymore:
+// the informer cache must have synced, and the issuer must still be able to sign certificates.
+// It is meant to be used as a readiness check.
+func (c *CertificateController) Ready() error {
+	c.watchdog.Lock()
+	started, synced := c.watchdog.started, c.watchdog.synced
+	c.watchdog.Unlock()
+
+	if !started {
+		return fmt.Errorf("controller has not been started")
+	}
+	if !synced {
+		return fmt.Errorf("informer cache has not synced yet")
+	}
+	if err := c.issuer.Healthy(); err != nil {
+		return fmt.Errorf("issuer is not able to sign: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/record"
+
+	"github.com/CodingJzy/trireme-csr/certificates"
+)
+
+// newTestCAData creates a self-signed ECDSA CA, and returns the PEM encoded certificate and key
+func newTestCAData(t *testing.T, commonName string) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %s", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to encode CA key: %s", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+// newTestIssuer creates a TriremeIssuer with a new self-signed ECDSA CA
+func newTestIssuer(t *testing.T, commonName string) *certificates.TriremeIssuer {
+	t.Helper()
+
+	certPEM, keyPEM := newTestCAData(t, commonName)
+	issuer, err := certificates.NewTriremeIssuerFromData(certPEM, keyPEM, "")
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+	return issuer
+}
+
+// signTestCert signs a certificate for a new ECDSA key with the issuer
+func signTestCert(t *testing.T, issuer certificates.Issuer, commonName string) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: commonName}}, key)
+	if err != nil {
+		t.Fatalf("failed to create CSR: %s", err)
+	}
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	if err != nil {
+		t.Fatalf("failed to parse CSR: %s", err)
+	}
+	certPEM, err := issuer.Sign(csr, &certificates.SignOptions{})
+	if err != nil {
+		t.Fatalf("failed to sign certificate: %s", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		t.Fatalf("failed to decode certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %s", err)
+	}
+	return cert
+}
+
+// newTestController returns a controller with the default issuer and the loaded issuers by name
+func newTestController(issuer certificates.Issuer, issuers map[string]*certificates.TriremeIssuer) *CertificateController {
+	c := &CertificateController{
+		issuer:   issuer,
+		issuers:  map[string]*issuerEntry{},
+		recorder: record.NewFakeRecorder(100),
+	}
+	for name, namedIssuer := range issuers {
+		c.issuers[name] = &issuerEntry{issuer: namedIssuer}
+	}
+	return c
+}
@@ -0,0 +1,593 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/CodingJzy/trireme-csr/certificates"
+	"github.com/CodingJzy/trireme-csr/ocspresponder"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+
+	certificatev1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+)
+
+// IssuerConfig holds the settings of the issuers that get built from Issuer resources
+type IssuerConfig struct {
+	// Configure applies the controller wide settings to an issuer, like the issuance policy
+	Configure func(issuer *certificates.TriremeIssuer) error
+	// DefaultDuration and MaxDuration apply if the Issuer does not set them
+	DefaultDuration time.Duration
+	MaxDuration     time.Duration
+	// CRLUpdateInterval is the interval at which the CRLs of the issuers get regenerated
+	CRLUpdateInterval time.Duration
+}
+
+// issuerEntry is the issuer built from a generation of an Issuer resource
+type issuerEntry struct {
+	generation int64
+	issuer     *certificates.TriremeIssuer
+	spec       *certificatev1alpha2.IssuerSpec
+	// err is set if the issuer could not be built
+	err error
+	// reloadError is the error of the last reload of the CA, which only gets logged when it changes. It is only
+	// used by the issuer worker.
+	reloadError string
+	// stopCh stops the background tasks of the issuer
+	stopCh chan struct{}
+}
+
+func (c *CertificateController) onIssuerAdd(obj interface{}) {
+	issuerObj, ok := obj.(*certificatev1alpha2.Issuer)
+	if !ok {
+		zap.L().Sugar().Errorf("Received wrong object type in adding Issuer event: '%T", obj)
+		return
+	}
+	c.issuerQueue.Add(issuerObj.Name)
+}
+
+func (c *CertificateController) onIssuerUpdate(oldObj, newObj interface{}) {
+	issuerObj, ok := newObj.(*certificatev1alpha2.Issuer)
+	if !ok {
+		zap.L().Sugar().Errorf("Received wrong object type in updating Issuer event: '%T", newObj)
+		return
+	}
+	// resyncs are queued as well, so that issuers that could not be built get retried
+	c.issuerQueue.Add(issuerObj.Name)
+}
+
+func (c *CertificateController) onIssuerDelete(obj interface{}) {
+	issuerObj, ok := obj.(*certificatev1alpha2.Issuer)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			zap.L().Sugar().Errorf("Received wrong object type in deleting Issuer event: '%T", obj)
+			return
+		}
+		issuerObj, ok = tombstone.Obj.(*certificatev1alpha2.Issuer)
+		if !ok {
+			zap.L().Sugar().Errorf("Received wrong object type in deleting Issuer event tombstone: '%T", tombstone.Obj)
+			return
+		}
+	}
+	c.issuerQueue.Add(issuerObj.Name)
+}
+
+func (c *CertificateController) onSecretAdd(obj interface{}) {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		zap.L().Sugar().Errorf("Received wrong object type in adding Secret event: '%T", obj)
+		return
+	}
+	c.enqueueIssuersOf(secret)
+}
+
+func (c *CertificateController) onSecretUpdate(oldObj, newObj interface{}) {
+	secret, ok := newObj.(*corev1.Secret)
+	if !ok {
+		zap.L().Sugar().Errorf("Received wrong object type in updating Secret event for new object: '%T", newObj)
+		return
+	}
+	oldSecret, ok := oldObj.(*corev1.Secret)
+	if !ok {
+		zap.L().Sugar().Errorf("Received wrong object type in updating Secret event for old object: '%T", oldObj)
+		return
+	}
+	// the Issuers get resynced on their own, so that a Secret resync changes nothing
+	if secret.ResourceVersion == oldSecret.ResourceVersion {
+		return
+	}
+	c.enqueueIssuersOf(secret)
+}
+
+func (c *CertificateController) onSecretDelete(obj interface{}) {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			zap.L().Sugar().Errorf("Received wrong object type in deleting Secret event: '%T", obj)
+			return
+		}
+		secret, ok = tombstone.Obj.(*corev1.Secret)
+		if !ok {
+			zap.L().Sugar().Errorf("Received wrong object type in deleting Secret event tombstone: '%T", tombstone.Obj)
+			return
+		}
+	}
+	c.enqueueIssuersOf(secret)
+}
+
+// enqueueIssuersOf queues the Issuers that reference the Secret, so that their CA gets reloaded, or they get built
+// again if a missing Secret prevented it
+func (c *CertificateController) enqueueIssuersOf(secret *corev1.Secret) {
+	issuerObjs, err := c.issuerLister.List(labels.Everything())
+	if err != nil {
+		zap.L().Error("Error listing Issuers", zap.Error(err))
+		return
+	}
+	for _, issuerObj := range issuerObjs {
+		if referencesSecret(&issuerObj.Spec, secret.Namespace, secret.Name) {
+			c.issuerQueue.Add(issuerObj.Name)
+		}
+	}
+}
+
+// referencesSecret returns true if the Issuer spec reads the Secret of the given namespace and name
+func referencesSecret(spec *certificatev1alpha2.IssuerSpec, namespace, name string) bool {
+	matches := func(ref *certificatev1alpha2.SecretReference) bool {
+		return ref.Namespace == namespace && ref.Name == name
+	}
+	if matches(&spec.CA) {
+		return true
+	}
+	for i := range spec.TrustedCAs {
+		if matches(&spec.TrustedCAs[i]) {
+			return true
+		}
+	}
+	return spec.Token != nil && spec.Token.SigningKey != nil && matches(spec.Token.SigningKey)
+}
+
+// RunIssuers loads the issuers of the Issuer resources until stopCh closes. Unlike Run, it runs on every replica,
+// so that all of them serve the CRLs and OCSP responses of all issuers.
+func (c *CertificateController) RunIssuers(stopCh <-chan struct{}) {
+	defer c.issuerQueue.ShutDown()
+
+	if !cache.WaitForCacheSync(stopCh, c.certificateInformer.Informer().HasSynced, c.issuerInformer.Informer().HasSynced, c.secretInformer.Informer().HasSynced) {
+		zap.L().Error("Error while waiting for the Issuer caches to sync")
+		return
+	}
+
+	go wait.Until(c.runIssuerWorker, time.Second, stopCh)
+	<-stopCh
+}
+
+// runIssuerWorker processes items of the Issuer work queue until it gets shut down
+func (c *CertificateController) runIssuerWorker() {
+	for c.processNextIssuer() {
+	}
+}
+
+// processNextIssuer loads, reloads or removes the issuer of the next Issuer of the work queue, outside of the
+// informer handlers, as this parses the CAs of their Secrets. It returns false once the queue has been shut down.
+func (c *CertificateController) processNextIssuer() bool {
+	key, quit := c.issuerQueue.Get()
+	if quit {
+		return false
+	}
+	defer c.issuerQueue.Done(key)
+
+	if err := c.syncIssuer(key.(string)); err != nil {
+		zap.L().Warn("Error loading Issuer, retrying", zap.Error(err), zap.String("issuer", key.(string)))
+		c.issuerQueue.AddRateLimited(key)
+		return true
+	}
+
+	c.issuerQueue.Forget(key)
+	return true
+}
+
+// syncIssuer brings the issuer in line with the Issuer resource of the given name
+func (c *CertificateController) syncIssuer(name string) error {
+	issuerObj, err := c.issuerLister.Get(name)
+	if errors.IsNotFound(err) {
+		c.removeIssuer(name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// the generation only changes with the spec, so that status updates and resyncs do not rebuild the issuer,
+	// unless it could not be built before
+	c.issuersLock.RLock()
+	entry, ok := c.issuers[name]
+	c.issuersLock.RUnlock()
+	if ok && entry.generation == issuerObj.Generation && entry.err == nil {
+		// the CA Secret can change without the Issuer, so it gets reloaded from the Secret cache whenever the
+		// Issuer or one of its Secrets changes
+		c.reloadIssuerCA(name, entry)
+		return nil
+	}
+	c.loadIssuer(issuerObj)
+	return nil
+}
+
+// removeIssuer stops and forgets the issuer of a deleted Issuer
+func (c *CertificateController) removeIssuer(name string) {
+	c.issuersLock.Lock()
+	defer c.issuersLock.Unlock()
+	if entry, ok := c.issuers[name]; ok {
+		zap.L().Info("Issuer deleted", zap.String("issuer", name))
+		close(entry.stopCh)
+		delete(c.issuers, name)
+	}
+}
+
+// loadIssuer builds the issuer of the Issuer resource and replaces the previous one.
+// It runs on every replica, so that all of them serve the CRLs of all issuers.
+func (c *CertificateController) loadIssuer(issuerObj *certificatev1alpha2.Issuer) {
+	entry := &issuerEntry{
+		generation: issuerObj.Generation,
+		spec:       issuerObj.Spec.DeepCopy(),
+		stopCh:     make(chan struct{}),
+	}
+	entry.issuer, entry.err = c.buildIssuer(&issuerObj.Spec)
+	if entry.err != nil {
+		zap.L().Error("Error loading Issuer", zap.Error(entry.err), zap.String("issuer", issuerObj.Name))
+	} else {
+		zap.L().Info("Issuer loaded", zap.String("issuer", issuerObj.Name), zap.Int64("generation", issuerObj.Generation))
+		// revocations are only kept in memory, so the new issuer must learn them again
+		if err := entry.issuer.AddRevocations(c.revocations.get(issuerObj.Name)); err != nil {
+			zap.L().Error("Error applying revocations", zap.Error(err), zap.String("issuer", issuerObj.Name))
+		}
+		go entry.issuer.RunCRLUpdater(c.issuerConfig.CRLUpdateInterval, entry.stopCh)
+	}
+
+	c.issuersLock.Lock()
+	if previous, ok := c.issuers[issuerObj.Name]; ok {
+		close(previous.stopCh)
+	}
+	c.issuers[issuerObj.Name] = entry
+	c.issuersLock.Unlock()
+
+	c.issuerStatusQueue.Add(issuerObj.Name)
+
+	// the Cert requests that wait for this issuer can be processed now
+	certRequests, err := c.certificateLister.List(labels.Everything())
+	if err != nil {
+		zap.L().Error("Error listing Cert requests", zap.Error(err))
+		return
+	}
+	for _, certRequest := range certRequests {
+		if issuerName(certRequest) != issuerObj.Name {
+			continue
+		}
+		c.enqueue(certRequest)
+	}
+}
+
+// reloadIssuerCA reloads the CA of a loaded issuer from its Secret. The issuer keeps its current CA if this fails,
+// and the error is logged once until it changes.
+func (c *CertificateController) reloadIssuerCA(name string, entry *issuerEntry) {
+	caCertPEM, caKeyPEM, keyPass, err := c.readSecret(&entry.spec.CA)
+	if err == nil {
+		err = entry.issuer.Reload(caCertPEM, caKeyPEM, keyPass)
+	}
+	if err == nil {
+		entry.reloadError = ""
+		return
+	}
+	if err.Error() == entry.reloadError {
+		return
+	}
+	entry.reloadError = err.Error()
+	zap.L().Error("Error reloading Issuer CA, keeping the current one", zap.Error(err), zap.String("issuer", name))
+}
+
+// buildIssuer creates an issuer from the CA Secret and the settings of the Issuer spec
+func (c *CertificateController) buildIssuer(spec *certificatev1alpha2.IssuerSpec) (*certificates.TriremeIssuer, error) {
+	caCertPEM, caKeyPEM, keyPass, err := c.readSecret(&spec.CA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA: %s", err)
+	}
+	issuer, err := certificates.NewTriremeIssuerFromData(caCertPEM, caKeyPEM, keyPass)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load CA: %s", err)
+	}
+
+	for i := range spec.TrustedCAs {
+		ref := &spec.TrustedCAs[i]
+		trustedPEM, err := c.readSecretCertificate(ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trusted CA %s/%s: %s", ref.Namespace, ref.Name, err)
+		}
+		if err := issuer.AddTrustedCA(trustedPEM); err != nil {
+			return nil, fmt.Errorf("failed to load trusted CA %s/%s: %s", ref.Namespace, ref.Name, err)
+		}
+	}
+
+	if spec.SignatureAlgorithm != "" {
+		if err := issuer.SetSignatureAlgorithm(spec.SignatureAlgorithm); err != nil {
+			return nil, err
+		}
+	}
+
+	defaultDuration := c.issuerConfig.DefaultDuration
+	maxDuration := c.issuerConfig.MaxDuration
+	if spec.MaxDuration != nil {
+		maxDuration = spec.MaxDuration.Duration
+	}
+	if spec.Duration != nil {
+		defaultDuration = spec.Duration.Duration
+	} else if defaultDuration > maxDuration {
+		defaultDuration = maxDuration
+	}
+	if err := issuer.SetDurations(defaultDuration, maxDuration); err != nil {
+		return nil, err
+	}
+
+	if spec.Token != nil {
+		if err := issuer.SetTokenOptions(certificates.NewTokenOptions(spec.Token)); err != nil {
+			return nil, fmt.Errorf("invalid token settings: %s", err)
+		}
+	}
+	if spec.Token != nil && spec.Token.SigningKey != nil {
+		_, tokenKeyPEM, tokenKeyPass, err := c.readSecret(spec.Token.SigningKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read token signing key: %s", err)
+		}
+		tokenKey, err := certificates.ParsePrivateKeyPEM(tokenKeyPEM, tokenKeyPass)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load token signing key: %s", err)
+		}
+		if err := issuer.SetTokenSigningKey(tokenKey); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.issuerConfig.Configure != nil {
+		if err := c.issuerConfig.Configure(issuer); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := issuer.ValidateTokenIssuer(); err != nil {
+		return nil, err
+	}
+
+	return issuer, nil
+}
+
+// readSecret returns the PEM certificate, the PEM private key and the passphrase of the referenced Secret
+func (c *CertificateController) readSecret(ref *certificatev1alpha2.SecretReference) ([]byte, []byte, string, error) {
+	secret, err := c.secretLister.Secrets(ref.Namespace).Get(ref.Name)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	certKey := secretCertificateKey(ref)
+	keyKey := ref.PrivateKeyKey
+	if keyKey == "" {
+		keyKey = certificatev1alpha2.DefaultSecretPrivateKeyKey
+	}
+
+	keyPEM, ok := secret.Data[keyKey]
+	if !ok {
+		return nil, nil, "", fmt.Errorf("Secret %s/%s has no key '%s'", ref.Namespace, ref.Name, keyKey)
+	}
+
+	var keyPass string
+	if ref.PassphraseKey != "" {
+		pass, ok := secret.Data[ref.PassphraseKey]
+		if !ok {
+			return nil, nil, "", fmt.Errorf("Secret %s/%s has no key '%s'", ref.Namespace, ref.Name, ref.PassphraseKey)
+		}
+		// Secrets created from files usually end with a newline, which is never part of the passphrase
+		keyPass = strings.TrimRight(string(pass), "\r\n")
+	}
+
+	return secret.Data[certKey], keyPEM, keyPass, nil
+}
+
+// readSecretCertificate returns the PEM certificate of the referenced Secret
+func (c *CertificateController) readSecretCertificate(ref *certificatev1alpha2.SecretReference) ([]byte, error) {
+	secret, err := c.secretLister.Secrets(ref.Namespace).Get(ref.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	certKey := secretCertificateKey(ref)
+	certPEM, ok := secret.Data[certKey]
+	if !ok {
+		return nil, fmt.Errorf("Secret %s/%s has no key '%s'", ref.Namespace, ref.Name, certKey)
+	}
+	return certPEM, nil
+}
+
+// secretCertificateKey returns the key of the certificate in the referenced Secret
+func secretCertificateKey(ref *certificatev1alpha2.SecretReference) string {
+	if ref.CertificateKey == "" {
+		return certificatev1alpha2.DefaultSecretCertificateKey
+	}
+	return ref.CertificateKey
+}
+
+// issuerName returns the name of the Issuer that the Cert request references, or an empty string for the default issuer
+func issuerName(certRequest *certificatev1alpha2.Certificate) string {
+	if certRequest.Spec.IssuerRef == nil {
+		return ""
+	}
+	return certRequest.Spec.IssuerRef.Name
+}
+
+// issuerFor returns the issuer of the Cert request and the spec of its Issuer, which is nil for the default issuer.
+// A NotFound error is returned if the referenced Issuer does not exist.
+func (c *CertificateController) issuerFor(certRequest *certificatev1alpha2.Certificate) (certificates.Issuer, *certificatev1alpha2.IssuerSpec, error) {
+	name := issuerName(certRequest)
+	if name == "" {
+		return c.issuer, nil, nil
+	}
+
+	if _, err := c.issuerLister.Get(name); err != nil {
+		return nil, nil, err
+	}
+
+	c.issuersLock.RLock()
+	entry, ok := c.issuers[name]
+	c.issuersLock.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("issuer '%s' has not been loaded yet", name)
+	}
+	if entry.err != nil {
+		return nil, nil, fmt.Errorf("issuer '%s' is not ready: %s", name, entry.err)
+	}
+	return entry.issuer, entry.spec, nil
+}
+
+// retiredCAsIssuer is implemented by the issuers that keep their signing CAs after a reload
+type retiredCAsIssuer interface {
+	RetiredCAs() []*certificates.RetiredCA
+}
+
+// OCSPSigners returns the default issuer and all loaded issuers, and the signing CAs they replaced, so that the
+// OCSP responder answers for all of them
+func (c *CertificateController) OCSPSigners() []ocspresponder.Signer {
+	var signers []ocspresponder.Signer
+	if signer, ok := c.issuer.(ocspresponder.Signer); ok {
+		signers = append(signers, signer)
+	}
+	if issuer, ok := c.issuer.(retiredCAsIssuer); ok {
+		for _, retired := range issuer.RetiredCAs() {
+			signers = append(signers, retired)
+		}
+	}
+
+	c.issuersLock.RLock()
+	defer c.issuersLock.RUnlock()
+	for _, entry := range c.issuers {
+		if entry.err != nil {
+			continue
+		}
+		signers = append(signers, entry.issuer)
+		for _, retired := range entry.issuer.RetiredCAs() {
+			signers = append(signers, retired)
+		}
+	}
+	return signers
+}
+
+// runIssuerStatusWorker processes items of the Issuer status work queue until it gets shut down
+func (c *CertificateController) runIssuerStatusWorker() {
+	for c.processNextIssuerStatus() {
+	}
+}
+
+// enqueueIssuerStatuses queues the status update of all loaded issuers
+func (c *CertificateController) enqueueIssuerStatuses() {
+	c.issuersLock.RLock()
+	defer c.issuersLock.RUnlock()
+	for name := range c.issuers {
+		c.issuerStatusQueue.Add(name)
+	}
+}
+
+// processNextIssuerStatus updates the status of the next Issuer of the status work queue.
+// It returns false once the queue has been shut down.
+func (c *CertificateController) processNextIssuerStatus() bool {
+	key, quit := c.issuerStatusQueue.Get()
+	if quit {
+		return false
+	}
+	defer c.issuerStatusQueue.Done(key)
+
+	if err := c.updateIssuerStatus(key.(string)); err != nil {
+		zap.L().Warn("Error updating Issuer status, retrying", zap.Error(err), zap.String("issuer", key.(string)))
+		c.issuerStatusQueue.AddRateLimited(key)
+		return true
+	}
+
+	c.issuerStatusQueue.Forget(key)
+	return true
+}
+
+// updateIssuerStatus reports if the issuer of the current generation of the Issuer has been loaded
+func (c *CertificateController) updateIssuerStatus(name string) error {
+	issuerObj, err := c.issuerLister.Get(name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	c.issuersLock.RLock()
+	entry, ok := c.issuers[name]
+	c.issuersLock.RUnlock()
+	if !ok || entry.generation != issuerObj.Generation {
+		// the new generation gets queued again once it has been loaded
+		return nil
+	}
+
+	status := certificatev1alpha2.IssuerStatus{
+		Ready:              true,
+		Reason:             certificatev1alpha2.IssuerStatusReasonLoaded,
+		Message:            "The Issuer has been loaded and can sign certificates",
+		ObservedGeneration: entry.generation,
+	}
+	if entry.err != nil {
+		status.Ready = false
+		status.Reason = certificatev1alpha2.IssuerStatusReasonFailed
+		status.Message = entry.err.Error()
+	}
+	if issuerObj.Status == status {
+		return nil
+	}
+
+	update := issuerObj.DeepCopy()
+	update.Status = status
+	_, err = c.certificateClient.CertmanagerV1alpha2().Issuers().UpdateStatus(update)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// issuerAllows returns true if the Issuer allows the Cert request to be signed by it. The default issuer,
+// whose spec is nil, can be used by all Cert requests. Renewals are allowed under the name that they renew.
+func issuerAllows(spec *certificatev1alpha2.IssuerSpec, certRequest *certificatev1alpha2.Certificate) bool {
+	return spec == nil || certificates.MatchesAny(spec.AllowedCertificates, requestName(certRequest))
+}
+
+// requestName returns the name of the Certificate that a renewal renews, or the name of the Cert request itself.
+// The label is set by the creator of the Cert request just like its name, so it grants nothing that a name could not.
+func requestName(certRequest *certificatev1alpha2.Certificate) string {
+	if renewalOf := certRequest.Labels[certificatev1alpha2.RenewalOfLabel]; renewalOf != "" {
+		return renewalOf
+	}
+	return certRequest.Name
+}
+
+// applyProfile fills the options that the Cert request did not set with the defaults of its profile on the Issuer
+func applyProfile(options *certificates.SignOptions, spec *certificatev1alpha2.IssuerSpec, profile string) {
+	if spec == nil {
+		return
+	}
+	defaults, ok := spec.Profiles[profile]
+	if !ok {
+		return
+	}
+	if options.Duration == 0 && defaults.Duration != nil {
+		options.Duration = defaults.Duration.Duration
+	}
+	if len(options.Usages) == 0 {
+		options.Usages = defaults.Usages
+	}
+}
@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	certificatev1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+	certificatefake "github.com/CodingJzy/trireme-csr/pkg/client/clientset/versioned/fake"
+	certificateinformers "github.com/CodingJzy/trireme-csr/pkg/client/informers/externalversions"
+)
+
+// newIssuerTestController returns a controller built on informers that are not started, so that the tests fill
+// their caches directly
+func newIssuerTestController(t *testing.T) (*CertificateController, *kubefake.Clientset) {
+	t.Helper()
+
+	kubeClient := kubefake.NewSimpleClientset()
+	c := NewCertificateController(
+		certificatefake.NewSimpleClientset(),
+		kubeClient,
+		certificateinformers.NewSharedInformerFactory(certificatefake.NewSimpleClientset(), 0),
+		kubeinformers.NewSharedInformerFactory(kubeClient, 0),
+		nil,
+		ApprovalPolicy{},
+		IssuerConfig{DefaultDuration: time.Hour, MaxDuration: 24 * time.Hour, CRLUpdateInterval: time.Hour},
+		NewRevocationStore(kubeClient, "default", "revocations"),
+	)
+	return c, kubeClient
+}
+
+// newTestCASecret returns a Secret with a new self-signed CA
+func newTestCASecret(t *testing.T, name, resourceVersion string) *corev1.Secret {
+	t.Helper()
+
+	certPEM, keyPEM := newTestCAData(t, name)
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, ResourceVersion: resourceVersion},
+		Data: map[string][]byte{
+			certificatev1alpha2.DefaultSecretCertificateKey: certPEM,
+			certificatev1alpha2.DefaultSecretPrivateKeyKey:  keyPEM,
+		},
+	}
+}
+
+// newTestCertRequestFor returns a Cert request that references the Issuer
+func newTestCertRequestFor(issuer string) *certificatev1alpha2.Certificate {
+	return &certificatev1alpha2.Certificate{
+		ObjectMeta: metav1.ObjectMeta{Name: "node"},
+		Spec:       certificatev1alpha2.CertificateSpec{IssuerRef: &certificatev1alpha2.IssuerReference{Name: issuer}},
+	}
+}
+
+// queuedIssuers drains the Issuer queue and returns the sorted names it held
+func queuedIssuers(c *CertificateController) []string {
+	var names []string
+	for c.issuerQueue.Len() > 0 {
+		key, _ := c.issuerQueue.Get()
+		names = append(names, key.(string))
+		c.issuerQueue.Done(key)
+		c.issuerQueue.Forget(key)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSyncIssuerReadsSecretCache(t *testing.T) {
+	c, kubeClient := newIssuerTestController(t)
+	secretCache := c.secretInformer.Informer().GetIndexer()
+	issuerObj := &certificatev1alpha2.Issuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "named", Generation: 1},
+		Spec:       certificatev1alpha2.IssuerSpec{CA: certificatev1alpha2.SecretReference{Namespace: "default", Name: "ca"}},
+	}
+	if err := c.issuerInformer.Informer().GetIndexer().Add(issuerObj); err != nil {
+		t.Fatalf("failed to add Issuer: %s", err)
+	}
+	defer c.removeIssuer("named")
+
+	// the CA Secret is missing from the cache
+	if err := c.syncIssuer("named"); err != nil {
+		t.Fatalf("syncIssuer() error = %s", err)
+	}
+	if _, _, err := c.issuerFor(newTestCertRequestFor("named")); err == nil {
+		t.Errorf("issuer has been loaded without its CA Secret")
+	}
+
+	caSecret := newTestCASecret(t, "ca", "1")
+	if err := secretCache.Add(caSecret); err != nil {
+		t.Fatalf("failed to add Secret: %s", err)
+	}
+	if err := c.syncIssuer("named"); err != nil {
+		t.Fatalf("syncIssuer() error = %s", err)
+	}
+	issuer, _, err := c.issuerFor(newTestCertRequestFor("named"))
+	if err != nil {
+		t.Fatalf("issuer has not been loaded from the cached Secret: %s", err)
+	}
+
+	// a new CA in the Secret gets reloaded into the same issuer
+	rotated := newTestCASecret(t, "ca", "2")
+	if err := secretCache.Update(rotated); err != nil {
+		t.Fatalf("failed to update Secret: %s", err)
+	}
+	if err := c.syncIssuer("named"); err != nil {
+		t.Fatalf("syncIssuer() error = %s", err)
+	}
+	reloaded, _, err := c.issuerFor(newTestCertRequestFor("named"))
+	if err != nil {
+		t.Fatalf("issuer failed after the CA rotation: %s", err)
+	}
+	if reloaded != issuer {
+		t.Errorf("CA rotation rebuilt the issuer instead of reloading it")
+	}
+	caCert := rotated.Data[certificatev1alpha2.DefaultSecretCertificateKey]
+	if !bytes.Equal(reloaded.GetCACert(), caCert) {
+		t.Errorf("issuer did not reload the CA of the updated Secret")
+	}
+
+	// a deleted Secret keeps the current CA
+	if err := secretCache.Delete(rotated); err != nil {
+		t.Fatalf("failed to delete Secret: %s", err)
+	}
+	if err := c.syncIssuer("named"); err != nil {
+		t.Fatalf("syncIssuer() error = %s", err)
+	}
+	if !bytes.Equal(reloaded.GetCACert(), caCert) {
+		t.Errorf("deleted CA Secret replaced the current CA")
+	}
+
+	for _, action := range kubeClient.Actions() {
+		if action.GetResource().Resource == "secrets" {
+			t.Errorf("syncIssuer() sent a %s request for Secrets instead of reading the cache", action.GetVerb())
+		}
+	}
+}
+
+func TestSecretEventsEnqueueIssuers(t *testing.T) {
+	c, _ := newIssuerTestController(t)
+	shared := certificatev1alpha2.SecretReference{Namespace: "default", Name: "shared"}
+	// the Secret of the same name in another namespace is not the shared one
+	other := certificatev1alpha2.SecretReference{Namespace: "other", Name: "shared"}
+	issuerObjs := []*certificatev1alpha2.Issuer{
+		{ObjectMeta: metav1.ObjectMeta{Name: "ca"}, Spec: certificatev1alpha2.IssuerSpec{CA: shared}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "trusted"}, Spec: certificatev1alpha2.IssuerSpec{CA: other, TrustedCAs: []certificatev1alpha2.SecretReference{other, shared}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "token"}, Spec: certificatev1alpha2.IssuerSpec{CA: other, Token: &certificatev1alpha2.IssuerToken{SigningKey: &shared}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "unrelated"}, Spec: certificatev1alpha2.IssuerSpec{CA: other, TrustedCAs: []certificatev1alpha2.SecretReference{other}, Token: &certificatev1alpha2.IssuerToken{}}},
+	}
+	for _, issuerObj := range issuerObjs {
+		if err := c.issuerInformer.Informer().GetIndexer().Add(issuerObj); err != nil {
+			t.Fatalf("failed to add Issuer: %s", err)
+		}
+	}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "shared", ResourceVersion: "1"}}
+	updated := secret.DeepCopy()
+	updated.ResourceVersion = "2"
+	want := []string{"ca", "token", "trusted"}
+
+	tests := []struct {
+		name  string
+		event func()
+		want  []string
+	}{
+		{name: "add", event: func() { c.onSecretAdd(secret) }, want: want},
+		{name: "update", event: func() { c.onSecretUpdate(secret, updated) }, want: want},
+		{name: "resync", event: func() { c.onSecretUpdate(updated, updated) }},
+		{name: "delete", event: func() { c.onSecretDelete(updated) }, want: want},
+		{name: "delete tombstone", event: func() {
+			c.onSecretDelete(cache.DeletedFinalStateUnknown{Key: "default/shared", Obj: updated})
+		}, want: want},
+		{name: "unreferenced Secret", event: func() {
+			c.onSecretAdd(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "unreferenced"}})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.event()
+			got := queuedIssuers(c)
+			if len(got) != len(tt.want) {
+				t.Fatalf("queued Issuers = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("queued Issuers = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
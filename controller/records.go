@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"math/big"
+
+	"go.uber.org/zap"
+
+	certificatev1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+)
+
+// serialIndex is the name of the informer index of Cert requests by the serial number of their certificate
+const serialIndex = "serial"
+
+// serialIndexFunc indexes Cert requests by the serial number of their issued certificate
+func serialIndexFunc(obj interface{}) ([]string, error) {
+	certRequest, ok := obj.(*certificatev1alpha2.Certificate)
+	if !ok || certRequest.Status.Certificate == nil {
+		return nil, nil
+	}
+	cert, err := certRequest.GetCertificate()
+	if err != nil {
+		return nil, nil
+	}
+	return []string{cert.SerialNumber.String()}, nil
+}
+
+// IsIssued returns true if a certificate with the given serial has been issued for a Cert request in the `Signed`
+// phase, and validates against its issuer. Revocations are only known by the issuers.
+func (c *CertificateController) IsIssued(serial *big.Int) bool {
+	objs, err := c.certificateInformer.Informer().GetIndexer().ByIndex(serialIndex, serial.String())
+	if err != nil {
+		zap.L().Error("Error looking up Cert request by serial", zap.Error(err), zap.String("serial", serial.String()))
+		return false
+	}
+
+	for _, obj := range objs {
+		certRequest, ok := obj.(*certificatev1alpha2.Certificate)
+		if !ok || certRequest.Status.Phase != certificatev1alpha2.CertificateSigned {
+			continue
+		}
+		cert, err := certRequest.GetCertificate()
+		if err != nil {
+			continue
+		}
+		issuer, _, err := c.issuerFor(certRequest)
+		if err != nil || issuer.ValidateCert(cert, nil) != nil {
+			continue
+		}
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/CodingJzy/trireme-csr/certificates"
+	certificatev1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+	certificatefake "github.com/CodingJzy/trireme-csr/pkg/client/clientset/versioned/fake"
+	certificateinformers "github.com/CodingJzy/trireme-csr/pkg/client/informers/externalversions"
+)
+
+func TestIsIssued(t *testing.T) {
+	issuer := newTestIssuer(t, "test-ca")
+	other := newTestIssuer(t, "other-ca")
+
+	signed := newSignedCertRequest("signed", signTestCert(t, issuer, "signed"))
+	revoked := newSignedCertRequest("revoked", signTestCert(t, issuer, "revoked"))
+	revoked.Status.Phase = certificatev1alpha2.CertificateRevoked
+	foreign := newSignedCertRequest("foreign", signTestCert(t, other, "foreign"))
+
+	informer := certificateinformers.NewSharedInformerFactory(certificatefake.NewSimpleClientset(), 0).Certmanager().V1alpha2().Certificates()
+	if err := informer.Informer().AddIndexers(cache.Indexers{serialIndex: serialIndexFunc}); err != nil {
+		t.Fatalf("failed to add serial index: %s", err)
+	}
+	for _, certRequest := range []*certificatev1alpha2.Certificate{signed, revoked, foreign} {
+		if err := informer.Informer().GetIndexer().Add(certRequest); err != nil {
+			t.Fatalf("failed to add Cert request: %s", err)
+		}
+	}
+	c := newTestController(issuer, nil)
+	c.certificateInformer = informer
+
+	tests := []struct {
+		name        string
+		certRequest *certificatev1alpha2.Certificate
+		want        bool
+	}{
+		{name: "signed Cert request", certRequest: signed, want: true},
+		{name: "revoked Cert request", certRequest: revoked, want: false},
+		{name: "certificate of another CA", certRequest: foreign, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, err := tt.certRequest.GetCertificate()
+			if err != nil {
+				t.Fatalf("failed to parse certificate: %s", err)
+			}
+			if got := c.IsIssued(cert.SerialNumber); got != tt.want {
+				t.Errorf("IsIssued() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if c.IsIssued(big.NewInt(42)) {
+		t.Errorf("IsIssued() = true for an unknown serial")
+	}
+}
+
+func TestOCSPSigners(t *testing.T) {
+	issuer := newTestIssuer(t, "default-ca")
+	named := newTestIssuer(t, "named-ca")
+	certPEM, keyPEM := newTestCAData(t, "named-ca-new")
+	if err := named.Reload(certPEM, keyPEM, ""); err != nil {
+		t.Fatalf("Reload() error = %s", err)
+	}
+	c := newTestController(issuer, map[string]*certificates.TriremeIssuer{"named": named})
+	c.issuers["broken"] = &issuerEntry{err: fmt.Errorf("invalid CA")}
+
+	signers := c.OCSPSigners()
+	// the default issuer, the named issuer and the CA it replaced
+	if len(signers) != 3 {
+		t.Fatalf("OCSPSigners() returned %d signers, want 3", len(signers))
+	}
+	caCerts := map[string]bool{}
+	for _, signer := range signers {
+		caCerts[string(signer.GetCACert())] = true
+	}
+	for _, want := range []string{string(issuer.GetCACert()), string(named.GetCACert()), string(named.RetiredCAs()[0].GetCACert())} {
+		if !caCerts[want] {
+			t.Errorf("OCSPSigners() is missing a signer of a CA")
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/CodingJzy/trireme-csr/certificates"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	certificatev1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+)
+
+// revoke revokes the certificate of a Cert request in the `Signed` phase, persists the revocation, and moves the
+// Cert request to the `Revoked` phase. It returns false if the certificate can not be revoked, because it is not a
+// valid certificate of its issuer, which is reported with an event.
+func (c *CertificateController) revoke(certRequest *certificatev1alpha2.Certificate, reason string) (bool, error) {
+	code, err := certificatev1alpha2.GetRevocationReasonCode(reason)
+	if err != nil {
+		zap.L().Warn("Invalid revocation request", zap.Error(err), zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
+		c.recorder.Eventf(certRequest, corev1.EventTypeWarning, EventReasonInvalidRevocation, "Invalid revocation request: %s", err.Error())
+		return false, nil
+	}
+
+	cert, err := certRequest.GetCertificate()
+	if err != nil {
+		c.recorder.Eventf(certRequest, corev1.EventTypeWarning, EventReasonInvalidRevocation, "Certificate can not be revoked: %s", err.Error())
+		return false, nil
+	}
+
+	issuer, _, err := c.issuerFor(certRequest)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			c.recorder.Eventf(certRequest, corev1.EventTypeWarning, EventReasonInvalidRevocation, "Certificate can not be revoked: Issuer '%s' does not exist", issuerName(certRequest))
+			return false, nil
+		}
+		return false, err
+	}
+
+	revokedAt := metav1.Now()
+	if err := issuer.Revoke(cert, revokedAt.Time, code); err != nil {
+		// Vault revokes over the network, which gets retried
+		if certificates.IsTemporary(err) {
+			return false, fmt.Errorf("temporary error revoking certificate: %s", err)
+		}
+		zap.L().Warn("Certificate can not be revoked", zap.Error(err), zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
+		c.recorder.Eventf(certRequest, corev1.EventTypeWarning, EventReasonInvalidRevocation, "Certificate can not be revoked: %s", err.Error())
+		return false, nil
+	}
+
+	// the revocation must be persisted before the status says so, it gets retried otherwise
+	err = c.revocations.add(issuerName(certRequest), certificates.Revocation{
+		Serial:    cert.SerialNumber,
+		RevokedAt: revokedAt.Time,
+		Reason:    code,
+		NotAfter:  cert.NotAfter,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, c.updateCertRevoked(certRequest, reason, revokedAt)
+}
+
+// persistRevocation persists the revocation of a Cert request in the `Revoked` phase if it has not been persisted,
+// which is the case for the Cert requests that have been revoked before the revocations were persisted.
+func (c *CertificateController) persistRevocation(certRequest *certificatev1alpha2.Certificate) error {
+	cert, err := certRequest.GetCertificate()
+	if err != nil {
+		zap.L().Debug("Revoked Cert request has no valid certificate", zap.Error(err), zap.String("name", certRequest.Name))
+		return nil
+	}
+	if c.revocations.has(issuerName(certRequest), cert.SerialNumber) {
+		return nil
+	}
+
+	issuer, _, err := c.issuerFor(certRequest)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	code, err := certificatev1alpha2.GetRevocationReasonCode(certRequest.Status.RevocationReason)
+	if err != nil {
+		zap.L().Debug("Revoked Cert request has an invalid revocation reason", zap.Error(err), zap.String("name", certRequest.Name))
+		code = 0
+	}
+	revokedAt := certRequest.CreationTimestamp
+	if certRequest.Status.RevocationTime != nil {
+		revokedAt = *certRequest.Status.RevocationTime
+	}
+
+	if err := issuer.Revoke(cert, revokedAt.Time, code); err != nil {
+		if certificates.IsTemporary(err) {
+			return fmt.Errorf("temporary error revoking certificate: %s", err)
+		}
+		zap.L().Warn("Certificate of revoked Cert request can not be revoked", zap.Error(err), zap.String("name", certRequest.Name))
+		return nil
+	}
+	zap.L().Info("Persisting revocation of revoked Cert request", zap.String("name", certRequest.Name), zap.String("serial", cert.SerialNumber.String()))
+	return c.revocations.add(issuerName(certRequest), certificates.Revocation{
+		Serial:    cert.SerialNumber,
+		RevokedAt: revokedAt.Time,
+		Reason:    code,
+		NotAfter:  cert.NotAfter,
+	})
+}
+
+// applyRevocations hands the persisted revocations to the default issuer and to all loaded issuers
+func (c *CertificateController) applyRevocations() {
+	if tracker, ok := c.issuer.(revocationTracker); ok {
+		if err := tracker.AddRevocations(c.revocations.get("")); err != nil {
+			zap.L().Error("Error applying revocations to the default issuer", zap.Error(err))
+		}
+	}
+
+	c.issuersLock.RLock()
+	defer c.issuersLock.RUnlock()
+	for name, entry := range c.issuers {
+		if entry.err != nil {
+			continue
+		}
+		if err := entry.issuer.AddRevocations(c.revocations.get(name)); err != nil {
+			zap.L().Error("Error applying revocations", zap.Error(err), zap.String("issuer", name))
+		}
+	}
+}
+
+func (c *CertificateController) updateCertRevoked(certRequestObj *certificatev1alpha2.Certificate, reason string, revokedAt metav1.Time) error {
+	message := fmt.Sprintf("The certificate has been revoked (%s)", reason)
+	return c.updateStatus(certRequestObj, corev1.EventTypeWarning, EventReasonRevoked, message, func(certRequest *certificatev1alpha2.Certificate) {
+		certRequest.Status.Phase = certificatev1alpha2.CertificateRevoked
+		certRequest.Status.Reason = certificatev1alpha2.StatusReasonRevoked
+		certRequest.Status.Message = message
+		certRequest.Status.RevocationReason = reason
+		certRequest.Status.RevocationTime = &revokedAt
+	})
+}
+
+// crlIssuer is the part of an issuer that serves a CRL
+type crlIssuer interface {
+	GetCRL() ([]byte, error)
+}
+
+// CRLHandler returns an HTTP handler serving the current DER encoded CRL of the default issuer on its root,
+// and the CRLs of the Issuers on their name. The CRL of a signing CA that has been replaced by a reload is
+// served with the `ca` query parameter set to its hex encoded subject key ID, e.g. `/?ca=<key id>`.
+// Use http.StripPrefix to mount it on a path other than the root.
+func (c *CertificateController) CRLHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var issuer crlIssuer = c.issuer
+		if name := strings.Trim(r.URL.Path, "/"); name != "" {
+			c.issuersLock.RLock()
+			entry, ok := c.issuers[name]
+			c.issuersLock.RUnlock()
+			if !ok || entry.err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			issuer = entry.issuer
+		}
+		if keyID := r.URL.Query().Get("ca"); keyID != "" {
+			retired, ok := issuer.(retiredCAsIssuer)
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			issuer = nil
+			for _, ca := range retired.RetiredCAs() {
+				if strings.EqualFold(ca.KeyID(), keyID) {
+					issuer = ca
+					break
+				}
+			}
+			if issuer == nil {
+				http.NotFound(w, r)
+				return
+			}
+		}
+
+		crl, err := issuer.GetCRL()
+		if err != nil {
+			zap.L().Error("Error serving CRL", zap.Error(err), zap.String("path", r.URL.Path))
+			http.Error(w, "CRL is not available", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pkix-crl")
+		w.Write(crl)
+	})
+}
@@ -0,0 +1,316 @@
+package controller
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/CodingJzy/trireme-csr/certificates"
+	certificatev1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+	certificatefake "github.com/CodingJzy/trireme-csr/pkg/client/clientset/versioned/fake"
+)
+
+// newSignedCertRequest returns a Cert request in the `Signed` phase holding the certificate
+func newSignedCertRequest(name string, cert *x509.Certificate) *certificatev1alpha2.Certificate {
+	return &certificatev1alpha2.Certificate{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: certificatev1alpha2.CertificateStatus{
+			Phase:       certificatev1alpha2.CertificateSigned,
+			Certificate: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}),
+		},
+	}
+}
+
+func TestRevokeInvalidReason(t *testing.T) {
+	issuer := newTestIssuer(t, "test-ca")
+	certRequest := newSignedCertRequest("node", signTestCert(t, issuer, "node"))
+	certRequest.Annotations = map[string]string{certificatev1alpha2.RevocationAnnotation: "compromised"}
+	c := newTestController(issuer, nil)
+
+	// the Cert request keeps being processed as a signed one until the annotation gets fixed
+	revoked, err := c.revoke(certRequest, "compromised")
+	if revoked || err != nil {
+		t.Errorf("revoke() = %v, %v for an invalid reason, want false, nil", revoked, err)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	issuer := newTestIssuer(t, "test-ca")
+	cert := signTestCert(t, issuer, "node")
+	certRequest := newSignedCertRequest("node", cert)
+
+	kubeClient := kubefake.NewSimpleClientset()
+	c := newTestController(issuer, nil)
+	c.certificateClient = certificatefake.NewSimpleClientset(certRequest)
+	c.revocations = NewRevocationStore(kubeClient, "default", "revocations")
+
+	revoked, err := c.revoke(certRequest, certificatev1alpha2.RevocationReasonKeyCompromise)
+	if !revoked || err != nil {
+		t.Fatalf("revoke() = %v, %v, want true, nil", revoked, err)
+	}
+
+	if _, reason, revoked := issuer.IsRevoked(cert.SerialNumber); !revoked || reason != 1 {
+		t.Errorf("IsRevoked() = reason %d, %v, want 1, true", reason, revoked)
+	}
+	configMap, err := kubeClient.CoreV1().ConfigMaps("default").Get("revocations", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("revocation has not been persisted: %s", err)
+	}
+	persisted, err := decodeRevocations(configMap.Data[defaultIssuerKey])
+	if err != nil {
+		t.Fatalf("failed to decode persisted revocations: %s", err)
+	}
+	if len(persisted) != 1 || persisted[0].Serial.Cmp(cert.SerialNumber) != 0 || persisted[0].Reason != 1 {
+		t.Errorf("persisted revocations = %+v, want the revoked certificate", persisted)
+	}
+
+	updated, err := c.certificateClient.CertmanagerV1alpha2().Certificates().Get("node", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get Cert request: %s", err)
+	}
+	if updated.Status.Phase != certificatev1alpha2.CertificateRevoked || updated.Status.RevocationReason != certificatev1alpha2.RevocationReasonKeyCompromise {
+		t.Errorf("Cert request status = %s (%s), want %s", updated.Status.Phase, updated.Status.RevocationReason, certificatev1alpha2.CertificateRevoked)
+	}
+}
+
+func TestRevokeTrustedCACertificate(t *testing.T) {
+	oldIssuer := newTestIssuer(t, "old-ca")
+	issuer := newTestIssuer(t, "new-ca")
+	if err := issuer.AddTrustedCA(oldIssuer.GetCACert()); err != nil {
+		t.Fatalf("AddTrustedCA() error = %s", err)
+	}
+	certRequest := newSignedCertRequest("node", signTestCert(t, oldIssuer, "node"))
+
+	kubeClient := kubefake.NewSimpleClientset()
+	c := newTestController(issuer, nil)
+	c.certificateClient = certificatefake.NewSimpleClientset(certRequest)
+	c.revocations = NewRevocationStore(kubeClient, "default", "revocations")
+
+	// the Cert request keeps being processed as a signed one, and must be re-issued to be revoked
+	revoked, err := c.revoke(certRequest, certificatev1alpha2.RevocationReasonKeyCompromise)
+	if revoked || err != nil {
+		t.Errorf("revoke() = %v, %v for a certificate of a trusted CA, want false, nil", revoked, err)
+	}
+	recorder := c.recorder.(*record.FakeRecorder)
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, EventReasonInvalidRevocation) || !strings.Contains(event, "trusted CA") {
+			t.Errorf("recorded event '%s', want %s for the trusted CA", event, EventReasonInvalidRevocation)
+		}
+	default:
+		t.Errorf("no event has been recorded for the rejected revocation")
+	}
+	if len(c.revocations.get("")) != 0 {
+		t.Errorf("revocation of a certificate of a trusted CA has been persisted")
+	}
+}
+
+func TestRevocationStoreAdd(t *testing.T) {
+	kubeClient := kubefake.NewSimpleClientset()
+	store := NewRevocationStore(kubeClient, "default", "revocations")
+
+	now := time.Now().UTC().Truncate(time.Second)
+	revocations := []struct {
+		issuer     string
+		revocation certificates.Revocation
+	}{
+		{issuer: "", revocation: certificates.Revocation{Serial: big.NewInt(1), RevokedAt: now, Reason: 1, NotAfter: now.Add(time.Hour)}},
+		{issuer: "", revocation: certificates.Revocation{Serial: big.NewInt(2), RevokedAt: now, Reason: 4, NotAfter: now.Add(time.Hour)}},
+		// a revocation that is already persisted is not added again
+		{issuer: "", revocation: certificates.Revocation{Serial: big.NewInt(1), RevokedAt: now, Reason: 0, NotAfter: now.Add(time.Hour)}},
+		{issuer: "other", revocation: certificates.Revocation{Serial: big.NewInt(1), RevokedAt: now, Reason: 3, NotAfter: now.Add(time.Hour)}},
+	}
+	for _, r := range revocations {
+		if err := store.add(r.issuer, r.revocation); err != nil {
+			t.Fatalf("add() error = %s", err)
+		}
+	}
+
+	configMap, err := kubeClient.CoreV1().ConfigMaps("default").Get("revocations", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get revocation ConfigMap: %s", err)
+	}
+	if len(configMap.Data) != 2 {
+		t.Errorf("ConfigMap has keys %v, want '%s' and 'issuer.other'", configMap.Data, defaultIssuerKey)
+	}
+
+	defaults, err := decodeRevocations(configMap.Data[defaultIssuerKey])
+	if err != nil {
+		t.Fatalf("failed to decode revocations: %s", err)
+	}
+	reasons := map[int64]int{}
+	for _, revocation := range defaults {
+		reasons[revocation.Serial.Int64()] = revocation.Reason
+		if !revocation.RevokedAt.Equal(now) || !revocation.NotAfter.Equal(now.Add(time.Hour)) {
+			t.Errorf("revocation of serial %s has times %s and %s, want %s and %s", revocation.Serial, revocation.RevokedAt, revocation.NotAfter, now, now.Add(time.Hour))
+		}
+	}
+	if len(reasons) != 2 || reasons[1] != 1 || reasons[2] != 4 {
+		t.Errorf("revocations of the default issuer = %v, want serial 1 with reason 1 and serial 2 with reason 4", reasons)
+	}
+
+	others, err := decodeRevocations(configMap.Data[revocationKey("other")])
+	if err != nil {
+		t.Fatalf("failed to decode revocations: %s", err)
+	}
+	if len(others) != 1 || others[0].Reason != 3 {
+		t.Errorf("revocations of issuer 'other' = %+v, want serial 1 with reason 3", others)
+	}
+}
+
+func TestRevocationStoreDropsExpired(t *testing.T) {
+	kubeClient := kubefake.NewSimpleClientset()
+	store := NewRevocationStore(kubeClient, "default", "revocations")
+
+	now := time.Now()
+	if err := store.add("", certificates.Revocation{Serial: big.NewInt(1), RevokedAt: now, NotAfter: now.Add(-time.Minute)}); err != nil {
+		t.Fatalf("add() error = %s", err)
+	}
+	if err := store.add("", certificates.Revocation{Serial: big.NewInt(2), RevokedAt: now, NotAfter: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("add() error = %s", err)
+	}
+
+	configMap, err := kubeClient.CoreV1().ConfigMaps("default").Get("revocations", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get revocation ConfigMap: %s", err)
+	}
+	revocations, err := decodeRevocations(configMap.Data[defaultIssuerKey])
+	if err != nil {
+		t.Fatalf("failed to decode revocations: %s", err)
+	}
+	if len(revocations) != 1 || revocations[0].Serial.Int64() != 2 {
+		t.Errorf("persisted revocations = %+v, want only serial 2 as serial 1 has expired", revocations)
+	}
+}
+
+func TestRevocationStoreRun(t *testing.T) {
+	kubeClient := kubefake.NewSimpleClientset()
+	writer := NewRevocationStore(kubeClient, "default", "revocations")
+	reader := NewRevocationStore(kubeClient, "default", "revocations")
+
+	// another replica persists the revocation, and the reader hands it to the issuers
+	issuer := newTestIssuer(t, "test-ca")
+	cert := signTestCert(t, issuer, "node")
+	c := newTestController(issuer, nil)
+	c.revocations = reader
+	changed := make(chan struct{}, 10)
+	reader.onChange = func() {
+		c.applyRevocations()
+		changed <- struct{}{}
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go reader.Run(stopCh)
+
+	err := writer.add("", certificates.Revocation{Serial: cert.SerialNumber, RevokedAt: time.Now(), Reason: 1, NotAfter: cert.NotAfter})
+	if err != nil {
+		t.Fatalf("add() error = %s", err)
+	}
+
+	timeout := time.After(10 * time.Second)
+	for !reader.has("", cert.SerialNumber) {
+		select {
+		case <-changed:
+		case <-timeout:
+			t.Fatalf("revocation has not been loaded from the ConfigMap")
+		}
+	}
+	if _, reason, revoked := issuer.IsRevoked(cert.SerialNumber); !revoked || reason != 1 {
+		t.Errorf("IsRevoked() = reason %d, %v after loading the revocation, want 1, true", reason, revoked)
+	}
+	crl, err := issuer.GetCRL()
+	if err != nil {
+		t.Fatalf("GetCRL() error = %s", err)
+	}
+	parsed, err := x509.ParseRevocationList(crl)
+	if err != nil {
+		t.Fatalf("failed to parse CRL: %s", err)
+	}
+	if len(parsed.RevokedCertificateEntries) != 1 || parsed.RevokedCertificateEntries[0].SerialNumber.Cmp(cert.SerialNumber) != 0 {
+		t.Errorf("CRL does not list the loaded revocation")
+	}
+}
+
+func TestCRLHandler(t *testing.T) {
+	defaultIssuer := newTestIssuer(t, "default-ca")
+	namedIssuer := newTestIssuer(t, "named-ca")
+
+	// the signing CA of the named issuer is replaced, and keeps its own CRL
+	retiredCert := signTestCert(t, namedIssuer, "retired")
+	certPEM, keyPEM := newTestCAData(t, "new-named-ca")
+	if err := namedIssuer.Reload(certPEM, keyPEM, ""); err != nil {
+		t.Fatalf("Reload() error = %s", err)
+	}
+	if err := namedIssuer.Revoke(retiredCert, time.Now(), 1); err != nil {
+		t.Fatalf("Revoke() error = %s", err)
+	}
+	retiredCAs := namedIssuer.RetiredCAs()
+	if len(retiredCAs) != 1 {
+		t.Fatalf("RetiredCAs() returned %d CAs, want 1", len(retiredCAs))
+	}
+
+	for _, issuer := range []*certificates.TriremeIssuer{defaultIssuer, namedIssuer} {
+		if err := issuer.UpdateCRL(); err != nil {
+			t.Fatalf("UpdateCRL() error = %s", err)
+		}
+	}
+	c := newTestController(defaultIssuer, map[string]*certificates.TriremeIssuer{"named": namedIssuer})
+	server := httptest.NewServer(http.StripPrefix("/crl", c.CRLHandler()))
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		issuer []byte
+	}{
+		{name: "default issuer", path: "/crl", status: http.StatusOK, issuer: defaultIssuer.GetCACert()},
+		{name: "named issuer", path: "/crl/named", status: http.StatusOK, issuer: namedIssuer.GetCACert()},
+		{name: "replaced CA", path: "/crl/named?ca=" + retiredCAs[0].KeyID(), status: http.StatusOK, issuer: retiredCAs[0].GetCACert()},
+		{name: "unknown CA", path: "/crl/named?ca=00", status: http.StatusNotFound},
+		{name: "unknown issuer", path: "/crl/unknown", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatalf("failed to get CRL: %s", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("failed to read CRL: %s", err)
+			}
+			crl, err := x509.ParseRevocationList(body)
+			if err != nil {
+				t.Fatalf("failed to parse CRL: %s", err)
+			}
+			block, _ := pem.Decode(tt.issuer)
+			ca, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				t.Fatalf("failed to parse CA certificate: %s", err)
+			}
+			if err := crl.CheckSignatureFrom(ca); err != nil {
+				t.Errorf("CRL has not been signed by the CA of the %s: %s", tt.name, err)
+			}
+		})
+	}
+}
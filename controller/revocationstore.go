@@ -0,0 +1,259 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/CodingJzy/trireme-csr/certificates"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+)
+
+// defaultIssuerKey is the key of the revocations of the default issuer in the ConfigMap. The keys of the Issuers
+// are prefixed, so that they never clash with it.
+const defaultIssuerKey = "default"
+
+// revocationTracker is implemented by the issuers that keep track of the revocations themselves
+type revocationTracker interface {
+	AddRevocations(revocations []certificates.Revocation) error
+}
+
+// storedRevocation is a revocation as it is persisted in the ConfigMap
+type storedRevocation struct {
+	Serial    string    `json:"serial"`
+	RevokedAt time.Time `json:"revokedAt"`
+	Reason    int       `json:"reason"`
+	NotAfter  time.Time `json:"notAfter"`
+}
+
+// RevocationStore persists the revocations of all issuers in a ConfigMap, so that they are kept when the Cert
+// requests get deleted. Every replica watches the ConfigMap and hands the revocations to its issuers, which
+// only keep them in memory. Revocations are dropped once their certificate has expired.
+type RevocationStore struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+	name       string
+
+	lock        sync.RWMutex
+	revocations map[string][]certificates.Revocation
+
+	// changed is signalled whenever the ConfigMap changed, and onChange is called once it has been loaded
+	changed  chan struct{}
+	onChange func()
+}
+
+// NewRevocationStore creates a RevocationStore for the ConfigMap `namespace/name`, which gets created on the first revocation
+func NewRevocationStore(kubeClient kubernetes.Interface, namespace, name string) *RevocationStore {
+	return &RevocationStore{
+		kubeClient:  kubeClient,
+		namespace:   namespace,
+		name:        name,
+		revocations: map[string][]certificates.Revocation{},
+		changed:     make(chan struct{}, 1),
+	}
+}
+
+// Run watches the ConfigMap until stopCh closes. The revocations are loaded outside of the informer handlers,
+// as handing them to the issuers regenerates their CRLs.
+func (s *RevocationStore) Run(stopCh <-chan struct{}) {
+	// the typed client is used instead of its REST client, which fake clientsets do not provide
+	configMaps := s.kubeClient.CoreV1().ConfigMaps(s.namespace)
+	fieldSelector := fields.OneTermEqualSelector("metadata.name", s.name).String()
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = fieldSelector
+			return configMaps.List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = fieldSelector
+			return configMaps.Watch(options)
+		},
+	}
+
+	store, informer := cache.NewInformer(listWatch, &corev1.ConfigMap{}, ResyncPeriod, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			s.notify()
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			s.notify()
+		},
+		DeleteFunc: func(obj interface{}) {
+			zap.L().Warn("Revocation ConfigMap deleted, keeping the known revocations", zap.String("configmap", s.namespace+"/"+s.name))
+		},
+	})
+	go informer.Run(stopCh)
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-s.changed:
+		}
+
+		for _, obj := range store.List() {
+			configMap, ok := obj.(*corev1.ConfigMap)
+			if !ok {
+				continue
+			}
+			s.load(configMap)
+		}
+		if s.onChange != nil {
+			s.onChange()
+		}
+	}
+}
+
+// notify signals that the ConfigMap changed without blocking the informer
+func (s *RevocationStore) notify() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// load replaces the known revocations with the ones of the ConfigMap
+func (s *RevocationStore) load(configMap *corev1.ConfigMap) {
+	revocations := map[string][]certificates.Revocation{}
+	for key, data := range configMap.Data {
+		decoded, err := decodeRevocations(data)
+		if err != nil {
+			zap.L().Error("Error decoding revocations", zap.Error(err), zap.String("configmap", s.namespace+"/"+s.name), zap.String("key", key))
+			continue
+		}
+		revocations[key] = decoded
+	}
+
+	s.lock.Lock()
+	s.revocations = revocations
+	s.lock.Unlock()
+}
+
+// get returns the known revocations of the issuer with the given name, which is empty for the default issuer
+func (s *RevocationStore) get(issuer string) []certificates.Revocation {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.revocations[revocationKey(issuer)]
+}
+
+// has returns true if the revocation of the serial is known for the issuer with the given name
+func (s *RevocationStore) has(issuer string, serial *big.Int) bool {
+	for _, revocation := range s.get(issuer) {
+		if revocation.Serial.Cmp(serial) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// add persists the revocation for the issuer with the given name, and drops the revocations of expired certificates.
+// Adding a revocation that is already persisted has no effect.
+func (s *RevocationStore) add(issuer string, revocation certificates.Revocation) error {
+	key := revocationKey(issuer)
+	configMaps := s.kubeClient.CoreV1().ConfigMaps(s.namespace)
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := configMaps.Get(s.name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			configMap = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: s.namespace, Name: s.name}}
+			configMap, err = configMaps.Create(configMap)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get revocation ConfigMap %s/%s: %s", s.namespace, s.name, err)
+		}
+
+		revocations, err := decodeRevocations(configMap.Data[key])
+		if err != nil {
+			return fmt.Errorf("failed to decode revocations of '%s': %s", key, err)
+		}
+
+		now := time.Now()
+		kept := []certificates.Revocation{revocation}
+		for _, existing := range revocations {
+			if existing.Serial.Cmp(revocation.Serial) == 0 {
+				return nil
+			}
+			if existing.NotAfter.Before(now) {
+				continue
+			}
+			kept = append(kept, existing)
+		}
+
+		data, err := encodeRevocations(kept)
+		if err != nil {
+			return err
+		}
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[key] = data
+		_, err = configMaps.Update(configMap)
+		return err
+	})
+}
+
+// revocationKey returns the key of the revocations of the issuer with the given name in the ConfigMap
+func revocationKey(issuer string) string {
+	if issuer == "" {
+		return defaultIssuerKey
+	}
+	return "issuer." + issuer
+}
+
+// decodeRevocations decodes the JSON list of revocations of an issuer
+func decodeRevocations(data string) ([]certificates.Revocation, error) {
+	if data == "" {
+		return nil, nil
+	}
+
+	var stored []storedRevocation
+	if err := json.Unmarshal([]byte(data), &stored); err != nil {
+		return nil, err
+	}
+
+	revocations := make([]certificates.Revocation, 0, len(stored))
+	for _, entry := range stored {
+		serial, ok := new(big.Int).SetString(entry.Serial, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid serial '%s'", entry.Serial)
+		}
+		revocations = append(revocations, certificates.Revocation{
+			Serial:    serial,
+			RevokedAt: entry.RevokedAt,
+			Reason:    entry.Reason,
+			NotAfter:  entry.NotAfter,
+		})
+	}
+	return revocations, nil
+}
+
+// encodeRevocations encodes the revocations of an issuer as a JSON list
+func encodeRevocations(revocations []certificates.Revocation) (string, error) {
+	stored := make([]storedRevocation, 0, len(revocations))
+	for _, revocation := range revocations {
+		stored = append(stored, storedRevocation{
+			Serial:    revocation.Serial.String(),
+			RevokedAt: revocation.RevokedAt.UTC(),
+			Reason:    revocation.Reason,
+			NotAfter:  revocation.NotAfter.UTC(),
+		})
+	}
+
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode revocations: %s", err)
+	}
+	return string(data), nil
+}
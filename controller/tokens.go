@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"crypto/x509"
+	"time"
+
+	"go.uber.org/zap"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/CodingJzy/trireme-csr/certificates"
+	"github.com/CodingJzy/trireme-csr/metrics"
+	certificatev1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+)
+
+// tokenRefreshFraction is the fraction of the lifetime of a token after which it gets refreshed
+const tokenRefreshFraction = 2.0 / 3
+
+// refreshToken issues a new token for the certificate of a Cert request in the `Signed` phase if its token is
+// about to expire, or if a refresh has been requested with the `TokenRefreshAnnotation`. The certificate stays
+// untouched. Otherwise, the Cert request is queued again for when its token has to be refreshed.
+func (c *CertificateController) refreshToken(certRequest *certificatev1alpha2.Certificate, issuer certificates.Issuer, cert *x509.Certificate) error {
+	// certificates without a token never get one
+	if !certificates.SupportsToken(cert) {
+		return nil
+	}
+
+	refreshAt := tokenRefreshTime(certRequest, cert)
+	requested := certRequest.TokenRefreshRequested()
+	if !requested && (refreshAt.IsZero() || time.Now().Before(refreshAt)) {
+		if !refreshAt.IsZero() {
+			c.enqueueAfter(certRequest, time.Until(refreshAt))
+		}
+		return nil
+	}
+
+	token, tokenNotAfter, err := issuer.IssueToken(cert, tokenAttributes(certRequest))
+	if err != nil {
+		zap.L().Error("Error refreshing compact PKI token", zap.Error(err), zap.String("name", certRequest.Name), zap.String("resource_version", certRequest.ResourceVersion))
+		metrics.ObserveIssuerError(metrics.IssuerOperationIssueToken)
+		return err
+	}
+	zap.L().Info("Token refreshed", zap.String("name", certRequest.Name), zap.Bool("requested", requested), zap.Time("notAfter", tokenNotAfter))
+
+	message := "Token has been refreshed for the issued certificate"
+	return c.updateStatus(certRequest, corev1.EventTypeNormal, EventReasonTokenRefreshed, message, func(certRequest *certificatev1alpha2.Certificate) {
+		setToken(certRequest, token, tokenNotAfter)
+	})
+}
+
+// tokenRefreshTime returns the time at which the token of the Cert request should be refreshed, or the zero
+// time if the token expires with the certificate, which must be renewed instead.
+func tokenRefreshTime(certRequest *certificatev1alpha2.Certificate, cert *x509.Certificate) time.Time {
+	// tokens issued before their lifetime has been recorded expire with the certificate
+	issuedAt, notAfter := cert.NotBefore, cert.NotAfter
+	if certRequest.Status.TokenIssuedAt != nil && certRequest.Status.TokenNotAfter != nil {
+		issuedAt, notAfter = certRequest.Status.TokenIssuedAt.Time, certRequest.Status.TokenNotAfter.Time
+	}
+	if !notAfter.Before(cert.NotAfter) {
+		return time.Time{}
+	}
+	return issuedAt.Add(time.Duration(float64(notAfter.Sub(issuedAt)) * tokenRefreshFraction))
+}
+
+// tokenAttributes returns the attributes of the Cert request that token tags can be copied from
+func tokenAttributes(certRequest *certificatev1alpha2.Certificate) *certificates.TokenAttributes {
+	return &certificates.TokenAttributes{
+		Labels:      certRequest.Labels,
+		Annotations: certRequest.Annotations,
+	}
+}
+
+// setToken stores the token and its lifetime in the status, together with the refresh request it answers.
+// A nil token clears the token of a certificate that has been issued without one.
+func setToken(certRequest *certificatev1alpha2.Certificate, token []byte, notAfter time.Time) {
+	if token == nil {
+		certRequest.Status.Token = nil
+		certRequest.Status.TokenIssuedAt = nil
+		certRequest.Status.TokenNotAfter = nil
+		certRequest.Status.TokenRefreshRequest = ""
+		return
+	}
+	issuedAt := metav1.Now()
+	tokenNotAfter := metav1.NewTime(notAfter)
+	certRequest.Status.Token = token
+	certRequest.Status.TokenIssuedAt = &issuedAt
+	certRequest.Status.TokenNotAfter = &tokenNotAfter
+	certRequest.Status.TokenRefreshRequest = certRequest.Annotations[certificatev1alpha2.TokenRefreshAnnotation]
+}
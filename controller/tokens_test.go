@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	certificatev1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+)
+
+func TestTokenRefreshTime(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	cert := &x509.Certificate{
+		NotBefore: now.Add(-time.Hour),
+		NotAfter:  now.Add(23 * time.Hour),
+	}
+	timePtr := func(t time.Time) *metav1.Time {
+		mt := metav1.NewTime(t)
+		return &mt
+	}
+
+	tests := []struct {
+		name          string
+		tokenIssuedAt *metav1.Time
+		tokenNotAfter *metav1.Time
+		want          time.Time
+	}{
+		{
+			name: "token without recorded lifetime expires with the certificate",
+			want: time.Time{},
+		},
+		{
+			name:          "token expiring with the certificate",
+			tokenIssuedAt: timePtr(cert.NotBefore),
+			tokenNotAfter: timePtr(cert.NotAfter),
+			want:          time.Time{},
+		},
+		{
+			name:          "token outliving the certificate",
+			tokenIssuedAt: timePtr(cert.NotBefore),
+			tokenNotAfter: timePtr(cert.NotAfter.Add(time.Hour)),
+			want:          time.Time{},
+		},
+		{
+			name:          "shorter lived token",
+			tokenIssuedAt: timePtr(now),
+			tokenNotAfter: timePtr(now.Add(3 * time.Hour)),
+			want:          now.Add(2 * time.Hour),
+		},
+		{
+			name:          "token without issuing time",
+			tokenNotAfter: timePtr(now.Add(3 * time.Hour)),
+			want:          time.Time{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certRequest := &certificatev1alpha2.Certificate{
+				Status: certificatev1alpha2.CertificateStatus{
+					TokenIssuedAt: tt.tokenIssuedAt,
+					TokenNotAfter: tt.tokenNotAfter,
+				},
+			}
+			if got := tokenRefreshTime(certRequest, cert); !got.Equal(tt.want) {
+				t.Errorf("tokenRefreshTime() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetToken(t *testing.T) {
+	request := time.Now().UTC().Format(time.RFC3339Nano)
+	certRequest := &certificatev1alpha2.Certificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				certificatev1alpha2.TokenRefreshAnnotation: request,
+			},
+		},
+	}
+	if !certRequest.TokenRefreshRequested() {
+		t.Fatalf("TokenRefreshRequested() = false for an annotated certificate without a token")
+	}
+
+	notAfter := time.Now().Add(time.Hour).Truncate(time.Second)
+	before := time.Now().Truncate(time.Second)
+	setToken(certRequest, []byte("token"), notAfter)
+	status := certRequest.Status
+	if string(status.Token) != "token" {
+		t.Errorf("Token = %q, want %q", status.Token, "token")
+	}
+	if status.TokenIssuedAt == nil || status.TokenIssuedAt.Time.Before(before) {
+		t.Errorf("TokenIssuedAt = %v, want the time the token has been stored", status.TokenIssuedAt)
+	}
+	if status.TokenNotAfter == nil || !status.TokenNotAfter.Time.Equal(notAfter) {
+		t.Errorf("TokenNotAfter = %v, want %s", status.TokenNotAfter, notAfter)
+	}
+	if status.TokenRefreshRequest != request {
+		t.Errorf("TokenRefreshRequest = %q, want %q", status.TokenRefreshRequest, request)
+	}
+	if certRequest.TokenRefreshRequested() {
+		t.Errorf("TokenRefreshRequested() = true after the requested token has been stored")
+	}
+
+	// a new request is pending until a token has been stored for it
+	certRequest.Annotations[certificatev1alpha2.TokenRefreshAnnotation] = time.Now().Add(time.Second).UTC().Format(time.RFC3339Nano)
+	if !certRequest.TokenRefreshRequested() {
+		t.Errorf("TokenRefreshRequested() = false for a new refresh request")
+	}
+
+	setToken(certRequest, nil, time.Time{})
+	status = certRequest.Status
+	if status.Token != nil || status.TokenIssuedAt != nil || status.TokenNotAfter != nil || status.TokenRefreshRequest != "" {
+		t.Errorf("setToken() with a nil token did not clear the token, status = %+v", status)
+	}
+}
+
+func TestTokenAttributes(t *testing.T) {
+	certRequest := &certificatev1alpha2.Certificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      map[string]string{"app": "web"},
+			Annotations: map[string]string{"team": "payments"},
+		},
+	}
+	attributes := tokenAttributes(certRequest)
+	if attributes.Labels["app"] != "web" || attributes.Annotations["team"] != "payments" {
+		t.Errorf("tokenAttributes() = %+v, want the labels and annotations of the Cert request", attributes)
+	}
+}
@@ -0,0 +1,138 @@
+package election
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/CodingJzy/trireme-csr/metrics"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// LeaderElector runs a function only while this replica holds the leader Lease.
+type LeaderElector struct {
+	identity string
+	elector  *leaderelection.LeaderElector
+
+	sync.RWMutex
+	leader  string
+	leading bool
+}
+
+// NewLeaderElector creates a LeaderElector competing for the Lease `name` in `namespace`.
+// `run` is called once this replica becomes the leader, and must return when its stop channel closes.
+func NewLeaderElector(kubeClient kubernetes.Interface, namespace, name string, leaseDuration, renewDeadline, retryPeriod time.Duration, run func(stopCh <-chan struct{})) (*LeaderElector, error) {
+	identity, err := newIdentity()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &LeaderElector{
+		identity: identity,
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Client: kubeClient.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: identity,
+		},
+	}
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: leaseDuration,
+		RenewDeadline: renewDeadline,
+		RetryPeriod:   retryPeriod,
+		// give up the Lease when we are shutting down, so that a follower can take over immediately
+		ReleaseOnCancel: true,
+		Name:            name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				zap.L().Info("Started leading", zap.String("identity", identity), zap.String("lease", namespace+"/"+name))
+				e.setLeading(true)
+				run(ctx.Done())
+			},
+			OnStoppedLeading: func() {
+				zap.L().Info("Stopped leading", zap.String("identity", identity), zap.String("lease", namespace+"/"+name))
+				e.setLeading(false)
+			},
+			OnNewLeader: func(leader string) {
+				zap.L().Info("New leader elected", zap.String("leader", leader), zap.String("identity", identity), zap.String("lease", namespace+"/"+name))
+				e.setLeader(leader)
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid leader election configuration: %s", err.Error())
+	}
+	e.elector = elector
+
+	return e, nil
+}
+
+// Run competes for the Lease until stopCh is closed. It returns once leadership is lost or stopCh is closed.
+func (e *LeaderElector) Run(stopCh <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+
+	zap.L().Info("Starting leader election", zap.String("identity", e.identity))
+	e.elector.Run(ctx)
+}
+
+// GetIdentity returns the identity this replica uses in the Lease.
+func (e *LeaderElector) GetIdentity() string {
+	return e.identity
+}
+
+// GetLeader returns the identity of the current leader, or an empty string if it is not known yet.
+func (e *LeaderElector) GetLeader() string {
+	e.RLock()
+	defer e.RUnlock()
+	return e.leader
+}
+
+// IsLeader returns true if this replica is currently the leader.
+func (e *LeaderElector) IsLeader() bool {
+	e.RLock()
+	defer e.RUnlock()
+	return e.leading
+}
+
+func (e *LeaderElector) setLeader(leader string) {
+	e.Lock()
+	defer e.Unlock()
+	e.leader = leader
+}
+
+func (e *LeaderElector) setLeading(leading bool) {
+	e.Lock()
+	defer e.Unlock()
+	e.leading = leading
+	metrics.SetLeader(leading)
+}
+
+// newIdentity generates a unique identity for this replica based on the hostname.
+func newIdentity() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("unable to get hostname for leader election identity: %s", err.Error())
+	}
+	return hostname + "_" + string(uuid.NewUUID()), nil
+}
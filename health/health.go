@@ -0,0 +1,66 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"go.uber.org/zap"
+
+	"github.com/CodingJzy/trireme-csr/version"
+)
+
+// Check returns an error if the checked component is not healthy.
+type Check func() error
+
+// Register adds the `/healthz`, `/readyz` and `/version` handlers to mux.
+// `/healthz` fails if any of the liveness checks fails, `/readyz` fails if any of the readiness checks fails.
+func Register(mux *http.ServeMux, liveness, readiness map[string]Check) {
+	mux.HandleFunc("/healthz", checksHandler("liveness", liveness))
+	mux.HandleFunc("/readyz", checksHandler("readiness", readiness))
+	mux.HandleFunc("/version", versionHandler)
+}
+
+func checksHandler(kind string, checks map[string]Check) http.HandlerFunc {
+	// run the checks in a stable order, so that the output is comparable
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		failed := false
+		output := ""
+		for _, name := range names {
+			if err := checks[name](); err != nil {
+				zap.L().Debug("Health check failed", zap.String("kind", kind), zap.String("check", name), zap.Error(err))
+				output += fmt.Sprintf("[-] %s failed: %s\n", name, err.Error())
+				failed = true
+				continue
+			}
+			output += fmt.Sprintf("[+] %s ok\n", name)
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if failed {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "%s%s check failed\n", output, kind)
+			return
+		}
+		fmt.Fprintf(w, "%s%s check passed\n", output, kind)
+	}
+}
+
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Version  string `json:"version"`
+		Revision string `json:"revision"`
+	}{
+		Version:  version.VERSION,
+		Revision: version.REVISION,
+	})
+}
@@ -1,13 +1,22 @@
 package main
 
 import (
+	"context"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/CodingJzy/trireme-csr/certificates"
 	"github.com/CodingJzy/trireme-csr/config"
+	"github.com/CodingJzy/trireme-csr/election"
+	"github.com/CodingJzy/trireme-csr/health"
+	"github.com/CodingJzy/trireme-csr/metrics"
+	"github.com/CodingJzy/trireme-csr/ocspresponder"
+	"github.com/CodingJzy/trireme-csr/policy"
+	"github.com/CodingJzy/trireme-csr/remotesigner"
 
 	certificatecontroller "github.com/CodingJzy/trireme-csr/controller"
 	certificateclient "github.com/CodingJzy/trireme-csr/pkg/client/clientset/versioned"
@@ -15,6 +24,9 @@ import (
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -30,11 +42,6 @@ func main() {
 	// creating OS signal handlers for shutdown handling
 	sigsCh := createSignalChannel()
 
-	issuer, err := certificates.NewTriremeIssuerFromPath(config.SigningCACert, config.SigningCACertKey, config.SigningCACertKeyPass)
-	if err != nil {
-		panic("Error creating Certificate Issuer " + err.Error())
-	}
-
 	// Get the Kube API interface for Certificates up
 	kubeconfig, err := buildConfig(config.KubeconfigPath)
 	if err != nil {
@@ -47,24 +54,360 @@ func main() {
 		zap.L().Fatal("Error creating CertificateClient", zap.Error(err))
 	}
 
+	// create the Kubernetes client used for events and leader election
+	kubeClient, err := kubernetes.NewForConfig(kubeconfig)
+	if err != nil {
+		zap.L().Fatal("Error creating Kubernetes client", zap.Error(err))
+	}
+
+	// load the issuance policy, and reload it whenever it changes
+	policyEngine := policy.NewEngine()
+	if config.PolicyFile != "" {
+		if err := policyEngine.LoadFile(config.PolicyFile); err != nil {
+			zap.L().Fatal("Error loading issuance policy", zap.Error(err))
+		}
+		go policyEngine.WatchFile(config.PolicyFile, config.PolicyReloadInterval, sigsCh)
+	}
+	if config.PolicyConfigMap != "" {
+		parts := strings.SplitN(config.PolicyConfigMap, "/", 2)
+		configMap, err := kubeClient.CoreV1().ConfigMaps(parts[0]).Get(parts[1], metav1.GetOptions{})
+		if err != nil {
+			zap.L().Fatal("Error getting issuance policy ConfigMap", zap.Error(err))
+		}
+		if err := policyEngine.Load([]byte(configMap.Data[config.PolicyConfigMapKey])); err != nil {
+			zap.L().Fatal("Error loading issuance policy", zap.Error(err))
+		}
+		go policyEngine.WatchConfigMap(kubeClient, parts[0], parts[1], config.PolicyConfigMapKey, sigsCh)
+	}
+
+	// the default issuer signs with the signing CA, or forwards the CSRs to Vault
+	var issuer certificates.Issuer
+	if config.VaultAddress != "" {
+		issuer = newVaultIssuer(config, policyEngine, sigsCh)
+	} else {
+		issuer = newTriremeIssuer(config, kubeClient, policyEngine, sigsCh)
+	}
+
+	// the revocations of all issuers are persisted, so that they do not depend on the Certificates being kept
+	revocationConfigMap := strings.SplitN(config.RevocationConfigMap, "/", 2)
+	revocationStore := certificatecontroller.NewRevocationStore(kubeClient, revocationConfigMap[0], revocationConfigMap[1])
+
 	// create CertificateInformer Factory for a shared informer
-	certInformerFactory := certificateinformers.NewSharedInformerFactory(certClient, time.Second*30)
+	certInformerFactory := certificateinformers.NewSharedInformerFactory(certClient, certificatecontroller.ResyncPeriod)
+	// the Secrets of the Issuers are read from a shared informer, so that reloading them causes no API requests
+	kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubeClient, certificatecontroller.ResyncPeriod)
 
 	// create our controller
-	certController := certificatecontroller.NewCertificateController(certClient, certInformerFactory, issuer)
+	certController := certificatecontroller.NewCertificateController(
+		certClient,
+		kubeClient,
+		certInformerFactory,
+		kubeInformerFactory,
+		issuer,
+		certificatecontroller.ApprovalPolicy{
+			Required:            config.ApprovalRequired,
+			AutoApproveProfiles: config.AutoApproveProfiles,
+			ApproverKey:         config.ApproverKey,
+		},
+		certificatecontroller.IssuerConfig{
+			// the issuers of Issuer resources share the controller wide settings of the default issuer
+			Configure: func(namedIssuer *certificates.TriremeIssuer) error {
+				namedIssuer.SetOCSPResponderURL(config.OCSPResponderURL)
+				namedIssuer.SetPolicy(policyEngine)
+				return namedIssuer.SetMinRSAKeySize(config.MinRSAKeySize)
+			},
+			DefaultDuration:   config.CertificateDuration,
+			MaxDuration:       config.MaxCertificateDuration,
+			CRLUpdateInterval: config.CRLUpdateInterval,
+		},
+		revocationStore,
+	)
 
 	// start the shared informer (internally, it calls Run(sigsCh) on the shared informer)
+	// it runs on every replica together with the revocation store and the issuers, so that all of them serve the
+	// same CRLs and OCSP responses
 	certInformerFactory.Start(sigsCh)
+	kubeInformerFactory.Start(sigsCh)
+	go revocationStore.Run(sigsCh)
+	go certController.RunIssuers(sigsCh)
 
-	// start and block
-	err = certController.Run(sigsCh)
-	if err != nil {
-		zap.L().Fatal("Error running CertificateController", zap.Error(err))
+	// runController starts the controller, and blocks until stopCh closes
+	runController := func(stopCh <-chan struct{}) {
+		// start and block
+		err := certController.Run(config.Workers, stopCh)
+		if err != nil {
+			zap.L().Fatal("Error running CertificateController", zap.Error(err))
+		}
+	}
+
+	// the elector is only set if leader election is enabled
+	var elector *election.LeaderElector
+	if config.LeaderElection {
+		elector, err = election.NewLeaderElector(
+			kubeClient,
+			config.LeaderElectionNamespace,
+			config.LeaderElectionLeaseName,
+			config.LeaderElectionLeaseDuration,
+			config.LeaderElectionRenewDeadline,
+			config.LeaderElectionRetryPeriod,
+			runController,
+		)
+		if err != nil {
+			zap.L().Fatal("Error creating leader elector", zap.Error(err))
+		}
+	}
+
+	// muxes holds the HTTP handlers per listen address, so that endpoints can share a server
+	muxes := map[string]*http.ServeMux{}
+	muxFor := func(address string) *http.ServeMux {
+		if _, ok := muxes[address]; !ok {
+			muxes[address] = http.NewServeMux()
+		}
+		return muxes[address]
+	}
+
+	// expose our metrics
+	if config.MetricsAddress != "" {
+		metrics.RegisterInformerSynced("certificates", certInformerFactory.Certmanager().V1alpha2().Certificates().Informer().HasSynced)
+		metrics.RegisterInformerSynced("issuers", certInformerFactory.Certmanager().V1alpha2().Issuers().Informer().HasSynced)
+		metrics.RegisterInformerSynced("secrets", kubeInformerFactory.Core().V1().Secrets().Informer().HasSynced)
+		metrics.RegisterCAExpiry(issuer.GetCACert)
+
+		muxFor(config.MetricsAddress).Handle("/metrics", metrics.Handler())
+	}
+
+	// expose our health checks
+	if config.HealthAddress != "" {
+		health.Register(
+			muxFor(config.HealthAddress),
+			map[string]health.Check{
+				"controller": certController.Healthy,
+			},
+			map[string]health.Check{
+				"controller": func() error {
+					// followers do not run the controller, but are ready to take over
+					if elector != nil && !elector.IsLeader() {
+						return nil
+					}
+					return certController.Ready()
+				},
+			},
+		)
+	}
+
+	// serve the CRL of the default issuer, and the CRLs of the Issuers under their name
+	if config.CRLAddress != "" {
+		crlHandler := http.StripPrefix("/crl", certController.CRLHandler())
+		mux := muxFor(config.CRLAddress)
+		mux.Handle("/crl", crlHandler)
+		mux.Handle("/crl/", crlHandler)
+	}
+
+	// serve the OCSP responder
+	if config.OCSPAddress != "" {
+		responder := ocspresponder.NewResponder(certController.OCSPSigners, certController, config.OCSPResponseValidity)
+		mux := muxFor(config.OCSPAddress)
+		mux.Handle("/ocsp", responder)
+		mux.Handle("/ocsp/", http.StripPrefix("/ocsp", responder))
+	}
+
+	for address, mux := range muxes {
+		startHTTPServer(address, mux, "", "", sigsCh)
+	}
+
+	// record the authenticated approvers of CertificateApprovals on every replica, as the API server calls any of them
+	if config.ApproverKey != nil && config.ApprovalWebhookAddress != "" {
+		startHTTPServer(config.ApprovalWebhookAddress, certificatecontroller.ApprovalWebhook(config.ApproverKey), config.ApprovalWebhookTLSCert, config.ApprovalWebhookTLSKey, sigsCh)
+	}
+
+	if elector == nil {
+		runController(sigsCh)
+		zap.L().Info("Trireme-CSR exiting")
+		return
+	}
+
+	// blocks until we are shutting down or leadership has been lost
+	elector.Run(sigsCh)
+
+	select {
+	case <-sigsCh:
+	default:
+		// we can not be sure that everything stopped processing, so we exit and let another replica take over
+		zap.L().Fatal("Leadership lost", zap.String("identity", elector.GetIdentity()))
 	}
 
 	zap.L().Info("Trireme-CSR exiting")
 }
 
+// newTriremeIssuer creates the default issuer from the signing CA, and starts regenerating its CRL and
+// reloading its signing CA until stopCh closes.
+func newTriremeIssuer(config *config.Configuration, kubeClient kubernetes.Interface, policyEngine *policy.Engine, stopCh <-chan struct{}) *certificates.TriremeIssuer {
+	// load the signing CA from its Secret, from its files, or from its certificate file with a remote signer
+	var issuer *certificates.TriremeIssuer
+	var err error
+	if config.SigningCASecret != "" {
+		parts := strings.SplitN(config.SigningCASecret, "/", 2)
+		secret, err := kubeClient.CoreV1().Secrets(parts[0]).Get(parts[1], metav1.GetOptions{})
+		if err != nil {
+			panic("Error getting signing CA Secret " + err.Error())
+		}
+		caCertPEM, caKeyPEM, keyPass, err := certificates.ReadCASecret(secret, config.SigningCASecretPassKey)
+		if err != nil {
+			panic("Error reading signing CA Secret " + err.Error())
+		}
+		issuer, err = certificates.NewTriremeIssuerFromData(caCertPEM, caKeyPEM, keyPass)
+		if err != nil {
+			panic("Error creating Certificate Issuer from Secret " + config.SigningCASecret + ": " + err.Error())
+		}
+	} else if config.SigningRemoteSigner != "" {
+		tlsConfig, err := remotesigner.ClientTLSConfig(config.SigningRemoteSignerTLSCert, config.SigningRemoteSignerTLSKey, config.SigningRemoteSignerTLSCA)
+		if err != nil {
+			panic("Error loading remote signer TLS configuration " + err.Error())
+		}
+		signer, err := remotesigner.NewClient(config.SigningRemoteSigner, tlsConfig, config.SigningRemoteSignerTimeout)
+		if err != nil {
+			panic("Error connecting to remote signer " + err.Error())
+		}
+		issuer, err = certificates.NewTriremeIssuerWithSigner(config.SigningCACertData, signer)
+		if err != nil {
+			panic("Error creating Certificate Issuer with remote signer " + err.Error())
+		}
+	} else {
+		issuer, err = certificates.NewTriremeIssuerFromPath(config.SigningCACert, config.SigningCACertKey, config.SigningCACertKeyPass)
+		if err != nil {
+			panic("Error creating Certificate Issuer " + err.Error())
+		}
+	}
+
+	// keep trusting the retiring CAs of a rotation, until their certificates have been re-issued
+	for _, trustedCA := range config.TrustedCAs {
+		trustedPEM, err := certificates.LoadCertPEM(trustedCA)
+		if err != nil {
+			panic("Error loading trusted CA " + err.Error())
+		}
+		if err := issuer.AddTrustedCA(trustedPEM); err != nil {
+			panic("Error loading trusted CA " + trustedCA + ": " + err.Error())
+		}
+	}
+
+	if config.SigningSignatureAlgorithm != "" {
+		if err := issuer.SetSignatureAlgorithm(config.SigningSignatureAlgorithm); err != nil {
+			panic("Error configuring Certificate Issuer " + err.Error())
+		}
+	}
+
+	// sign tokens with a separate key if configured, which is required if the CA key can not sign tokens
+	if config.TokenSigningKey != "" {
+		tokenKey, err := certificates.ReadPrivateKeyPEM(config.TokenSigningKey, config.TokenSigningKeyPass)
+		if err != nil {
+			panic("Error loading token signing key " + err.Error())
+		}
+		if err := issuer.SetTokenSigningKey(tokenKey); err != nil {
+			panic("Error configuring token signing key " + err.Error())
+		}
+	}
+	if err := issuer.ValidateTokenIssuer(); err != nil {
+		panic("Error configuring Certificate Issuer " + err.Error())
+	}
+	if err := issuer.SetTokenOptions(tokenOptions(config)); err != nil {
+		panic("Error configuring token tags " + err.Error())
+	}
+
+	if err := issuer.SetMinRSAKeySize(config.MinRSAKeySize); err != nil {
+		panic("Error configuring Certificate Issuer " + err.Error())
+	}
+	if err := issuer.SetDurations(config.CertificateDuration, config.MaxCertificateDuration); err != nil {
+		panic("Error configuring Certificate Issuer " + err.Error())
+	}
+
+	// embed the OCSP responder in issued certificates, and configure a delegated OCSP signer
+	issuer.SetOCSPResponderURL(config.OCSPResponderURL)
+	if config.OCSPSigningCert != "" {
+		ocspCert, ocspKey, err := certificates.ReadCertificatePEM(config.OCSPSigningCert, config.OCSPSigningCertKey, config.OCSPSigningCertKeyPass)
+		if err != nil {
+			panic("Error loading OCSP signing certificate " + err.Error())
+		}
+		if err := issuer.SetOCSPSigner(ocspCert, ocspKey); err != nil {
+			panic("Error configuring OCSP signing certificate " + err.Error())
+		}
+	}
+
+	issuer.SetPolicy(policyEngine)
+
+	// regenerate the CRL on a schedule
+	go issuer.RunCRLUpdater(config.CRLUpdateInterval, stopCh)
+
+	// swap in the signing CA when its Secret or its files change
+	if config.SigningCASecret != "" {
+		parts := strings.SplitN(config.SigningCASecret, "/", 2)
+		go issuer.WatchSecret(kubeClient, parts[0], parts[1], config.SigningCASecretPassKey, stopCh)
+	} else if config.SigningCACertReloadInterval > 0 && config.SigningRemoteSigner == "" {
+		go issuer.WatchFiles(config.SigningCACert, config.SigningCACertKey, config.SigningCACertKeyPass, config.SigningCACertReloadInterval, stopCh)
+	}
+
+	return issuer
+}
+
+// newVaultIssuer creates the default issuer that forwards CSRs to the Vault PKI secrets engine, and starts
+// refreshing its CA and role until stopCh closes.
+func newVaultIssuer(config *config.Configuration, policyEngine *policy.Engine, stopCh <-chan struct{}) *certificates.VaultIssuer {
+	tlsConfig, err := certificates.NewVaultTLSConfig(config.VaultCACert)
+	if err != nil {
+		panic("Error loading Vault TLS configuration " + err.Error())
+	}
+	issuer, err := certificates.NewVaultIssuer(&certificates.VaultConfig{
+		Address:             config.VaultAddress,
+		Mount:               config.VaultPKIMount,
+		Role:                config.VaultRole,
+		Token:               config.VaultToken,
+		KubernetesAuthMount: config.VaultKubernetesAuthMount,
+		KubernetesAuthRole:  config.VaultKubernetesAuthRole,
+		TLSConfig:           tlsConfig,
+		Timeout:             config.VaultTimeout,
+	})
+	if err != nil {
+		panic("Error creating Vault Certificate Issuer " + err.Error())
+	}
+
+	// the CA key stays in Vault, so tokens are always signed with a separate key
+	tokenKey, err := certificates.ReadPrivateKeyPEM(config.TokenSigningKey, config.TokenSigningKeyPass)
+	if err != nil {
+		panic("Error loading token signing key " + err.Error())
+	}
+	if err := issuer.SetTokenSigningKey(tokenKey); err != nil {
+		panic("Error configuring token signing key " + err.Error())
+	}
+	if err := issuer.SetTokenOptions(tokenOptions(config)); err != nil {
+		panic("Error configuring token tags " + err.Error())
+	}
+
+	if err := issuer.SetMinRSAKeySize(config.MinRSAKeySize); err != nil {
+		panic("Error configuring Certificate Issuer " + err.Error())
+	}
+	if err := issuer.SetDurations(config.CertificateDuration, config.MaxCertificateDuration); err != nil {
+		panic("Error configuring Certificate Issuer " + err.Error())
+	}
+	issuer.SetPolicy(policyEngine)
+
+	go issuer.RunRefresher(config.VaultRefreshInterval, stopCh)
+
+	return issuer
+}
+
+// tokenOptions returns the tags and the validity of the tokens of the default issuer
+func tokenOptions(config *config.Configuration) *certificates.TokenOptions {
+	return &certificates.TokenOptions{
+		Validity:       config.TokenValidity,
+		StaticTags:     config.TokenStaticTags,
+		LabelKeys:      config.TokenLabelKeys,
+		AnnotationKeys: config.TokenAnnotationKeys,
+		SubjectFields:  config.TokenSubjectFields,
+		SANs:           config.TokenSANs,
+		AllowedKeys:    config.TokenAllowedKeys,
+		DeniedKeys:     config.TokenDeniedKeys,
+	}
+}
+
 // setLogs setups Zap to the specified logLevel.
 func setLogs(format, logLevel string) error {
 	var zapConfig zap.Config
@@ -115,6 +458,37 @@ func buildConfig(kubeconfig string) (*rest.Config, error) {
 	return rest.InClusterConfig()
 }
 
+// startHTTPServer serves `handler` on `address` in the background until stopCh closes. It serves over TLS if
+// `certFile` and `keyFile` are set.
+func startHTTPServer(address string, handler http.Handler, certFile, keyFile string, stopCh <-chan struct{}) {
+	server := &http.Server{
+		Addr:    address,
+		Handler: handler,
+	}
+
+	go func() {
+		zap.L().Info("Starting HTTP server", zap.String("address", address), zap.Bool("tls", certFile != ""))
+		var err error
+		if certFile != "" {
+			err = server.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			zap.L().Fatal("Error running HTTP server", zap.Error(err), zap.String("address", address))
+		}
+	}()
+
+	go func() {
+		<-stopCh
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			zap.L().Warn("Error shutting down HTTP server", zap.Error(err), zap.String("address", address))
+		}
+	}()
+}
+
 func createSignalChannel() <-chan struct{} {
 	stopCh := make(chan struct{})
 	sigsCh := make(chan os.Signal, 2)
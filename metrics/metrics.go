@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.aporeto.io/tg/tglib"
+)
+
+// namespace is the prefix of all Trireme-CSR metrics.
+const namespace = "trireme_csr"
+
+// Issuer operations that are counted in the issuer error metric.
+const (
+	IssuerOperationSign       = "sign"
+	IssuerOperationIssueToken = "issue_token"
+)
+
+var (
+	phaseTransitions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "certificate_phase_transitions_total",
+			Help:      "Number of Certificate phase transitions performed by the controller, by phase.",
+		},
+		[]string{"phase"},
+	)
+
+	statusReasons = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "certificate_status_reasons_total",
+			Help:      "Number of Certificate status updates performed by the controller, by status reason.",
+		},
+		[]string{"reason"},
+	)
+
+	processDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "process_duration_seconds",
+			Help:      "Time spent processing submitted Certificate requests.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+		},
+	)
+
+	issuerErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "issuer_errors_total",
+			Help:      "Number of errors returned by the issuer, by operation.",
+		},
+		[]string{"operation"},
+	)
+
+	leader = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "leader",
+			Help:      "Set to 1 while this replica is the leader, 0 otherwise.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		phaseTransitions,
+		statusReasons,
+		processDuration,
+		issuerErrors,
+		leader,
+	)
+}
+
+// Handler returns the HTTP handler serving the metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveStatusUpdate counts a status update that moved a Certificate to `phase` with `reason`.
+func ObserveStatusUpdate(phase, reason string) {
+	phaseTransitions.WithLabelValues(phase).Inc()
+	statusReasons.WithLabelValues(reason).Inc()
+}
+
+// ObserveProcessDuration records the time spent processing a Certificate request since `start`.
+func ObserveProcessDuration(start time.Time) {
+	processDuration.Observe(time.Since(start).Seconds())
+}
+
+// ObserveIssuerError counts an error returned by the issuer for the given operation.
+func ObserveIssuerError(operation string) {
+	issuerErrors.WithLabelValues(operation).Inc()
+}
+
+// SetLeader records if this replica is currently the leader.
+func SetLeader(isLeader bool) {
+	if isLeader {
+		leader.Set(1)
+		return
+	}
+	leader.Set(0)
+}
+
+// RegisterInformerSynced registers a gauge reporting the sync status of the informer `name`.
+func RegisterInformerSynced(name string, hasSynced func() bool) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "informer_synced",
+			Help:        "Set to 1 once the informer has synced its cache, 0 otherwise.",
+			ConstLabels: prometheus.Labels{"informer": name},
+		},
+		func() float64 {
+			if hasSynced() {
+				return 1
+			}
+			return 0
+		},
+	))
+}
+
+// RegisterCAExpiry registers a gauge reporting the seconds until the signing CA certificate expires.
+// `getCACert` must return the PEM encoded CA certificate, and is called at every collection.
+func RegisterCAExpiry(getCACert func() []byte) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "signing_ca_expiry_seconds",
+			Help:      "Seconds until the signing CA certificate expires.",
+		},
+		func() float64 {
+			cert, err := tglib.ReadCertificatePEMFromData(getCACert())
+			if err != nil {
+				return 0
+			}
+			return time.Until(cert.NotAfter).Seconds()
+		},
+	))
+}
@@ -0,0 +1,181 @@
+package ocspresponder
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ocsp"
+
+	"go.aporeto.io/tg/tglib"
+)
+
+// maxRequestSize is the maximum size of an OCSP request that we accept.
+const maxRequestSize = 10 * 1024
+
+// Records gives access to the issuance records of the controller.
+type Records interface {
+	// IsIssued returns true if the certificate with the given serial has been issued and is known to the controller.
+	IsIssued(serial *big.Int) bool
+}
+
+// Signer signs OCSP responses for the CA, and knows the certificates that have been revoked.
+type Signer interface {
+	SignOCSPResponse(template ocsp.Response) ([]byte, error)
+	GetCACert() []byte
+	IsRevoked(serial *big.Int) (revokedAt time.Time, reason int, revoked bool)
+}
+
+// Signers returns the signers of all CAs the responder answers for.
+type Signers func() []Signer
+
+// Responder is an RFC 6960 OCSP responder for the certificates issued by the controller.
+type Responder struct {
+	signers          Signers
+	records          Records
+	responseValidity time.Duration
+}
+
+// NewResponder creates an OCSP responder answering from the revocations of the signers and from `records`, whose responses are signed by the signer
+// of the CA that issued the certificate, and valid for `responseValidity`.
+func NewResponder(signers Signers, records Records, responseValidity time.Duration) *Responder {
+	return &Responder{
+		signers:          signers,
+		records:          records,
+		responseValidity: responseValidity,
+	}
+}
+
+// ServeHTTP handles OCSP requests sent with POST, or base64 encoded in the URL path with GET.
+// Use http.StripPrefix to mount the responder on a path other than the root.
+func (r *Responder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var der []byte
+	var err error
+
+	switch req.Method {
+	case http.MethodPost:
+		der, err = ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxRequestSize))
+	case http.MethodGet:
+		der, err = decodeGetRequest(req.URL.Path)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		zap.L().Debug("Invalid OCSP request", zap.Error(err))
+		writeResponse(w, ocsp.MalformedRequestErrorResponse)
+		return
+	}
+
+	ocspRequest, err := ocsp.ParseRequest(der)
+	if err != nil {
+		zap.L().Debug("Error parsing OCSP request", zap.Error(err))
+		writeResponse(w, ocsp.MalformedRequestErrorResponse)
+		return
+	}
+
+	response, err := r.respond(ocspRequest)
+	if err != nil {
+		zap.L().Error("Error creating OCSP response", zap.Error(err), zap.String("serial", ocspRequest.SerialNumber.String()))
+		writeResponse(w, ocsp.InternalErrorErrorResponse)
+		return
+	}
+	writeResponse(w, response)
+}
+
+// respond creates the signed response for the request.
+func (r *Responder) respond(ocspRequest *ocsp.Request) ([]byte, error) {
+	// we only answer for certificates that have been issued by one of our CAs
+	signer, err := r.signerFor(ocspRequest)
+	if err != nil {
+		return nil, err
+	}
+	if signer == nil {
+		zap.L().Debug("OCSP request for a different issuer", zap.String("serial", ocspRequest.SerialNumber.String()))
+		return ocsp.UnauthorizedErrorResponse, nil
+	}
+
+	// the revocations of the issuer are the ones on its CRL, the records only tell if a certificate is known at all
+	now := time.Now()
+	template := ocsp.Response{
+		Status:       ocsp.Unknown,
+		SerialNumber: ocspRequest.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(r.responseValidity),
+		IssuerHash:   ocspRequest.HashAlgorithm,
+	}
+	if revokedAt, reason, revoked := signer.IsRevoked(ocspRequest.SerialNumber); revoked {
+		template.Status = ocsp.Revoked
+		template.RevokedAt = revokedAt
+		template.RevocationReason = reason
+	} else if r.records.IsIssued(ocspRequest.SerialNumber) {
+		template.Status = ocsp.Good
+	}
+	zap.L().Debug("Answering OCSP request", zap.String("serial", ocspRequest.SerialNumber.String()), zap.Int("status", template.Status))
+
+	return signer.SignOCSPResponse(template)
+}
+
+// signerFor returns the signer of the CA whose key hash matches the request, or nil if there is none.
+func (r *Responder) signerFor(ocspRequest *ocsp.Request) (Signer, error) {
+	for _, signer := range r.signers() {
+		caCert, err := tglib.ReadCertificatePEMFromData(signer.GetCACert())
+		if err != nil {
+			return nil, fmt.Errorf("unable to load CA certificate: %s", err)
+		}
+
+		issuerKeyHash, err := hashIssuerKey(caCert, ocspRequest.HashAlgorithm)
+		if err == nil && bytes.Equal(issuerKeyHash, ocspRequest.IssuerKeyHash) {
+			return signer, nil
+		}
+	}
+	return nil, nil
+}
+
+// hashIssuerKey hashes the public key of the CA as it is done in the CertID of OCSP requests.
+func hashIssuerKey(caCert *x509.Certificate, hash crypto.Hash) ([]byte, error) {
+	if !hash.Available() {
+		return nil, fmt.Errorf("unsupported hash algorithm %v", hash)
+	}
+
+	var publicKeyInfo struct {
+		Algorithm asn1.RawValue
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(caCert.RawSubjectPublicKeyInfo, &publicKeyInfo); err != nil {
+		return nil, err
+	}
+
+	h := hash.New()
+	h.Write(publicKeyInfo.PublicKey.RightAlign())
+	return h.Sum(nil), nil
+}
+
+// decodeGetRequest decodes the OCSP request from the path of a GET request. The responder must be
+// mounted with http.StripPrefix, as the base64 encoded request can contain slashes itself.
+func decodeGetRequest(path string) ([]byte, error) {
+	encoded, err := url.PathUnescape(strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if encoded == "" {
+		return nil, fmt.Errorf("no OCSP request in path")
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+func writeResponse(w http.ResponseWriter, response []byte) {
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(response)
+}
@@ -0,0 +1,321 @@
+package ocspresponder
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/CodingJzy/trireme-csr/certificates"
+)
+
+// testRecords are the serials of the issued certificates
+type testRecords map[string]bool
+
+func (r testRecords) IsIssued(serial *big.Int) bool {
+	return r[serial.String()]
+}
+
+// newTestCAData creates a self-signed ECDSA CA, and returns the PEM encoded certificate and key
+func newTestCAData(t *testing.T, commonName string) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %s", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to encode CA key: %s", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+// newTestIssuer creates a TriremeIssuer with a new self-signed ECDSA CA, and returns it with its CA certificate
+func newTestIssuer(t *testing.T, commonName string) (*certificates.TriremeIssuer, *x509.Certificate) {
+	t.Helper()
+
+	certPEM, keyPEM := newTestCAData(t, commonName)
+	issuer, err := certificates.NewTriremeIssuerFromData(certPEM, keyPEM, "")
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+	return issuer, parseTestCert(t, certPEM)
+}
+
+// parseTestCert parses a PEM encoded certificate
+func parseTestCert(t *testing.T, certPEM []byte) *x509.Certificate {
+	t.Helper()
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		t.Fatalf("failed to decode certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %s", err)
+	}
+	return cert
+}
+
+// signTestCert signs a certificate for a new ECDSA key with the issuer
+func signTestCert(t *testing.T, issuer certificates.Issuer, commonName string) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: commonName}}, key)
+	if err != nil {
+		t.Fatalf("failed to create CSR: %s", err)
+	}
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	if err != nil {
+		t.Fatalf("failed to parse CSR: %s", err)
+	}
+	certPEM, err := issuer.Sign(csr, &certificates.SignOptions{})
+	if err != nil {
+		t.Fatalf("failed to sign certificate: %s", err)
+	}
+	return parseTestCert(t, certPEM)
+}
+
+// newTestRequest creates the DER encoded OCSP request for the certificate of the CA
+func newTestRequest(t *testing.T, cert, ca *x509.Certificate) []byte {
+	t.Helper()
+
+	der, err := ocsp.CreateRequest(cert, ca, &ocsp.RequestOptions{Hash: crypto.SHA256})
+	if err != nil {
+		t.Fatalf("failed to create OCSP request: %s", err)
+	}
+	return der
+}
+
+// postRequest sends the OCSP request to the responder with POST, and returns the raw response
+func postRequest(t *testing.T, responder http.Handler, der []byte) []byte {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	responder.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(der)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("responder returned status %d", w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/ocsp-response" {
+		t.Errorf("responder returned content type '%s'", contentType)
+	}
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Fatalf("failed to read response: %s", err)
+	}
+	return body
+}
+
+func TestResponderStatus(t *testing.T) {
+	issuer, ca := newTestIssuer(t, "test-ca")
+	good := signTestCert(t, issuer, "good")
+	revoked := signTestCert(t, issuer, "revoked")
+	unknown := signTestCert(t, issuer, "unknown")
+	records := testRecords{good.SerialNumber.String(): true, revoked.SerialNumber.String(): true}
+
+	revokedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	if err := issuer.Revoke(revoked, revokedAt, ocsp.KeyCompromise); err != nil {
+		t.Fatalf("Revoke() error = %s", err)
+	}
+
+	validity := 30 * time.Minute
+	responder := NewResponder(func() []Signer { return []Signer{issuer} }, records, validity)
+
+	tests := []struct {
+		name   string
+		cert   *x509.Certificate
+		status int
+	}{
+		{name: "issued certificate", cert: good, status: ocsp.Good},
+		{name: "revoked certificate", cert: revoked, status: ocsp.Revoked},
+		{name: "certificate that is not known", cert: unknown, status: ocsp.Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			der := postRequest(t, responder, newTestRequest(t, tt.cert, ca))
+			response, err := ocsp.ParseResponseForCert(der, tt.cert, ca)
+			if err != nil {
+				t.Fatalf("failed to parse OCSP response: %s", err)
+			}
+			if response.Status != tt.status {
+				t.Errorf("OCSP response status = %d, want %d", response.Status, tt.status)
+			}
+			if response.SerialNumber.Cmp(tt.cert.SerialNumber) != 0 {
+				t.Errorf("OCSP response is for serial %s, want %s", response.SerialNumber, tt.cert.SerialNumber)
+			}
+			if got := response.NextUpdate.Sub(response.ThisUpdate); got != validity {
+				t.Errorf("OCSP response is valid for %s, want %s", got, validity)
+			}
+			if time.Since(response.ThisUpdate) > time.Minute {
+				t.Errorf("OCSP response was produced at %s, want now", response.ThisUpdate)
+			}
+			if tt.status == ocsp.Revoked {
+				if response.RevocationReason != ocsp.KeyCompromise {
+					t.Errorf("OCSP response revocation reason = %d, want %d", response.RevocationReason, ocsp.KeyCompromise)
+				}
+				if !response.RevokedAt.Equal(revokedAt) {
+					t.Errorf("OCSP response revocation time = %s, want %s", response.RevokedAt, revokedAt)
+				}
+			}
+		})
+	}
+}
+
+func TestResponderGet(t *testing.T) {
+	issuer, ca := newTestIssuer(t, "test-ca")
+	cert := signTestCert(t, issuer, "good")
+	responder := NewResponder(func() []Signer { return []Signer{issuer} }, testRecords{cert.SerialNumber.String(): true}, time.Hour)
+
+	// the request can contain slashes, so it gets escaped in the path
+	path := "/" + url.PathEscape(base64.StdEncoding.EncodeToString(newTestRequest(t, cert, ca)))
+	w := httptest.NewRecorder()
+	responder.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	response, err := ocsp.ParseResponseForCert(w.Body.Bytes(), cert, ca)
+	if err != nil {
+		t.Fatalf("failed to parse OCSP response: %s", err)
+	}
+	if response.Status != ocsp.Good {
+		t.Errorf("OCSP response status = %d, want %d", response.Status, ocsp.Good)
+	}
+}
+
+func TestResponderErrors(t *testing.T) {
+	issuer, _ := newTestIssuer(t, "test-ca")
+	other, otherCA := newTestIssuer(t, "other-ca")
+	foreign := signTestCert(t, other, "foreign")
+	responder := NewResponder(func() []Signer { return []Signer{issuer} }, testRecords{foreign.SerialNumber.String(): true}, time.Hour)
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    []byte
+		want    []byte
+		wantErr int
+	}{
+		{
+			name:   "certificate of another issuer",
+			method: http.MethodPost,
+			path:   "/",
+			body:   newTestRequest(t, foreign, otherCA),
+			want:   ocsp.UnauthorizedErrorResponse,
+		},
+		{
+			name:   "malformed request",
+			method: http.MethodPost,
+			path:   "/",
+			body:   []byte("not an OCSP request"),
+			want:   ocsp.MalformedRequestErrorResponse,
+		},
+		{
+			name:   "GET without a request",
+			method: http.MethodGet,
+			path:   "/",
+			want:   ocsp.MalformedRequestErrorResponse,
+		},
+		{
+			name:    "unsupported method",
+			method:  http.MethodPut,
+			path:    "/",
+			wantErr: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			responder.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, bytes.NewReader(tt.body)))
+			if tt.wantErr != 0 {
+				if w.Code != tt.wantErr {
+					t.Errorf("responder returned status %d, want %d", w.Code, tt.wantErr)
+				}
+				return
+			}
+			if !bytes.Equal(w.Body.Bytes(), tt.want) {
+				t.Errorf("responder returned %x, want %x", w.Body.Bytes(), tt.want)
+			}
+		})
+	}
+}
+
+func TestResponderSignerSelection(t *testing.T) {
+	issuerA, caA := newTestIssuer(t, "ca-a")
+	issuerB, caB := newTestIssuer(t, "ca-b")
+	certA := signTestCert(t, issuerA, "a")
+	certB := signTestCert(t, issuerB, "b")
+
+	// the replaced CA of issuer A keeps answering for its certificates
+	retiredCert := certA
+	retiredCA := caA
+	newCertPEM, newKeyPEM := newTestCAData(t, "ca-a-new")
+	if err := issuerA.Reload(newCertPEM, newKeyPEM, ""); err != nil {
+		t.Fatalf("Reload() error = %s", err)
+	}
+	newCert := signTestCert(t, issuerA, "a-new")
+	newCA := parseTestCert(t, newCertPEM)
+
+	signers := func() []Signer {
+		signers := []Signer{issuerA, issuerB}
+		for _, ca := range issuerA.RetiredCAs() {
+			signers = append(signers, ca)
+		}
+		return signers
+	}
+	records := testRecords{certB.SerialNumber.String(): true, retiredCert.SerialNumber.String(): true, newCert.SerialNumber.String(): true}
+	responder := NewResponder(signers, records, time.Hour)
+
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		ca   *x509.Certificate
+	}{
+		{name: "certificate of the second issuer", cert: certB, ca: caB},
+		{name: "certificate of the replaced CA", cert: retiredCert, ca: retiredCA},
+		{name: "certificate of the new CA", cert: newCert, ca: newCA},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			der := postRequest(t, responder, newTestRequest(t, tt.cert, tt.ca))
+			// parsing verifies that the response has been signed by the CA of the certificate
+			response, err := ocsp.ParseResponseForCert(der, tt.cert, tt.ca)
+			if err != nil {
+				t.Fatalf("OCSP response has not been signed by the issuing CA: %s", err)
+			}
+			if response.Status != ocsp.Good {
+				t.Errorf("OCSP response status = %d, want %d", response.Status, ocsp.Good)
+			}
+		})
+	}
+}
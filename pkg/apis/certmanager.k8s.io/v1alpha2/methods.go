@@ -1,7 +1,10 @@
 package v1alpha2
 
 import (
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
 	"fmt"
 
 	"go.aporeto.io/tg/tglib"
@@ -23,6 +26,31 @@ func (c *CertificateSpec) GetCertificateRequest() (*x509.CertificateRequest, err
 	return csrs[0], nil
 }
 
+// GetRequestHash returns the hex encoded SHA256 hash of the certificate request in the spec and of the
+// requested duration, usages, isCA and issuer, or an empty string if there is no request. The options only
+// contribute to the hash if they are set, so that the hash of plain requests does not change.
+func (c *CertificateSpec) GetRequestHash() string {
+	if len(c.Request) == 0 {
+		return ""
+	}
+
+	h := sha256.New()
+	h.Write(c.Request)
+	if c.Duration != nil || len(c.Usages) > 0 || c.IsCA || c.IssuerRef != nil {
+		if c.Duration != nil {
+			fmt.Fprintf(h, "\nduration=%s", c.Duration.Duration)
+		}
+		for _, usage := range c.Usages {
+			fmt.Fprintf(h, "\nusage=%s", usage)
+		}
+		fmt.Fprintf(h, "\nisCA=%t", c.IsCA)
+		if c.IssuerRef != nil {
+			fmt.Fprintf(h, "\nissuer=%s", c.IssuerRef.Name)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // GetCertificate returns a `*x509.Certificate` object from the status holding the
 // issued certificate from the CA, or an error if this fails
 func (c *CertificateStatus) GetCertificate() (*x509.Certificate, error) {
@@ -32,7 +60,7 @@ func (c *CertificateStatus) GetCertificate() (*x509.Certificate, error) {
 	return tglib.ReadCertificatePEMFromData(c.Certificate)
 }
 
-// GetCACertificate returns a `*x509.Certificate` object from the status holding the issuing CA
+// GetCACertificate returns a `*x509.Certificate` object from the status holding the root CA
 // certificate, or an error if this fails
 func (c *CertificateStatus) GetCACertificate() (*x509.Certificate, error) {
 	if c.Ca == nil {
@@ -41,6 +69,25 @@ func (c *CertificateStatus) GetCACertificate() (*x509.Certificate, error) {
 	return tglib.ReadCertificatePEMFromData(c.Ca)
 }
 
+// GetChainCertificates returns the intermediate CA certificates from the status, starting with the
+// issuer of the certificate, or an error if this fails. It is empty if the root CA issued the certificate.
+func (c *CertificateStatus) GetChainCertificates() ([]*x509.Certificate, error) {
+	var chain []*x509.Certificate
+	rest := c.Chain
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return chain, nil
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse chain certificate: %s", err)
+		}
+		chain = append(chain, cert)
+	}
+}
+
 // GetCertificateRequest returns a `*x509.CertificateRequest` object from the spec, or
 // an error if this fails.
 func (c *Certificate) GetCertificateRequest() (*x509.CertificateRequest, error) {
@@ -53,8 +100,81 @@ func (c *Certificate) GetCertificate() (*x509.Certificate, error) {
 	return c.Status.GetCertificate()
 }
 
-// GetCACertificate returns a `*x509.Certificate` object from the status holding the issuing CA
+// GetCACertificate returns a `*x509.Certificate` object from the status holding the root CA
 // certificate, or an error if this fails
 func (c *Certificate) GetCACertificate() (*x509.Certificate, error) {
 	return c.Status.GetCACertificate()
 }
+
+// GetChainCertificates returns the intermediate CA certificates from the status, or an error if this fails
+func (c *Certificate) GetChainCertificates() ([]*x509.Certificate, error) {
+	return c.Status.GetChainCertificates()
+}
+
+// RequestChanged returns true if the certificate request in the spec is not the one
+// that the current status has been computed for
+func (c *Certificate) RequestChanged() bool {
+	return c.Spec.GetRequestHash() != c.Status.RequestHash
+}
+
+// revocationReasonCodes maps the revocation reasons to their RFC 5280 code
+var revocationReasonCodes = map[string]int{
+	RevocationReasonUnspecified:          0,
+	RevocationReasonKeyCompromise:        1,
+	RevocationReasonCACompromise:         2,
+	RevocationReasonAffiliationChanged:   3,
+	RevocationReasonSuperseded:           4,
+	RevocationReasonCessationOfOperation: 5,
+	RevocationReasonCertificateHold:      6,
+	RevocationReasonPrivilegeWithdrawn:   9,
+	RevocationReasonAACompromise:         10,
+}
+
+// GetRevocationReasonCode returns the RFC 5280 reason code of a revocation reason, or an error
+// if the reason is unknown. An empty reason is `RevocationReasonUnspecified`.
+func GetRevocationReasonCode(reason string) (int, error) {
+	if reason == "" {
+		reason = RevocationReasonUnspecified
+	}
+	code, ok := revocationReasonCodes[reason]
+	if !ok {
+		return 0, fmt.Errorf("unknown revocation reason '%s'", reason)
+	}
+	return code, nil
+}
+
+// GetRevocationRequest returns the requested revocation reason, and true if the revocation has been requested
+// either in the spec, or with the `RevocationAnnotation`. The spec takes precedence over the annotation.
+func (c *Certificate) GetRevocationRequest() (string, bool) {
+	if c.Spec.Revocation != nil {
+		reason := c.Spec.Revocation.Reason
+		if reason == "" {
+			reason = RevocationReasonUnspecified
+		}
+		return reason, true
+	}
+	if reason, ok := c.Annotations[RevocationAnnotation]; ok {
+		if reason == "" {
+			reason = RevocationReasonUnspecified
+		}
+		return reason, true
+	}
+	return "", false
+}
+
+// TokenRefreshRequested returns true if a new token has been requested with the `TokenRefreshAnnotation`,
+// and has not been issued yet.
+func (c *Certificate) TokenRefreshRequested() bool {
+	request, ok := c.Annotations[TokenRefreshAnnotation]
+	return ok && request != c.Status.TokenRefreshRequest
+}
+
+// GetCondition returns the condition of the given type, or nil if the status has none
+func (c *CertificateStatus) GetCondition(conditionType CertificateConditionType) *CertificateCondition {
+	for i := range c.Conditions {
+		if c.Conditions[i].Type == conditionType {
+			return &c.Conditions[i]
+		}
+	}
+	return nil
+}
@@ -57,6 +57,10 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&Certificate{},
 		&CertificateList{},
+		&CertificateApproval{},
+		&CertificateApprovalList{},
+		&Issuer{},
+		&IssuerList{},
 	)
 
 	scheme.AddKnownTypes(SchemeGroupVersion, &metav1.Status{})
@@ -7,6 +7,9 @@ import (
 // CertificateResourcePlural is the ressource name used to get a list of cetts.
 const CertificateResourcePlural = "certificates"
 
+// IssuerResourcePlural is the resource name used to get a list of issuers.
+const IssuerResourcePlural = "issuers"
+
 // +genclient
 // +genclient:nonNamespaced
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -23,6 +26,53 @@ type Certificate struct {
 type CertificateSpec struct {
 	// Base64-encoded PKCS#10 CSR data
 	Request []byte `json:"request" protobuf:"bytes,1,opt,name=request"`
+	// Revocation requests the revocation of the issued certificate
+	Revocation *CertificateRevocation `json:"revocation,omitempty" protobuf:"bytes,2,opt,name=revocation"`
+	// Profile selects the approval policy that applies to this request
+	Profile string `json:"profile,omitempty" protobuf:"bytes,3,opt,name=profile"`
+	// Duration is the requested validity of the certificate. Defaults to the issuer default, and is
+	// clamped to the issuer maximum and to the validity of the CA.
+	Duration *metav1.Duration `json:"duration,omitempty" protobuf:"bytes,4,opt,name=duration"`
+	// Usages are the requested key usages of the certificate. Defaults to `digital signature`,
+	// `server auth` and `client auth`, plus `key encipherment` for RSA keys.
+	Usages []KeyUsage `json:"usages,omitempty" protobuf:"bytes,5,rep,name=usages,casttype=KeyUsage"`
+	// IsCA requests a CA certificate, which must be allowed by the issuance policy
+	IsCA bool `json:"isCA,omitempty" protobuf:"varint,6,opt,name=isCA"`
+	// IssuerRef selects the Issuer that signs the certificate. Defaults to the issuer configured on the controller.
+	IssuerRef *IssuerReference `json:"issuerRef,omitempty" protobuf:"bytes,7,opt,name=issuerRef"`
+}
+
+// IssuerReference references an Issuer
+type IssuerReference struct {
+	Name string `json:"name" protobuf:"bytes,1,opt,name=name"`
+}
+
+// KeyUsage is a key usage or an extended key usage of a certificate
+type KeyUsage string
+
+// Key usages as defined in RFC 5280, sections 4.2.1.3 and 4.2.1.12
+const (
+	UsageDigitalSignature  KeyUsage = "digital signature"
+	UsageContentCommitment KeyUsage = "content commitment"
+	UsageKeyEncipherment   KeyUsage = "key encipherment"
+	UsageDataEncipherment  KeyUsage = "data encipherment"
+	UsageKeyAgreement      KeyUsage = "key agreement"
+	UsageCertSign          KeyUsage = "cert sign"
+	UsageCRLSign           KeyUsage = "crl sign"
+	UsageEncipherOnly      KeyUsage = "encipher only"
+	UsageDecipherOnly      KeyUsage = "decipher only"
+	UsageServerAuth        KeyUsage = "server auth"
+	UsageClientAuth        KeyUsage = "client auth"
+	UsageCodeSigning       KeyUsage = "code signing"
+	UsageEmailProtection   KeyUsage = "email protection"
+	UsageTimestamping      KeyUsage = "timestamping"
+	UsageOCSPSigning       KeyUsage = "ocsp signing"
+)
+
+// CertificateRevocation is a request to revoke an issued certificate
+type CertificateRevocation struct {
+	// Reason is one of the `RevocationReason*` values, defaults to `RevocationReasonUnspecified`
+	Reason string `json:"reason,omitempty" protobuf:"bytes,1,opt,name=reason"`
 }
 
 // CertificateStatus is the status for Certificates on the API
@@ -33,6 +83,60 @@ type CertificateStatus struct {
 	Certificate []byte           `json:"certificate,omitempty" protobuf:"bytes,4,opt,name=certificate"`
 	Token       []byte           `json:"token,omitempty" protobuf:"bytes,5,opt,name=token"`
 	Ca          []byte           `json:"ca,omitempty" protobuf:"bytes,6,opt,name=ca"`
+	// RequestHash is the hash of the spec request that the current phase was reached with
+	RequestHash string `json:"requestHash,omitempty" protobuf:"bytes,7,opt,name=requestHash"`
+	// RevocationReason is the reason the certificate has been revoked with
+	RevocationReason string `json:"revocationReason,omitempty" protobuf:"bytes,8,opt,name=revocationReason"`
+	// RevocationTime is the time the certificate has been revoked at
+	RevocationTime *metav1.Time `json:"revocationTime,omitempty" protobuf:"bytes,9,opt,name=revocationTime"`
+	// Conditions holds the approval decisions for the request
+	Conditions []CertificateCondition `json:"conditions,omitempty" protobuf:"bytes,10,rep,name=conditions"`
+	// ApprovedBy is the identity that approved the request that the certificate was signed for
+	ApprovedBy string `json:"approvedBy,omitempty" protobuf:"bytes,11,opt,name=approvedBy"`
+	// ApprovedAt is the time the request that the certificate was signed for has been approved at
+	ApprovedAt *metav1.Time `json:"approvedAt,omitempty" protobuf:"bytes,12,opt,name=approvedAt"`
+	// NotBefore is the start of the granted validity of the certificate
+	NotBefore *metav1.Time `json:"notBefore,omitempty" protobuf:"bytes,13,opt,name=notBefore"`
+	// NotAfter is the end of the granted validity of the certificate
+	NotAfter *metav1.Time `json:"notAfter,omitempty" protobuf:"bytes,14,opt,name=notAfter"`
+	// Usages are the granted key usages of the certificate
+	Usages []KeyUsage `json:"usages,omitempty" protobuf:"bytes,15,rep,name=usages,casttype=KeyUsage"`
+	// IsCA is set if a CA certificate has been granted
+	IsCA bool `json:"isCA,omitempty" protobuf:"varint,16,opt,name=isCA"`
+	// Chain holds the PEM encoded intermediate CAs between the certificate and the root CA in `ca`,
+	// starting with the issuer of the certificate. It is empty if the certificate is issued by the root CA.
+	Chain []byte `json:"chain,omitempty" protobuf:"bytes,17,opt,name=chain"`
+	// ReissueRequired is set if the certificate has been issued by a retiring CA, and must be renewed
+	ReissueRequired bool `json:"reissueRequired,omitempty" protobuf:"varint,18,opt,name=reissueRequired"`
+	// TokenIssuedAt is the time the token has been issued at, as it gets refreshed without the certificate
+	TokenIssuedAt *metav1.Time `json:"tokenIssuedAt,omitempty" protobuf:"bytes,19,opt,name=tokenIssuedAt"`
+	// TokenNotAfter is the time the token expires at
+	TokenNotAfter *metav1.Time `json:"tokenNotAfter,omitempty" protobuf:"bytes,20,opt,name=tokenNotAfter"`
+	// TokenRefreshRequest is the value of the `TokenRefreshAnnotation` that the token has last been issued for
+	TokenRefreshRequest string `json:"tokenRefreshRequest,omitempty" protobuf:"bytes,21,opt,name=tokenRefreshRequest"`
+}
+
+// CertificateConditionType is the type of a condition of a Certificate
+type CertificateConditionType string
+
+const (
+	// CertificateApproved means that the request has been approved for signing
+	CertificateApproved CertificateConditionType = "Approved"
+	// CertificateDenied means that the request has been denied and will not be signed
+	CertificateDenied CertificateConditionType = "Denied"
+)
+
+// CertificateCondition is an approval decision for a Certificate request, as recorded by the controller
+type CertificateCondition struct {
+	Type CertificateConditionType `json:"type" protobuf:"bytes,1,opt,name=type,casttype=CertificateConditionType"`
+	// Reason is a brief reason for the decision
+	Reason string `json:"reason,omitempty" protobuf:"bytes,2,opt,name=reason"`
+	// Message is a human readable message with details about the decision
+	Message string `json:"message,omitempty" protobuf:"bytes,3,opt,name=message"`
+	// Approver is the approver of the CertificateApproval that the decision has been taken with
+	Approver string `json:"approver,omitempty" protobuf:"bytes,4,opt,name=approver"`
+	// LastUpdateTime is the time of the decision
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty" protobuf:"bytes,5,opt,name=lastUpdateTime"`
 }
 
 // CertificatePhase defines the phase of the certificate
@@ -47,6 +151,41 @@ const (
 	CertificateRejected CertificatePhase = "Rejected"
 	// CertificateUnknown defines that the CSR is in an unknown state, and the controller will not take any further action on this object
 	CertificateUnknown CertificatePhase = "Unknown"
+	// CertificatePending defines that the CSR is valid, but waits for an approval before it gets signed
+	CertificatePending CertificatePhase = "Pending"
+	// CertificateRevoked defines that the certificate was issued, but has been revoked since, and is published in the CRL of the CA
+	CertificateRevoked CertificatePhase = "Revoked"
+)
+
+// RevocationAnnotation can be set on a Certificate to request the revocation of the issued certificate,
+// as an alternative to `spec.revocation`. Its value is the revocation reason.
+const RevocationAnnotation = "certmanager.k8s.io/revoke"
+
+// TokenRefreshAnnotation can be set on a signed Certificate to request a new token for the issued certificate.
+// Another refresh can be requested by changing its value, e.g. to the current time.
+const TokenRefreshAnnotation = "certmanager.k8s.io/refresh-token"
+
+// RenewalOfLabel is set by the CertManager on the Certificates that renew a certificate. Its value is the
+// name of the Certificate that got renewed, and the renewal gets deleted once it has been replaced. The controller
+// checks the Issuer and the approval of a renewal under that name, as the name of the renewal itself is generated.
+const RenewalOfLabel = "certmanager.k8s.io/renewal-of"
+
+// ApproverSignatureAnnotation is set on CertificateApprovals by the approval webhook of the controller, together with
+// `spec.approver`. Its value signs the decision and the authenticated approver, and CertificateApprovals without a
+// valid signature are ignored.
+const ApproverSignatureAnnotation = "certmanager.k8s.io/approver-signature"
+
+// Revocation reasons as defined in RFC 5280, section 5.3.1
+const (
+	RevocationReasonUnspecified          = "unspecified"
+	RevocationReasonKeyCompromise        = "keyCompromise"
+	RevocationReasonCACompromise         = "cACompromise"
+	RevocationReasonAffiliationChanged   = "affiliationChanged"
+	RevocationReasonSuperseded           = "superseded"
+	RevocationReasonCessationOfOperation = "cessationOfOperation"
+	RevocationReasonCertificateHold      = "certificateHold"
+	RevocationReasonPrivilegeWithdrawn   = "privilegeWithdrawn"
+	RevocationReasonAACompromise         = "aACompromise"
 )
 
 // Certificate Status reasons
@@ -57,6 +196,9 @@ const (
 	StatusReasonProcessedRejected             = "ProcessedRejected"
 	StatusReasonProcessedRejectedInvalidCSR   = "ProcessedRejectedInvalidCSR"
 	StatusReasonProcessedRejectedInvalidCerts = "ProcessedRejectedInvalidCerts"
+	StatusReasonRevoked                       = "Revoked"
+	StatusReasonPendingApproval               = "PendingApproval"
+	StatusReasonProcessedRejectedDenied       = "ProcessedRejectedDenied"
 )
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -67,3 +209,153 @@ type CertificateList struct {
 	metav1.ListMeta `json:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
 	Items           []Certificate `json:"items" protobuf:"bytes,2,rep,name=items"`
 }
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Issuer describes a CA that signs the Certificates referencing it
+type Issuer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
+	Spec              IssuerSpec   `json:"spec" protobuf:"bytes,2,req,name=spec"`
+	Status            IssuerStatus `json:"status" protobuf:"bytes,3,req,name=status"`
+}
+
+// IssuerSpec is the specification for Issuers on the API
+type IssuerSpec struct {
+	// CA references the Secret holding the CA certificate and key
+	CA SecretReference `json:"ca" protobuf:"bytes,1,opt,name=ca"`
+	// SignatureAlgorithm overrides the algorithm used to sign certificates, e.g. `SHA384-RSAPSS`
+	SignatureAlgorithm string `json:"signatureAlgorithm,omitempty" protobuf:"bytes,2,opt,name=signatureAlgorithm"`
+	// Duration is the default validity of issued certificates. Defaults to the controller default.
+	Duration *metav1.Duration `json:"duration,omitempty" protobuf:"bytes,3,opt,name=duration"`
+	// MaxDuration is the maximum validity of issued certificates. Defaults to the controller maximum.
+	MaxDuration *metav1.Duration `json:"maxDuration,omitempty" protobuf:"bytes,4,opt,name=maxDuration"`
+	// Profiles holds the defaults of Certificates by their `spec.profile`
+	Profiles map[string]IssuerProfile `json:"profiles,omitempty" protobuf:"bytes,5,rep,name=profiles"`
+	// Token holds the settings of the tokens issued next to the certificates
+	Token *IssuerToken `json:"token,omitempty" protobuf:"bytes,6,opt,name=token"`
+	// TrustedCAs reference the CA bundles of retiring CAs, whose certificates stay valid until they expire,
+	// but must be re-issued by the CA. Their certificates can not be revoked through the Issuer, as it can not
+	// sign their CRLs. Only the certificate key of the Secrets is read.
+	TrustedCAs []SecretReference `json:"trustedCAs,omitempty" protobuf:"bytes,7,rep,name=trustedCAs"`
+	// AllowedCertificates are patterns of the names of the Certificates that may reference the Issuer, where `*`
+	// matches any characters. Certificates are cluster-scoped, so that requesters are told apart by the names
+	// they are allowed to create. Certificates can not reference an Issuer that does not list any pattern.
+	AllowedCertificates []string `json:"allowedCertificates,omitempty" protobuf:"bytes,8,rep,name=allowedCertificates"`
+}
+
+// SecretReference references a Secret and the keys of the PEM encoded certificate and key in it
+type SecretReference struct {
+	Namespace string `json:"namespace" protobuf:"bytes,1,opt,name=namespace"`
+	Name      string `json:"name" protobuf:"bytes,2,opt,name=name"`
+	// CertificateKey defaults to `tls.crt`. For a CA, it can hold a bundle of the CA followed by its intermediates and root CA
+	CertificateKey string `json:"certificateKey,omitempty" protobuf:"bytes,3,opt,name=certificateKey"`
+	// PrivateKeyKey defaults to `tls.key`
+	PrivateKeyKey string `json:"privateKeyKey,omitempty" protobuf:"bytes,4,opt,name=privateKeyKey"`
+	// PassphraseKey is the key of the passphrase of an encrypted private key
+	PassphraseKey string `json:"passphraseKey,omitempty" protobuf:"bytes,5,opt,name=passphraseKey"`
+}
+
+// Default keys of the certificate and the key in a Secret, as used by `kubernetes.io/tls` Secrets
+const (
+	DefaultSecretCertificateKey = "tls.crt"
+	DefaultSecretPrivateKeyKey  = "tls.key"
+)
+
+// IssuerProfile holds the defaults of the Certificates of a profile, which apply if a Certificate does not request them
+type IssuerProfile struct {
+	Duration *metav1.Duration `json:"duration,omitempty" protobuf:"bytes,1,opt,name=duration"`
+	Usages   []KeyUsage       `json:"usages,omitempty" protobuf:"bytes,2,rep,name=usages,casttype=KeyUsage"`
+}
+
+// IssuerToken holds the token settings of an Issuer
+type IssuerToken struct {
+	// SigningKey references a Secret holding a separate ECDSA key that signs tokens, which is required
+	// if the CA key is not an ECDSA key. Only its private key is used.
+	SigningKey *SecretReference `json:"signingKey,omitempty" protobuf:"bytes,1,opt,name=signingKey"`
+	// Validity of the tokens. Defaults to the validity of the certificate, which also caps it.
+	Validity *metav1.Duration `json:"validity,omitempty" protobuf:"bytes,2,opt,name=validity"`
+	// StaticTags are added to every token as `key=value`, and take precedence over the tags of the requests
+	StaticTags []string `json:"staticTags,omitempty" protobuf:"bytes,3,rep,name=staticTags"`
+	// LabelKeys and AnnotationKeys are patterns of the keys of the labels and annotations of a Certificate
+	// that are copied into its token, where `*` matches any characters
+	LabelKeys      []string `json:"labelKeys,omitempty" protobuf:"bytes,4,rep,name=labelKeys"`
+	AnnotationKeys []string `json:"annotationKeys,omitempty" protobuf:"bytes,5,rep,name=annotationKeys"`
+	// SubjectFields are the subject fields of the certificate that are copied into its token: `commonName`,
+	// `organization`, `organizationalUnit`, `country`, `province`, `locality` or `serialNumber`
+	SubjectFields []string `json:"subjectFields,omitempty" protobuf:"bytes,6,rep,name=subjectFields"`
+	// SANs are the types of the subject alternative names of the certificate that are copied into its token:
+	// `dns`, `email`, `ip` or `uri`
+	SANs []string `json:"sans,omitempty" protobuf:"bytes,7,rep,name=sans"`
+	// AllowedKeys and DeniedKeys are patterns that limit the tag keys that requests can set. A tag must match
+	// one of the allowed keys if set, and none of the denied keys. They do not apply to the static tags.
+	AllowedKeys []string `json:"allowedKeys,omitempty" protobuf:"bytes,8,rep,name=allowedKeys"`
+	DeniedKeys  []string `json:"deniedKeys,omitempty" protobuf:"bytes,9,rep,name=deniedKeys"`
+}
+
+// IssuerStatus is the status for Issuers on the API
+type IssuerStatus struct {
+	// Ready is set once the Issuer has been loaded and can sign certificates
+	Ready   bool   `json:"ready" protobuf:"varint,1,opt,name=ready"`
+	Reason  string `json:"reason,omitempty" protobuf:"bytes,2,opt,name=reason"`
+	Message string `json:"message,omitempty" protobuf:"bytes,3,opt,name=message"`
+	// ObservedGeneration is the generation of the Issuer that the status applies to
+	ObservedGeneration int64 `json:"observedGeneration,omitempty" protobuf:"varint,4,opt,name=observedGeneration"`
+}
+
+// Issuer Status reasons
+const (
+	IssuerStatusReasonLoaded = "Loaded"
+	IssuerStatusReasonFailed = "Failed"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// IssuerList represents a list of issuers
+type IssuerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
+	Items           []Issuer `json:"items" protobuf:"bytes,2,rep,name=items"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CertificateApproval is the decision of an approver on a Certificate request. It has the name of the Certificate,
+// and only applies to the request that it has been taken for, so that approvers only need to be allowed to create
+// CertificateApprovals, and never to write the status of Certificates. It only applies once the approval webhook
+// of the controller has recorded its approver.
+type CertificateApproval struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
+	Spec              CertificateApprovalSpec `json:"spec" protobuf:"bytes,2,req,name=spec"`
+}
+
+// CertificateApprovalSpec is the decision on a Certificate request
+type CertificateApprovalSpec struct {
+	// Decision is either `Approved` or `Denied`
+	Decision CertificateConditionType `json:"decision" protobuf:"bytes,1,opt,name=decision,casttype=CertificateConditionType"`
+	// RequestHash is the `status.requestHash` of the Certificate request that the decision has been taken for.
+	// The decision does not apply to any other request.
+	RequestHash string `json:"requestHash" protobuf:"bytes,2,opt,name=requestHash"`
+	// Reason is a brief reason for the decision
+	Reason string `json:"reason,omitempty" protobuf:"bytes,3,opt,name=reason"`
+	// Message is a human readable message with details about the decision
+	Message string `json:"message,omitempty" protobuf:"bytes,4,opt,name=message"`
+	// Approver is the authenticated user that created or last updated the CertificateApproval. It is set by the
+	// approval webhook of the controller, which replaces any value written by the approver.
+	Approver string `json:"approver,omitempty" protobuf:"bytes,5,opt,name=approver"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CertificateApprovalList represents a list of certificate approvals
+type CertificateApprovalList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
+	Items           []CertificateApproval `json:"items" protobuf:"bytes,2,rep,name=items"`
+}
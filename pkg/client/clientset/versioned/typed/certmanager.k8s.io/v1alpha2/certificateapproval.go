@@ -0,0 +1,144 @@
+//
+// Copyright 2017 Aporeto, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+package v1alpha2
+
+import (
+	v1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+	scheme "github.com/CodingJzy/trireme-csr/pkg/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// CertificateApprovalsGetter has a method to return a CertificateApprovalInterface.
+// A group's client should implement this interface.
+type CertificateApprovalsGetter interface {
+	CertificateApprovals() CertificateApprovalInterface
+}
+
+// CertificateApprovalInterface has methods to work with CertificateApproval resources.
+type CertificateApprovalInterface interface {
+	Create(*v1alpha2.CertificateApproval) (*v1alpha2.CertificateApproval, error)
+	Update(*v1alpha2.CertificateApproval) (*v1alpha2.CertificateApproval, error)
+	Delete(name string, options *v1.DeleteOptions) error
+	DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error
+	Get(name string, options v1.GetOptions) (*v1alpha2.CertificateApproval, error)
+	List(opts v1.ListOptions) (*v1alpha2.CertificateApprovalList, error)
+	Watch(opts v1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha2.CertificateApproval, err error)
+	CertificateApprovalExpansion
+}
+
+// certificateApprovals implements CertificateApprovalInterface
+type certificateApprovals struct {
+	client rest.Interface
+}
+
+// newCertificateApprovals returns a CertificateApprovals
+func newCertificateApprovals(c *CertmanagerV1alpha2Client) *certificateApprovals {
+	return &certificateApprovals{
+		client: c.RESTClient(),
+	}
+}
+
+// Get takes name of the certificateApproval, and returns the corresponding certificateApproval object, and an error if there is any.
+func (c *certificateApprovals) Get(name string, options v1.GetOptions) (result *v1alpha2.CertificateApproval, err error) {
+	result = &v1alpha2.CertificateApproval{}
+	err = c.client.Get().
+		Resource("certificateapprovals").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of CertificateApprovals that match those selectors.
+func (c *certificateApprovals) List(opts v1.ListOptions) (result *v1alpha2.CertificateApprovalList, err error) {
+	result = &v1alpha2.CertificateApprovalList{}
+	err = c.client.Get().
+		Resource("certificateapprovals").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested certificateApprovals.
+func (c *certificateApprovals) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.client.Get().
+		Resource("certificateapprovals").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Watch()
+}
+
+// Create takes the representation of a certificateApproval and creates it.  Returns the server's representation of the certificateApproval, and an error, if there is any.
+func (c *certificateApprovals) Create(certificateApproval *v1alpha2.CertificateApproval) (result *v1alpha2.CertificateApproval, err error) {
+	result = &v1alpha2.CertificateApproval{}
+	err = c.client.Post().
+		Resource("certificateapprovals").
+		Body(certificateApproval).
+		Do().
+		Into(result)
+	return
+}
+
+// Update takes the representation of a certificateApproval and updates it. Returns the server's representation of the certificateApproval, and an error, if there is any.
+func (c *certificateApprovals) Update(certificateApproval *v1alpha2.CertificateApproval) (result *v1alpha2.CertificateApproval, err error) {
+	result = &v1alpha2.CertificateApproval{}
+	err = c.client.Put().
+		Resource("certificateapprovals").
+		Name(certificateApproval.Name).
+		Body(certificateApproval).
+		Do().
+		Into(result)
+	return
+}
+
+// Delete takes name of the certificateApproval and deletes it. Returns an error if one occurs.
+func (c *certificateApprovals) Delete(name string, options *v1.DeleteOptions) error {
+	return c.client.Delete().
+		Resource("certificateapprovals").
+		Name(name).
+		Body(options).
+		Do().
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *certificateApprovals) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	return c.client.Delete().
+		Resource("certificateapprovals").
+		VersionedParams(&listOptions, scheme.ParameterCodec).
+		Body(options).
+		Do().
+		Error()
+}
+
+// Patch applies the patch and returns the patched certificateApproval.
+func (c *certificateApprovals) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha2.CertificateApproval, err error) {
+	result = &v1alpha2.CertificateApproval{}
+	err = c.client.Patch(pt).
+		Resource("certificateapprovals").
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do().
+		Into(result)
+	return
+}
@@ -25,6 +25,8 @@ import (
 type CertmanagerV1alpha2Interface interface {
 	RESTClient() rest.Interface
 	CertificatesGetter
+	CertificateApprovalsGetter
+	IssuersGetter
 }
 
 // CertmanagerV1alpha2Client is used to interact with features provided by the certmanager.k8s.io group.
@@ -36,6 +38,14 @@ func (c *CertmanagerV1alpha2Client) Certificates() CertificateInterface {
 	return newCertificates(c)
 }
 
+func (c *CertmanagerV1alpha2Client) CertificateApprovals() CertificateApprovalInterface {
+	return newCertificateApprovals(c)
+}
+
+func (c *CertmanagerV1alpha2Client) Issuers() IssuerInterface {
+	return newIssuers(c)
+}
+
 // NewForConfig creates a new CertmanagerV1alpha2Client for the given config.
 func NewForConfig(c *rest.Config) (*CertmanagerV1alpha2Client, error) {
 	config := *c
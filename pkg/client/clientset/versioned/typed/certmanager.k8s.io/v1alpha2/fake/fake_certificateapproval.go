@@ -0,0 +1,117 @@
+//
+// Copyright 2017 Aporeto, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+package fake
+
+import (
+	v1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeCertificateApprovals implements CertificateApprovalInterface
+type FakeCertificateApprovals struct {
+	Fake *FakeCertmanagerV1alpha2
+}
+
+var certificateapprovalsResource = schema.GroupVersionResource{Group: "certmanager.k8s.io", Version: "v1alpha2", Resource: "certificateapprovals"}
+
+var certificateapprovalsKind = schema.GroupVersionKind{Group: "certmanager.k8s.io", Version: "v1alpha2", Kind: "CertificateApproval"}
+
+// Get takes name of the certificateApproval, and returns the corresponding certificateApproval object, and an error if there is any.
+func (c *FakeCertificateApprovals) Get(name string, options v1.GetOptions) (result *v1alpha2.CertificateApproval, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootGetAction(certificateapprovalsResource, name), &v1alpha2.CertificateApproval{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha2.CertificateApproval), err
+}
+
+// List takes label and field selectors, and returns the list of CertificateApprovals that match those selectors.
+func (c *FakeCertificateApprovals) List(opts v1.ListOptions) (result *v1alpha2.CertificateApprovalList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootListAction(certificateapprovalsResource, certificateapprovalsKind, opts), &v1alpha2.CertificateApprovalList{})
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha2.CertificateApprovalList{}
+	for _, item := range obj.(*v1alpha2.CertificateApprovalList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested certificateApprovals.
+func (c *FakeCertificateApprovals) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewRootWatchAction(certificateapprovalsResource, opts))
+}
+
+// Create takes the representation of a certificateApproval and creates it.  Returns the server's representation of the certificateApproval, and an error, if there is any.
+func (c *FakeCertificateApprovals) Create(certificateApproval *v1alpha2.CertificateApproval) (result *v1alpha2.CertificateApproval, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootCreateAction(certificateapprovalsResource, certificateApproval), &v1alpha2.CertificateApproval{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha2.CertificateApproval), err
+}
+
+// Update takes the representation of a certificateApproval and updates it. Returns the server's representation of the certificateApproval, and an error, if there is any.
+func (c *FakeCertificateApprovals) Update(certificateApproval *v1alpha2.CertificateApproval) (result *v1alpha2.CertificateApproval, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateAction(certificateapprovalsResource, certificateApproval), &v1alpha2.CertificateApproval{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha2.CertificateApproval), err
+}
+
+// Delete takes name of the certificateApproval and deletes it. Returns an error if one occurs.
+func (c *FakeCertificateApprovals) Delete(name string, options *v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewRootDeleteAction(certificateapprovalsResource, name), &v1alpha2.CertificateApproval{})
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeCertificateApprovals) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	action := testing.NewRootDeleteCollectionAction(certificateapprovalsResource, listOptions)
+
+	_, err := c.Fake.Invokes(action, &v1alpha2.CertificateApprovalList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched certificateApproval.
+func (c *FakeCertificateApprovals) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha2.CertificateApproval, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootPatchSubresourceAction(certificateapprovalsResource, name, data, subresources...), &v1alpha2.CertificateApproval{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha2.CertificateApproval), err
+}
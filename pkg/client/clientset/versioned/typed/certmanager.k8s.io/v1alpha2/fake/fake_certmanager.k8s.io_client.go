@@ -29,6 +29,14 @@ func (c *FakeCertmanagerV1alpha2) Certificates() v1alpha2.CertificateInterface {
 	return &FakeCertificates{c}
 }
 
+func (c *FakeCertmanagerV1alpha2) CertificateApprovals() v1alpha2.CertificateApprovalInterface {
+	return &FakeCertificateApprovals{c}
+}
+
+func (c *FakeCertmanagerV1alpha2) Issuers() v1alpha2.IssuerInterface {
+	return &FakeIssuers{c}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeCertmanagerV1alpha2) RESTClient() rest.Interface {
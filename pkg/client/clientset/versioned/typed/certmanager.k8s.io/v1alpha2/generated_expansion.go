@@ -16,3 +16,7 @@
 package v1alpha2
 
 type CertificateExpansion interface{}
+
+type CertificateApprovalExpansion interface{}
+
+type IssuerExpansion interface{}
@@ -26,6 +26,10 @@ import (
 type Interface interface {
 	// Certificates returns a CertificateInformer.
 	Certificates() CertificateInformer
+	// CertificateApprovals returns a CertificateApprovalInformer.
+	CertificateApprovals() CertificateApprovalInformer
+	// Issuers returns a IssuerInformer.
+	Issuers() IssuerInformer
 }
 
 type version struct {
@@ -43,3 +47,13 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 func (v *version) Certificates() CertificateInformer {
 	return &certificateInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
 }
+
+// CertificateApprovals returns a CertificateApprovalInformer.
+func (v *version) CertificateApprovals() CertificateApprovalInformer {
+	return &certificateApprovalInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// Issuers returns a IssuerInformer.
+func (v *version) Issuers() IssuerInformer {
+	return &issuerInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
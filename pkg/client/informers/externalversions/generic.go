@@ -60,6 +60,10 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 		// Group=certmanager.k8s.io, Version=v1alpha2
 	case v1alpha2.SchemeGroupVersion.WithResource("certificates"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Certmanager().V1alpha2().Certificates().Informer()}, nil
+	case v1alpha2.SchemeGroupVersion.WithResource("certificateapprovals"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Certmanager().V1alpha2().CertificateApprovals().Informer()}, nil
+	case v1alpha2.SchemeGroupVersion.WithResource("issuers"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Certmanager().V1alpha2().Issuers().Informer()}, nil
 
 	}
 
@@ -0,0 +1,65 @@
+//
+// Copyright 2017 Aporeto, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// This file was automatically generated by lister-gen
+
+package v1alpha2
+
+import (
+	v1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// CertificateApprovalLister helps list CertificateApprovals.
+type CertificateApprovalLister interface {
+	// List lists all CertificateApprovals in the indexer.
+	List(selector labels.Selector) (ret []*v1alpha2.CertificateApproval, err error)
+	// Get retrieves the CertificateApproval from the index for a given name.
+	Get(name string) (*v1alpha2.CertificateApproval, error)
+	CertificateApprovalListerExpansion
+}
+
+// certificateApprovalLister implements the CertificateApprovalLister interface.
+type certificateApprovalLister struct {
+	indexer cache.Indexer
+}
+
+// NewCertificateApprovalLister returns a new CertificateApprovalLister.
+func NewCertificateApprovalLister(indexer cache.Indexer) CertificateApprovalLister {
+	return &certificateApprovalLister{indexer: indexer}
+}
+
+// List lists all CertificateApprovals in the indexer.
+func (s *certificateApprovalLister) List(selector labels.Selector) (ret []*v1alpha2.CertificateApproval, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha2.CertificateApproval))
+	})
+	return ret, err
+}
+
+// Get retrieves the CertificateApproval from the index for a given name.
+func (s *certificateApprovalLister) Get(name string) (*v1alpha2.CertificateApproval, error) {
+	obj, exists, err := s.indexer.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha2.Resource("certificateapproval"), name)
+	}
+	return obj.(*v1alpha2.CertificateApproval), nil
+}
@@ -21,3 +21,11 @@ package v1alpha2
 // CertificateListerExpansion allows custom methods to be added to
 // CertificateLister.
 type CertificateListerExpansion interface{}
+
+// CertificateApprovalListerExpansion allows custom methods to be added to
+// CertificateApprovalLister.
+type CertificateApprovalListerExpansion interface{}
+
+// IssuerListerExpansion allows custom methods to be added to
+// IssuerLister.
+type IssuerListerExpansion interface{}
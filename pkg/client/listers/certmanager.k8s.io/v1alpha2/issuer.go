@@ -0,0 +1,65 @@
+//
+// Copyright 2017 Aporeto, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// This file was automatically generated by lister-gen
+
+package v1alpha2
+
+import (
+	v1alpha2 "github.com/CodingJzy/trireme-csr/pkg/apis/certmanager.k8s.io/v1alpha2"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// IssuerLister helps list Issuers.
+type IssuerLister interface {
+	// List lists all Issuers in the indexer.
+	List(selector labels.Selector) (ret []*v1alpha2.Issuer, err error)
+	// Get retrieves the Issuer from the index for a given name.
+	Get(name string) (*v1alpha2.Issuer, error)
+	IssuerListerExpansion
+}
+
+// issuerLister implements the IssuerLister interface.
+type issuerLister struct {
+	indexer cache.Indexer
+}
+
+// NewIssuerLister returns a new IssuerLister.
+func NewIssuerLister(indexer cache.Indexer) IssuerLister {
+	return &issuerLister{indexer: indexer}
+}
+
+// List lists all Issuers in the indexer.
+func (s *issuerLister) List(selector labels.Selector) (ret []*v1alpha2.Issuer, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha2.Issuer))
+	})
+	return ret, err
+}
+
+// Get retrieves the Issuer from the index for a given name.
+func (s *issuerLister) Get(name string) (*v1alpha2.Issuer, error) {
+	obj, exists, err := s.indexer.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha2.Resource("issuer"), name)
+	}
+	return obj.(*v1alpha2.Issuer), nil
+}
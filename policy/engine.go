@@ -0,0 +1,151 @@
+package policy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"sigs.k8s.io/yaml"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Engine validates CSRs against the currently loaded policy. The policy can be replaced at any time.
+type Engine struct {
+	sync.RWMutex
+
+	policy *compiledPolicy
+	hash   []byte
+}
+
+// NewEngine creates an Engine with an empty policy, which allows every CSR but CA certificates and the RestrictedUsages
+func NewEngine() *Engine {
+	return &Engine{
+		policy: &compiledPolicy{
+			Policy: &Policy{},
+		},
+	}
+}
+
+// Load parses the YAML or JSON policy in `data` and replaces the current policy with it.
+// If the policy is invalid, the current policy is kept and an error is returned.
+func (e *Engine) Load(data []byte) error {
+	hash := sha256.Sum256(data)
+
+	e.RLock()
+	unchanged := bytes.Equal(e.hash, hash[:])
+	e.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	p := &Policy{}
+	if err := yaml.UnmarshalStrict(data, p); err != nil {
+		return fmt.Errorf("failed to parse policy: %s", err)
+	}
+	compiled, err := p.compile()
+	if err != nil {
+		return fmt.Errorf("invalid policy: %s", err)
+	}
+
+	e.Lock()
+	e.policy = compiled
+	e.hash = hash[:]
+	e.Unlock()
+
+	zap.L().Info("Issuance policy loaded", zap.String("sha256", fmt.Sprintf("%x", hash)))
+	return nil
+}
+
+// Validate returns a RuleError naming the failed rule if the CSR, the usages, a CA certificate or the
+// requested duration, which is 0 if none has been requested, are not allowed by the current policy
+func (e *Engine) Validate(csr *x509.CertificateRequest, usages []string, isCA bool, duration time.Duration) error {
+	e.RLock()
+	p := e.policy
+	e.RUnlock()
+
+	return p.validate(csr, usages, isCA, duration)
+}
+
+// LoadFile loads the policy from the file at `path`
+func (e *Engine) LoadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read policy file: %s", err)
+	}
+	return e.Load(data)
+}
+
+// WatchFile reloads the policy from the file at `path` every `interval` until stopCh closes.
+// Errors are logged and the last valid policy stays active.
+func (e *Engine) WatchFile(path string, interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		if err := e.LoadFile(path); err != nil {
+			zap.L().Error("Error reloading issuance policy", zap.String("file", path), zap.Error(err))
+		}
+	}
+}
+
+// WatchConfigMap loads the policy from `key` of the ConfigMap `namespace/name` whenever it changes, until stopCh closes.
+// Errors are logged and the last valid policy stays active, also when the ConfigMap gets deleted.
+func (e *Engine) WatchConfigMap(kubeClient kubernetes.Interface, namespace, name, key string, stopCh <-chan struct{}) {
+	// the typed client is used instead of its REST client, which fake clientsets do not provide
+	configMaps := kubeClient.CoreV1().ConfigMaps(namespace)
+	fieldSelector := fields.OneTermEqualSelector("metadata.name", name).String()
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = fieldSelector
+			return configMaps.List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = fieldSelector
+			return configMaps.Watch(options)
+		},
+	}
+
+	load := func(obj interface{}) {
+		configMap, ok := obj.(*corev1.ConfigMap)
+		if !ok {
+			return
+		}
+		data, ok := configMap.Data[key]
+		if !ok {
+			zap.L().Error("Issuance policy ConfigMap is missing its key", zap.String("configmap", namespace+"/"+name), zap.String("key", key))
+			return
+		}
+		if err := e.Load([]byte(data)); err != nil {
+			zap.L().Error("Error reloading issuance policy", zap.String("configmap", namespace+"/"+name), zap.Error(err))
+		}
+	}
+
+	_, informer := cache.NewInformer(listWatch, &corev1.ConfigMap{}, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc: load,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			load(newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			zap.L().Warn("Issuance policy ConfigMap deleted, keeping the last policy", zap.String("configmap", namespace+"/"+name))
+		},
+	})
+	informer.Run(stopCh)
+}
@@ -0,0 +1,190 @@
+package policy
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	nodePolicy   = "commonNamePatterns: [node]\n"
+	workerPolicy = "commonNamePatterns: [worker]\n"
+)
+
+// allows returns true if the engine allows a CSR for the common name
+func allows(t *testing.T, engine *Engine, commonName string) bool {
+	t.Helper()
+
+	csr := newTestCSR(t, "p256", &x509.CertificateRequest{Subject: pkix.Name{CommonName: commonName}})
+	return engine.Validate(csr, nil, false, 0) == nil
+}
+
+// waitFor polls the condition until it is true, and fails the test after a few seconds
+func waitFor(t *testing.T, description string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", description)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEngineLoad(t *testing.T) {
+	engine := newTestEngine(t, nodePolicy)
+	loaded := engine.policy
+
+	// loading the same policy again keeps the compiled policy
+	if err := engine.Load([]byte(nodePolicy)); err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+	if engine.policy != loaded {
+		t.Errorf("Load() replaced the policy with an unchanged one")
+	}
+
+	// an invalid policy keeps the previous one
+	for _, invalid := range []string{"commonNamePatterns: [", "commonNamePatterns: ['(']", "unknownRule: true"} {
+		if err := engine.Load([]byte(invalid)); err == nil {
+			t.Errorf("Load() succeeded with the invalid policy %q", invalid)
+		}
+		if engine.policy != loaded || !allows(t, engine, "node") || allows(t, engine, "worker") {
+			t.Errorf("invalid policy %q replaced the previous policy", invalid)
+		}
+	}
+
+	if err := engine.Load([]byte(workerPolicy)); err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+	if allows(t, engine, "node") || !allows(t, engine, "worker") {
+		t.Errorf("Load() did not replace the policy")
+	}
+
+	// the previous policy can be loaded again after another one
+	if err := engine.Load([]byte(nodePolicy)); err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+	if !allows(t, engine, "node") {
+		t.Errorf("Load() did not restore the previous policy")
+	}
+}
+
+func TestEngineWatchFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policy")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "policy.yaml")
+	writePolicy := func(policy string) {
+		if err := ioutil.WriteFile(path, []byte(policy), 0600); err != nil {
+			t.Fatalf("failed to write policy file: %s", err)
+		}
+	}
+
+	writePolicy(nodePolicy)
+	engine := NewEngine()
+	if err := engine.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile() error = %s", err)
+	}
+	if err := engine.LoadFile(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Errorf("LoadFile() succeeded with a missing file")
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go engine.WatchFile(path, 10*time.Millisecond, stopCh)
+
+	// a bad file keeps the previous policy
+	writePolicy("commonNamePatterns: [")
+	time.Sleep(100 * time.Millisecond)
+	if !allows(t, engine, "node") || allows(t, engine, "worker") {
+		t.Errorf("invalid policy file replaced the previous policy")
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("failed to remove policy file: %s", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if !allows(t, engine, "node") {
+		t.Errorf("removed policy file replaced the previous policy")
+	}
+
+	writePolicy(workerPolicy)
+	waitFor(t, "the policy file to be reloaded", func() bool {
+		return allows(t, engine, "worker")
+	})
+	if allows(t, engine, "node") {
+		t.Errorf("reloaded policy still allows the previous common name")
+	}
+}
+
+func TestEngineWatchConfigMap(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "policy"},
+		Data:       map[string]string{"policy.yaml": nodePolicy},
+	}
+	kubeClient := kubefake.NewSimpleClientset(configMap)
+	configMaps := kubeClient.CoreV1().ConfigMaps("default")
+	update := func(data map[string]string) {
+		updated := configMap.DeepCopy()
+		updated.Data = data
+		if _, err := configMaps.Update(updated); err != nil {
+			t.Fatalf("failed to update ConfigMap: %s", err)
+		}
+	}
+
+	engine := NewEngine()
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go engine.WatchConfigMap(kubeClient, "default", "policy", "policy.yaml", stopCh)
+
+	waitFor(t, "the policy ConfigMap to be loaded", func() bool {
+		return !allows(t, engine, "worker")
+	})
+	loaded := func() *compiledPolicy {
+		engine.RLock()
+		defer engine.RUnlock()
+		return engine.policy
+	}()
+
+	// a bad policy or a missing key keeps the previous policy
+	update(map[string]string{"policy.yaml": "commonNamePatterns: ["})
+	update(map[string]string{"other.yaml": workerPolicy})
+	time.Sleep(100 * time.Millisecond)
+	if !allows(t, engine, "node") || allows(t, engine, "worker") {
+		t.Errorf("invalid policy ConfigMap replaced the previous policy")
+	}
+
+	// the same policy does not get reloaded
+	update(map[string]string{"policy.yaml": nodePolicy, "comment": "unrelated change"})
+	time.Sleep(100 * time.Millisecond)
+	engine.RLock()
+	unchanged := engine.policy == loaded
+	engine.RUnlock()
+	if !unchanged {
+		t.Errorf("unchanged policy in the ConfigMap replaced the compiled policy")
+	}
+
+	update(map[string]string{"policy.yaml": workerPolicy})
+	waitFor(t, "the policy ConfigMap to be reloaded", func() bool {
+		return allows(t, engine, "worker")
+	})
+
+	// deleting the ConfigMap keeps the last policy
+	if err := configMaps.Delete("policy", &metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("failed to delete ConfigMap: %s", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if !allows(t, engine, "worker") || allows(t, engine, "node") {
+		t.Errorf("deleted policy ConfigMap replaced the last policy")
+	}
+}
@@ -0,0 +1,155 @@
+package policy
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Policy constrains which CSRs are allowed to be issued. Every empty field allows anything.
+type Policy struct {
+	// AllowedSubjectFields lists the subject fields that a CSR may contain, e.g. `commonName` or `organization`.
+	// Attributes without a name are referred to by their OID.
+	AllowedSubjectFields []string `json:"allowedSubjectFields,omitempty"`
+	// CommonNamePatterns are regular expressions, one of which the whole common name must match
+	CommonNamePatterns []string `json:"commonNamePatterns,omitempty"`
+
+	// DNSNames constrains the DNS SANs
+	DNSNames *SANPolicy `json:"dnsNames,omitempty"`
+	// IPAddresses constrains the IP SANs, whose patterns are CIDRs
+	IPAddresses *SANPolicy `json:"ipAddresses,omitempty"`
+	// URIs constrains the URI SANs
+	URIs *SANPolicy `json:"uris,omitempty"`
+	// EmailAddresses constrains the email SANs
+	EmailAddresses *SANPolicy `json:"emailAddresses,omitempty"`
+
+	// KeyAlgorithms lists the allowed public key algorithms: `RSA`, `ECDSA` or `Ed25519`
+	KeyAlgorithms []string `json:"keyAlgorithms,omitempty"`
+	// Curves lists the allowed curves of ECDSA keys: `P-256`, `P-384` or `P-521`
+	Curves []string `json:"curves,omitempty"`
+	// MinRSAKeySize is the minimum size in bits of RSA keys
+	MinRSAKeySize int `json:"minRSAKeySize,omitempty"`
+	// SignatureAlgorithms lists the allowed signature algorithms of the CSR, e.g. `ECDSA-SHA256`
+	SignatureAlgorithms []string `json:"signatureAlgorithms,omitempty"`
+
+	// AllowedUsages lists the key usages that a certificate may be issued with, e.g. `client auth`.
+	// The RestrictedUsages are denied unless listed here, even if the list is empty.
+	AllowedUsages []string `json:"allowedUsages,omitempty"`
+	// AllowCA allows to issue CA certificates. Unlike all other fields, CA certificates are denied if unset.
+	AllowCA bool `json:"allowCA,omitempty"`
+
+	// MaxDuration is the maximum validity that a certificate may be requested with, e.g. `720h`. Requests without
+	// a duration get the default validity of their issuer.
+	MaxDuration metav1.Duration `json:"maxDuration,omitempty"`
+}
+
+// RestrictedUsages let a certificate sign certificates, CRLs or OCSP responses, which clients trust as if they came
+// from the CA. They must always be allowed explicitly, except `cert sign` and `crl sign` for CA certificates.
+var RestrictedUsages = []string{"cert sign", "crl sign", "ocsp signing"}
+
+// caUsages are the restricted usages that CA certificates are allowed with through AllowCA
+var caUsages = []string{"cert sign", "crl sign"}
+
+// SANPolicy constrains one type of Subject Alternative Names
+type SANPolicy struct {
+	// Forbidden rejects any SAN of this type
+	Forbidden bool `json:"forbidden,omitempty"`
+	// Patterns are regular expressions (CIDRs for IP addresses), one of which every whole SAN must match
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+// compiledPolicy is a Policy with all its patterns parsed
+type compiledPolicy struct {
+	*Policy
+
+	commonNamePatterns []*regexp.Regexp
+	dnsNames           *compiledSANPolicy
+	ipAddresses        *compiledSANPolicy
+	uris               *compiledSANPolicy
+	emailAddresses     *compiledSANPolicy
+}
+
+// compiledSANPolicy is a SANPolicy with all its patterns parsed
+type compiledSANPolicy struct {
+	forbidden bool
+	patterns  []*regexp.Regexp
+	networks  []*net.IPNet
+}
+
+// compile parses all patterns of the policy
+func (p *Policy) compile() (*compiledPolicy, error) {
+	var err error
+
+	compiled := &compiledPolicy{
+		Policy: p,
+	}
+
+	compiled.commonNamePatterns, err = compilePatterns(p.CommonNamePatterns)
+	if err != nil {
+		return nil, fmt.Errorf("commonNamePatterns: %s", err)
+	}
+	if compiled.dnsNames, err = p.DNSNames.compile(false); err != nil {
+		return nil, fmt.Errorf("dnsNames: %s", err)
+	}
+	if compiled.ipAddresses, err = p.IPAddresses.compile(true); err != nil {
+		return nil, fmt.Errorf("ipAddresses: %s", err)
+	}
+	if compiled.uris, err = p.URIs.compile(false); err != nil {
+		return nil, fmt.Errorf("uris: %s", err)
+	}
+	if compiled.emailAddresses, err = p.EmailAddresses.compile(false); err != nil {
+		return nil, fmt.Errorf("emailAddresses: %s", err)
+	}
+	if p.MinRSAKeySize < 0 {
+		return nil, fmt.Errorf("minRSAKeySize: must not be negative")
+	}
+	if p.MaxDuration.Duration < 0 {
+		return nil, fmt.Errorf("maxDuration: must not be negative")
+	}
+
+	return compiled, nil
+}
+
+// compile parses the patterns of the SAN policy, as CIDRs if `cidr` is set
+func (p *SANPolicy) compile(cidr bool) (*compiledSANPolicy, error) {
+	if p == nil {
+		return nil, nil
+	}
+
+	compiled := &compiledSANPolicy{
+		forbidden: p.Forbidden,
+	}
+
+	if !cidr {
+		patterns, err := compilePatterns(p.Patterns)
+		if err != nil {
+			return nil, err
+		}
+		compiled.patterns = patterns
+		return compiled, nil
+	}
+
+	for _, pattern := range p.Patterns {
+		_, network, err := net.ParseCIDR(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR '%s': %s", pattern, err)
+		}
+		compiled.networks = append(compiled.networks, network)
+	}
+	return compiled, nil
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		// patterns always have to match the whole value
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %s", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
@@ -0,0 +1,203 @@
+package policy
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"net"
+	"time"
+)
+
+// RuleError is returned when a CSR violates a rule of the policy
+type RuleError struct {
+	Rule    string
+	Message string
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("policy rule '%s' failed: %s", e.Rule, e.Message)
+}
+
+func ruleError(rule string, format string, args ...interface{}) error {
+	return &RuleError{
+		Rule:    rule,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// subjectFieldNames maps the OIDs of the subject attributes to the names used in the policy
+var subjectFieldNames = map[string]string{
+	"2.5.4.3":  "commonName",
+	"2.5.4.5":  "serialNumber",
+	"2.5.4.6":  "country",
+	"2.5.4.7":  "locality",
+	"2.5.4.8":  "province",
+	"2.5.4.9":  "streetAddress",
+	"2.5.4.10": "organization",
+	"2.5.4.11": "organizationalUnit",
+	"2.5.4.17": "postalCode",
+}
+
+// validate checks the CSR, the requested usages and the requested duration against all rules of the policy, and
+// returns a RuleError for the first failing rule
+func (p *compiledPolicy) validate(csr *x509.CertificateRequest, usages []string, isCA bool, duration time.Duration) error {
+	if err := p.validateSubject(csr); err != nil {
+		return err
+	}
+	if err := p.dnsNames.validate("dnsNames", csr.DNSNames); err != nil {
+		return err
+	}
+	if err := p.ipAddresses.validateIPs("ipAddresses", csr.IPAddresses); err != nil {
+		return err
+	}
+	uris := make([]string, 0, len(csr.URIs))
+	for _, uri := range csr.URIs {
+		uris = append(uris, uri.String())
+	}
+	if err := p.uris.validate("uris", uris); err != nil {
+		return err
+	}
+	if err := p.emailAddresses.validate("emailAddresses", csr.EmailAddresses); err != nil {
+		return err
+	}
+	if err := p.validateKey(csr); err != nil {
+		return err
+	}
+	if len(p.SignatureAlgorithms) > 0 && !contains(p.SignatureAlgorithms, csr.SignatureAlgorithm.String()) {
+		return ruleError("signatureAlgorithms", "signature algorithm '%s' is not allowed", csr.SignatureAlgorithm)
+	}
+	if len(p.AllowedUsages) > 0 {
+		for _, usage := range usages {
+			if !contains(p.AllowedUsages, usage) {
+				return ruleError("allowedUsages", "usage '%s' is not allowed", usage)
+			}
+		}
+	}
+	for _, usage := range usages {
+		if contains(RestrictedUsages, usage) && !contains(p.AllowedUsages, usage) && !(isCA && contains(caUsages, usage)) {
+			return ruleError("allowedUsages", "usage '%s' must be allowed explicitly", usage)
+		}
+	}
+	if isCA && !p.AllowCA {
+		return ruleError("allowCA", "CA certificates are not allowed")
+	}
+	if p.MaxDuration.Duration > 0 && duration > p.MaxDuration.Duration {
+		return ruleError("maxDuration", "duration %s is longer than %s", duration, p.MaxDuration.Duration)
+	}
+	return nil
+}
+
+// validateSubject checks the subject fields and the common name
+func (p *compiledPolicy) validateSubject(csr *x509.CertificateRequest) error {
+	if len(p.AllowedSubjectFields) > 0 {
+		for _, attribute := range csr.Subject.Names {
+			name := subjectFieldName(attribute.Type)
+			if !contains(p.AllowedSubjectFields, name) {
+				return ruleError("allowedSubjectFields", "subject field '%s' is not allowed", name)
+			}
+		}
+	}
+
+	if len(p.commonNamePatterns) > 0 {
+		for _, re := range p.commonNamePatterns {
+			if re.MatchString(csr.Subject.CommonName) {
+				return nil
+			}
+		}
+		return ruleError("commonNamePatterns", "common name '%s' does not match any allowed pattern", csr.Subject.CommonName)
+	}
+	return nil
+}
+
+// validateKey checks the public key algorithm, the curve and the key size
+func (p *compiledPolicy) validateKey(csr *x509.CertificateRequest) error {
+	algorithm := csr.PublicKeyAlgorithm.String()
+	if len(p.KeyAlgorithms) > 0 && !contains(p.KeyAlgorithms, algorithm) {
+		return ruleError("keyAlgorithms", "key algorithm '%s' is not allowed", algorithm)
+	}
+
+	switch key := csr.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		curve := key.Curve.Params().Name
+		if len(p.Curves) > 0 && !contains(p.Curves, curve) {
+			return ruleError("curves", "curve '%s' is not allowed", curve)
+		}
+	case *rsa.PublicKey:
+		if size := key.N.BitLen(); size < p.MinRSAKeySize {
+			return ruleError("minRSAKeySize", "RSA key size %d is smaller than %d", size, p.MinRSAKeySize)
+		}
+	}
+	return nil
+}
+
+// validate checks that every SAN value matches one of the patterns
+func (p *compiledSANPolicy) validate(rule string, values []string) error {
+	if p == nil || len(values) == 0 {
+		return nil
+	}
+	if p.forbidden {
+		return ruleError(rule, "SANs of this type are not allowed")
+	}
+	if len(p.patterns) == 0 {
+		return nil
+	}
+
+	for _, value := range values {
+		matched := false
+		for _, re := range p.patterns {
+			if re.MatchString(value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return ruleError(rule, "'%s' does not match any allowed pattern", value)
+		}
+	}
+	return nil
+}
+
+// validateIPs checks that every IP SAN is in one of the networks
+func (p *compiledSANPolicy) validateIPs(rule string, ips []net.IP) error {
+	if p == nil || len(ips) == 0 {
+		return nil
+	}
+	if p.forbidden {
+		return ruleError(rule, "SANs of this type are not allowed")
+	}
+	if len(p.networks) == 0 {
+		return nil
+	}
+
+	for _, ip := range ips {
+		matched := false
+		for _, network := range p.networks {
+			if network.Contains(ip) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return ruleError(rule, "'%s' is not in any allowed network", ip)
+		}
+	}
+	return nil
+}
+
+func subjectFieldName(oid asn1.ObjectIdentifier) string {
+	if name, ok := subjectFieldNames[oid.String()]; ok {
+		return name
+	}
+	return oid.String()
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,272 @@
+package policy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// testKeys are generated once, as RSA keys are slow to generate
+var testKeys = map[string]crypto.Signer{}
+
+// newTestKey returns a private key of the given type: "p256", "p384", "rsa2048" or "ed25519"
+func newTestKey(t *testing.T, keyType string) crypto.Signer {
+	t.Helper()
+
+	if key, ok := testKeys[keyType]; ok {
+		return key
+	}
+	var key crypto.Signer
+	var err error
+	switch keyType {
+	case "p256":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "p384":
+		key, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "rsa2048":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ed25519":
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		t.Fatalf("unknown key type '%s'", keyType)
+	}
+	if err != nil {
+		t.Fatalf("failed to generate %s key: %s", keyType, err)
+	}
+	testKeys[keyType] = key
+	return key
+}
+
+// newTestCSR creates a CSR from the template, signed by a key of the given type
+func newTestCSR(t *testing.T, keyType string, template *x509.CertificateRequest) *x509.CertificateRequest {
+	t.Helper()
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, newTestKey(t, keyType))
+	if err != nil {
+		t.Fatalf("failed to create CSR: %s", err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatalf("failed to parse CSR: %s", err)
+	}
+	return csr
+}
+
+// newTestEngine returns an engine with the YAML policy loaded
+func newTestEngine(t *testing.T, policy string) *Engine {
+	t.Helper()
+
+	engine := NewEngine()
+	if err := engine.Load([]byte(policy)); err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+	return engine
+}
+
+// ruleOf returns the rule of the RuleError, or an empty string if the error is nil
+func ruleOf(t *testing.T, err error) string {
+	t.Helper()
+
+	if err == nil {
+		return ""
+	}
+	ruleErr, ok := err.(*RuleError)
+	if !ok {
+		t.Fatalf("error is not a RuleError: %s", err)
+	}
+	return ruleErr.Rule
+}
+
+// validateCase is a request that gets validated against a policy, and the rule that must reject it
+type validateCase struct {
+	name     string
+	keyType  string
+	csr      *x509.CertificateRequest
+	usages   []string
+	isCA     bool
+	duration time.Duration
+	wantRule string
+}
+
+// runValidateCases validates every case against the policy, and checks the rule that rejected it
+func runValidateCases(t *testing.T, policy string, tests []validateCase) {
+	t.Helper()
+
+	engine := newTestEngine(t, policy)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyType := tt.keyType
+			if keyType == "" {
+				keyType = "p256"
+			}
+			csr := newTestCSR(t, keyType, tt.csr)
+			if got := ruleOf(t, engine.Validate(csr, tt.usages, tt.isCA, tt.duration)); got != tt.wantRule {
+				t.Errorf("Validate() failed rule = '%s', want '%s'", got, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestValidateCommonName(t *testing.T) {
+	runValidateCases(t, `
+commonNamePatterns:
+- node-[0-9]+
+- worker|agent
+`, []validateCase{
+		{name: "matching common name", csr: &x509.CertificateRequest{Subject: pkix.Name{CommonName: "node-1"}}},
+		{name: "matching alternative", csr: &x509.CertificateRequest{Subject: pkix.Name{CommonName: "agent"}}},
+		{name: "prefix", csr: &x509.CertificateRequest{Subject: pkix.Name{CommonName: "evil-node-1"}}, wantRule: "commonNamePatterns"},
+		{name: "suffix", csr: &x509.CertificateRequest{Subject: pkix.Name{CommonName: "node-1.evil"}}, wantRule: "commonNamePatterns"},
+		{name: "suffix of an alternative", csr: &x509.CertificateRequest{Subject: pkix.Name{CommonName: "worker-evil"}}, wantRule: "commonNamePatterns"},
+		{name: "prefix of an alternative", csr: &x509.CertificateRequest{Subject: pkix.Name{CommonName: "evil-agent"}}, wantRule: "commonNamePatterns"},
+		{name: "empty common name", csr: &x509.CertificateRequest{}, wantRule: "commonNamePatterns"},
+	})
+}
+
+func TestValidateSubjectFields(t *testing.T) {
+	runValidateCases(t, `
+allowedSubjectFields: [commonName, organizationalUnit]
+`, []validateCase{
+		{name: "allowed fields", csr: &x509.CertificateRequest{Subject: pkix.Name{CommonName: "node", OrganizationalUnit: []string{"nodes"}}}},
+		{name: "organization", csr: &x509.CertificateRequest{Subject: pkix.Name{CommonName: "node", Organization: []string{"system:masters"}}}, wantRule: "allowedSubjectFields"},
+	})
+}
+
+func TestValidateSANs(t *testing.T) {
+	runValidateCases(t, `
+dnsNames:
+  patterns: ['[a-z0-9-]+\.example\.com']
+ipAddresses:
+  patterns: [10.0.0.0/8, 'fd00::/8']
+uris:
+  patterns: ['spiffe://example\.com/.*']
+emailAddresses:
+  forbidden: true
+`, []validateCase{
+		{
+			name: "allowed SANs",
+			csr: &x509.CertificateRequest{
+				DNSNames:    []string{"node-1.example.com", "node-2.example.com"},
+				IPAddresses: []net.IP{net.ParseIP("10.1.2.3"), net.ParseIP("fd00::1")},
+				URIs:        []*url.URL{{Scheme: "spiffe", Host: "example.com", Path: "/node"}},
+			},
+		},
+		{name: "DNS name with a suffix", csr: &x509.CertificateRequest{DNSNames: []string{"node.example.com.evil.org"}}, wantRule: "dnsNames"},
+		{name: "DNS name with a prefix", csr: &x509.CertificateRequest{DNSNames: []string{"evil.org.example.com"}}, wantRule: "dnsNames"},
+		{name: "one DNS name not allowed", csr: &x509.CertificateRequest{DNSNames: []string{"node.example.com", "node.example.org"}}, wantRule: "dnsNames"},
+		{name: "IP outside the networks", csr: &x509.CertificateRequest{IPAddresses: []net.IP{net.ParseIP("192.168.1.1")}}, wantRule: "ipAddresses"},
+		{name: "IPv6 outside the networks", csr: &x509.CertificateRequest{IPAddresses: []net.IP{net.ParseIP("2001:db8::1")}}, wantRule: "ipAddresses"},
+		{name: "URI of another domain", csr: &x509.CertificateRequest{URIs: []*url.URL{{Scheme: "spiffe", Host: "evil.org", Path: "/node"}}}, wantRule: "uris"},
+		{name: "forbidden email address", csr: &x509.CertificateRequest{EmailAddresses: []string{"admin@example.com"}}, wantRule: "emailAddresses"},
+	})
+
+	runValidateCases(t, `
+ipAddresses:
+  forbidden: true
+`, []validateCase{
+		{name: "forbidden IP", csr: &x509.CertificateRequest{IPAddresses: []net.IP{net.ParseIP("10.1.2.3")}}, wantRule: "ipAddresses"},
+		{name: "DNS names without patterns", csr: &x509.CertificateRequest{DNSNames: []string{"anything.example.org"}}},
+	})
+}
+
+func TestValidateKeys(t *testing.T) {
+	runValidateCases(t, `
+keyAlgorithms: [ECDSA, RSA]
+curves: [P-256]
+minRSAKeySize: 3072
+signatureAlgorithms: [ECDSA-SHA256, SHA256-RSA]
+`, []validateCase{
+		{name: "allowed curve", keyType: "p256", csr: &x509.CertificateRequest{}},
+		{name: "curve not allowed", keyType: "p384", csr: &x509.CertificateRequest{}, wantRule: "curves"},
+		{name: "RSA key too small", keyType: "rsa2048", csr: &x509.CertificateRequest{}, wantRule: "minRSAKeySize"},
+		{name: "key algorithm not allowed", keyType: "ed25519", csr: &x509.CertificateRequest{}, wantRule: "keyAlgorithms"},
+		{name: "signature algorithm not allowed", keyType: "p256", csr: &x509.CertificateRequest{SignatureAlgorithm: x509.ECDSAWithSHA384}, wantRule: "signatureAlgorithms"},
+	})
+
+	runValidateCases(t, `
+minRSAKeySize: 2048
+`, []validateCase{
+		{name: "RSA key of the minimum size", keyType: "rsa2048", csr: &x509.CertificateRequest{}},
+		{name: "Ed25519 key", keyType: "ed25519", csr: &x509.CertificateRequest{}},
+	})
+}
+
+func TestValidateUsages(t *testing.T) {
+	runValidateCases(t, `
+allowedUsages: [digital signature, client auth, server auth]
+`, []validateCase{
+		{name: "allowed usages", csr: &x509.CertificateRequest{}, usages: []string{"digital signature", "client auth"}},
+		{name: "usage not allowed", csr: &x509.CertificateRequest{}, usages: []string{"code signing"}, wantRule: "allowedUsages"},
+		{name: "restricted usage", csr: &x509.CertificateRequest{}, usages: []string{"ocsp signing"}, wantRule: "allowedUsages"},
+		{name: "CA certificate", csr: &x509.CertificateRequest{}, isCA: true, wantRule: "allowCA"},
+	})
+
+	// the restricted usages are denied even without allowed usages
+	runValidateCases(t, ``, []validateCase{
+		{name: "any usage", csr: &x509.CertificateRequest{}, usages: []string{"code signing"}},
+		{name: "cert sign", csr: &x509.CertificateRequest{}, usages: []string{"cert sign"}, wantRule: "allowedUsages"},
+		{name: "crl sign", csr: &x509.CertificateRequest{}, usages: []string{"crl sign"}, wantRule: "allowedUsages"},
+		{name: "ocsp signing", csr: &x509.CertificateRequest{}, usages: []string{"ocsp signing"}, wantRule: "allowedUsages"},
+		{name: "CA certificate", csr: &x509.CertificateRequest{}, isCA: true, usages: []string{"cert sign"}, wantRule: "allowCA"},
+	})
+
+	runValidateCases(t, `
+allowCA: true
+`, []validateCase{
+		{name: "CA certificate", csr: &x509.CertificateRequest{}, isCA: true, usages: []string{"cert sign", "crl sign"}},
+		{name: "CA certificate with ocsp signing", csr: &x509.CertificateRequest{}, isCA: true, usages: []string{"cert sign", "ocsp signing"}, wantRule: "allowedUsages"},
+		{name: "cert sign without CA", csr: &x509.CertificateRequest{}, usages: []string{"cert sign"}, wantRule: "allowedUsages"},
+	})
+
+	runValidateCases(t, `
+allowedUsages: [digital signature, ocsp signing]
+`, []validateCase{
+		{name: "explicitly allowed restricted usage", csr: &x509.CertificateRequest{}, usages: []string{"digital signature", "ocsp signing"}},
+	})
+}
+
+func TestValidateDuration(t *testing.T) {
+	runValidateCases(t, `
+maxDuration: 720h
+`, []validateCase{
+		{name: "default duration", csr: &x509.CertificateRequest{}},
+		{name: "maximum duration", csr: &x509.CertificateRequest{}, duration: 720 * time.Hour},
+		{name: "duration too long", csr: &x509.CertificateRequest{}, duration: 721 * time.Hour, wantRule: "maxDuration"},
+	})
+
+	runValidateCases(t, ``, []validateCase{
+		{name: "any duration without a maximum", csr: &x509.CertificateRequest{}, duration: 10 * 365 * 24 * time.Hour},
+	})
+}
+
+func TestInvalidPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+	}{
+		{name: "invalid common name pattern", policy: "commonNamePatterns: ['node-[']"},
+		{name: "invalid DNS pattern", policy: "dnsNames: {patterns: ['(']}"},
+		{name: "invalid CIDR", policy: "ipAddresses: {patterns: [10.0.0.1]}"},
+		{name: "negative RSA key size", policy: "minRSAKeySize: -1"},
+		{name: "negative duration", policy: "maxDuration: -1h"},
+		{name: "invalid duration", policy: "maxDuration: a month"},
+		{name: "unknown field", policy: "commonNamePattern: [node]"},
+		{name: "invalid YAML", policy: "commonNamePatterns: ["},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := NewEngine().Load([]byte(tt.policy)); err == nil {
+				t.Errorf("Load() succeeded with an invalid policy")
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package remotesigner
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is the default timeout of the requests to the signing service.
+const DefaultTimeout = 10 * time.Second
+
+// Client is a crypto.Signer that signs with the key of a remote signing service, so that the key
+// never leaves the service.
+type Client struct {
+	url        string
+	httpClient *http.Client
+	publicKey  crypto.PublicKey
+}
+
+// NewClient creates a client of the signing service at `url`, which authenticates with the client certificate
+// of `tlsConfig`. The public key of the service is fetched once, so that the service must be reachable.
+func NewClient(url string, tlsConfig *tls.Config, timeout time.Duration) (*Client, error) {
+	c := &Client{
+		url: strings.TrimSuffix(url, "/"),
+		httpClient: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				TLSClientConfig: tlsConfig,
+			},
+		},
+	}
+
+	resp, err := c.httpClient.Get(c.url + PublicKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get public key from signing service: %s", err)
+	}
+	defer resp.Body.Close()
+
+	publicKeyResponse := &PublicKeyResponse{}
+	if err := decodeResponse(resp, publicKeyResponse); err != nil {
+		return nil, fmt.Errorf("failed to get public key from signing service: %s", err)
+	}
+	c.publicKey, err = x509.ParsePKIXPublicKey(publicKeyResponse.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key of signing service: %s", err)
+	}
+	return c, nil
+}
+
+// Public returns the public key of the signing service.
+func (c *Client) Public() crypto.PublicKey {
+	return c.publicKey
+}
+
+// Sign sends the digest to the signing service, and returns its signature. RSA-PSS is requested if `opts`
+// are *rsa.PSSOptions. `rand` is not used, as the service has its own source of randomness.
+// Transport errors, server errors and rate limiting of the service return an error with a `Temporary() bool`
+// method that returns true, so that the signing can be retried.
+func (c *Client) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	hash, err := hashName(opts.HashFunc())
+	if err != nil {
+		return nil, err
+	}
+	signRequest := &SignRequest{
+		Digest: digest,
+		Hash:   hash,
+	}
+	if pssOptions, ok := opts.(*rsa.PSSOptions); ok {
+		saltLength := pssOptions.SaltLength
+		signRequest.PSSSaltLength = &saltLength
+	}
+
+	body, err := json.Marshal(signRequest)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Post(c.url+SignPath, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, &temporaryError{err: fmt.Errorf("failed to sign with signing service: %s", err)}
+	}
+	defer resp.Body.Close()
+
+	signResponse := &SignResponse{}
+	if err := decodeResponse(resp, signResponse); err != nil {
+		if _, ok := err.(*temporaryError); ok {
+			return nil, &temporaryError{err: fmt.Errorf("failed to sign with signing service: %s", err)}
+		}
+		return nil, fmt.Errorf("failed to sign with signing service: %s", err)
+	}
+	return signResponse.Signature, nil
+}
+
+// temporaryError is an error that can go away when the request is retried: a transport error, or a server
+// error or rate limiting of the service
+type temporaryError struct {
+	err error
+}
+
+func (e *temporaryError) Error() string {
+	return e.err.Error()
+}
+
+// Temporary returns true, so that callers can retry the request
+func (e *temporaryError) Temporary() bool {
+	return true
+}
+
+// decodeResponse decodes the JSON response, or returns the error message of the service, which is temporary
+// for server errors and rate limiting
+func decodeResponse(resp *http.Response, v interface{}) error {
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxRequestSize))
+		err := fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+		if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+			return &temporaryError{err: err}
+		}
+		return err
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxRequestSize)).Decode(v); err != nil {
+		// the response can be cut off by the transport
+		return &temporaryError{err: fmt.Errorf("invalid response: %s", err)}
+	}
+	return nil
+}
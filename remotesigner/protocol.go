@@ -0,0 +1,65 @@
+package remotesigner
+
+import (
+	"crypto"
+	"fmt"
+)
+
+// Paths of the endpoints of the signing service.
+const (
+	PublicKeyPath = "/v1/publickey"
+	SignPath      = "/v1/sign"
+)
+
+// maxRequestSize is the maximum size of a request that the signing service accepts.
+const maxRequestSize = 64 * 1024
+
+// PublicKeyResponse holds the public key of the signing service.
+type PublicKeyResponse struct {
+	// PublicKey is the DER encoded PKIX public key
+	PublicKey []byte `json:"publicKey"`
+}
+
+// SignRequest asks the signing service to sign a digest.
+type SignRequest struct {
+	// Digest is the hashed message, or the message itself for Ed25519 keys
+	Digest []byte `json:"digest"`
+	// Hash is the name of the hash function of the digest, e.g. `SHA-256`, or empty for Ed25519 keys
+	Hash string `json:"hash,omitempty"`
+	// PSSSaltLength requests an RSA-PSS signature with the given salt length if set
+	PSSSaltLength *int `json:"pssSaltLength,omitempty"`
+}
+
+// SignResponse holds the signature of a digest.
+type SignResponse struct {
+	Signature []byte `json:"signature"`
+}
+
+// hashes are the hash functions that the signing service signs digests of
+var hashes = []crypto.Hash{crypto.SHA256, crypto.SHA384, crypto.SHA512}
+
+// hashName returns the name of the hash function in requests
+func hashName(hash crypto.Hash) (string, error) {
+	if hash == 0 {
+		return "", nil
+	}
+	for _, h := range hashes {
+		if h == hash {
+			return h.String(), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported hash function %v", hash)
+}
+
+// parseHash returns the hash function of its name in requests
+func parseHash(name string) (crypto.Hash, error) {
+	if name == "" {
+		return 0, nil
+	}
+	for _, h := range hashes {
+		if h.String() == name {
+			return h, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported hash function '%s'", name)
+}
@@ -0,0 +1,397 @@
+package remotesigner
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testPKI holds the files of a CA and of the certificates it issued
+type testPKI struct {
+	dir    string
+	caPath string
+	caCert *x509.Certificate
+	caKey  crypto.Signer
+}
+
+// newTestPKI creates a CA whose certificate is written to a file in `dir`
+func newTestPKI(t *testing.T, dir, name string) *testPKI {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse CA certificate: %s", err)
+	}
+
+	pki := &testPKI{dir: dir, caPath: filepath.Join(dir, name+".pem"), caCert: cert, caKey: key}
+	writePEM(t, pki.caPath, "CERTIFICATE", der)
+	return pki
+}
+
+// issue creates a TLS certificate and key for `name`, and returns the paths of their files
+func (p *testPKI) issue(t *testing.T, name string, usage x509.ExtKeyUsage) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, p.caCert, key.Public(), p.caKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to encode key: %s", err)
+	}
+
+	certPath := filepath.Join(p.dir, name+".crt")
+	keyPath := filepath.Join(p.dir, name+".key")
+	writePEM(t, certPath, "CERTIFICATE", der)
+	writePEM(t, keyPath, "EC PRIVATE KEY", keyDER)
+	return certPath, keyPath
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatalf("failed to write %s: %s", path, err)
+	}
+}
+
+// newTestServer starts a signing service for the signer with mutual TLS, requiring client certificates of the PKI
+func newTestServer(t *testing.T, pki *testPKI, signer crypto.Signer) *httptest.Server {
+	t.Helper()
+
+	server, err := NewServer(signer)
+	if err != nil {
+		t.Fatalf("NewServer() error = %s", err)
+	}
+	certPath, keyPath := pki.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	tlsConfig, err := ServerTLSConfig(certPath, keyPath, pki.caPath)
+	if err != nil {
+		t.Fatalf("ServerTLSConfig() error = %s", err)
+	}
+
+	httpServer := httptest.NewUnstartedServer(server)
+	httpServer.TLS = tlsConfig
+	httpServer.StartTLS()
+	return httpServer
+}
+
+func newTestDir(t *testing.T) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "remotesigner")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	return dir
+}
+
+func TestClientSign(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	pki := newTestPKI(t, dir, "ca")
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %s", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %s", err)
+	}
+
+	message := []byte("message")
+	digest := sha256.Sum256(message)
+	pssOptions := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+
+	tests := []struct {
+		name   string
+		signer crypto.Signer
+		digest []byte
+		opts   crypto.SignerOpts
+		verify func(signature []byte) bool
+	}{
+		{
+			name:   "ecdsa",
+			signer: ecdsaKey,
+			digest: digest[:],
+			opts:   crypto.SHA256,
+			verify: func(signature []byte) bool {
+				return ecdsa.VerifyASN1(&ecdsaKey.PublicKey, digest[:], signature)
+			},
+		},
+		{
+			name:   "rsa",
+			signer: rsaKey,
+			digest: digest[:],
+			opts:   crypto.SHA256,
+			verify: func(signature []byte) bool {
+				return rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], signature) == nil
+			},
+		},
+		{
+			name:   "rsa-pss",
+			signer: rsaKey,
+			digest: digest[:],
+			opts:   pssOptions,
+			verify: func(signature []byte) bool {
+				return rsa.VerifyPSS(&rsaKey.PublicKey, crypto.SHA256, digest[:], signature, pssOptions) == nil
+			},
+		},
+		{
+			name:   "ed25519",
+			signer: ed25519Key,
+			digest: message,
+			opts:   crypto.Hash(0),
+			verify: func(signature []byte) bool {
+				return ed25519.Verify(ed25519Key.Public().(ed25519.PublicKey), message, signature)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, pki, tt.signer)
+			defer server.Close()
+
+			certPath, keyPath := pki.issue(t, "client-"+tt.name, x509.ExtKeyUsageClientAuth)
+			tlsConfig, err := ClientTLSConfig(certPath, keyPath, pki.caPath)
+			if err != nil {
+				t.Fatalf("ClientTLSConfig() error = %s", err)
+			}
+			client, err := NewClient(server.URL, tlsConfig, DefaultTimeout)
+			if err != nil {
+				t.Fatalf("NewClient() error = %s", err)
+			}
+			if !client.Public().(interface{ Equal(x crypto.PublicKey) bool }).Equal(tt.signer.Public()) {
+				t.Errorf("Public() differs from the key of the signing service")
+			}
+
+			signature, err := client.Sign(rand.Reader, tt.digest, tt.opts)
+			if err != nil {
+				t.Fatalf("Sign() error = %s", err)
+			}
+			if !tt.verify(signature) {
+				t.Errorf("signature of the signing service does not verify")
+			}
+		})
+	}
+}
+
+func TestClientSignUnsupportedHash(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	pki := newTestPKI(t, dir, "ca")
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	server := newTestServer(t, pki, key)
+	defer server.Close()
+
+	certPath, keyPath := pki.issue(t, "client", x509.ExtKeyUsageClientAuth)
+	tlsConfig, err := ClientTLSConfig(certPath, keyPath, pki.caPath)
+	if err != nil {
+		t.Fatalf("ClientTLSConfig() error = %s", err)
+	}
+	client, err := NewClient(server.URL, tlsConfig, DefaultTimeout)
+	if err != nil {
+		t.Fatalf("NewClient() error = %s", err)
+	}
+
+	if _, err := client.Sign(rand.Reader, make([]byte, 20), crypto.SHA1); err == nil {
+		t.Errorf("Sign() succeeded with SHA-1")
+	}
+	if _, err := client.Sign(rand.Reader, make([]byte, 16), crypto.SHA256); err == nil {
+		t.Errorf("Sign() succeeded with a digest that does not match the hash function")
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	pki := newTestPKI(t, dir, "ca")
+	otherPKI := newTestPKI(t, dir, "other-ca")
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	server := newTestServer(t, pki, key)
+	defer server.Close()
+
+	certPath, keyPath := pki.issue(t, "client", x509.ExtKeyUsageClientAuth)
+	otherCertPath, otherKeyPath := otherPKI.issue(t, "other-client", x509.ExtKeyUsageClientAuth)
+
+	caPool := x509.NewCertPool()
+	caPool.AddCert(pki.caCert)
+
+	tests := []struct {
+		name      string
+		tlsConfig func() (*tls.Config, error)
+	}{
+		{
+			name: "no client certificate",
+			tlsConfig: func() (*tls.Config, error) {
+				return &tls.Config{RootCAs: caPool, MinVersion: tls.VersionTLS12}, nil
+			},
+		},
+		{
+			name: "client certificate of another CA",
+			tlsConfig: func() (*tls.Config, error) {
+				return ClientTLSConfig(otherCertPath, otherKeyPath, pki.caPath)
+			},
+		},
+		{
+			name: "server certificate of another CA",
+			tlsConfig: func() (*tls.Config, error) {
+				return ClientTLSConfig(certPath, keyPath, otherPKI.caPath)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig, err := tt.tlsConfig()
+			if err != nil {
+				t.Fatalf("failed to create TLS configuration: %s", err)
+			}
+			if _, err := NewClient(server.URL, tlsConfig, DefaultTimeout); err == nil {
+				t.Errorf("NewClient() succeeded")
+			}
+		})
+	}
+
+	if _, err := ClientTLSConfig(certPath, keyPath, filepath.Join(dir, "missing.pem")); err == nil {
+		t.Errorf("ClientTLSConfig() succeeded with a missing CA file")
+	}
+	if _, err := ServerTLSConfig(certPath, otherKeyPath, pki.caPath); err == nil {
+		t.Errorf("ServerTLSConfig() succeeded with a key that does not match the certificate")
+	}
+}
+
+func TestClientSignTemporaryErrors(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	pki := newTestPKI(t, dir, "ca")
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	signServer, err := NewServer(key)
+	if err != nil {
+		t.Fatalf("NewServer() error = %s", err)
+	}
+
+	// the status of the sign endpoint, which signs if 0
+	var status int32
+	certPath, keyPath := pki.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	serverTLSConfig, err := ServerTLSConfig(certPath, keyPath, pki.caPath)
+	if err != nil {
+		t.Fatalf("ServerTLSConfig() error = %s", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if code := int(atomic.LoadInt32(&status)); req.URL.Path == SignPath && code != 0 {
+			http.Error(w, http.StatusText(code), code)
+			return
+		}
+		signServer.ServeHTTP(w, req)
+	}))
+	server.TLS = serverTLSConfig
+	server.StartTLS()
+
+	clientCertPath, clientKeyPath := pki.issue(t, "client", x509.ExtKeyUsageClientAuth)
+	tlsConfig, err := ClientTLSConfig(clientCertPath, clientKeyPath, pki.caPath)
+	if err != nil {
+		t.Fatalf("ClientTLSConfig() error = %s", err)
+	}
+	client, err := NewClient(server.URL, tlsConfig, DefaultTimeout)
+	if err != nil {
+		t.Fatalf("NewClient() error = %s", err)
+	}
+
+	digest := sha256.Sum256([]byte("message"))
+	tests := []struct {
+		status    int
+		temporary bool
+	}{
+		{status: http.StatusServiceUnavailable, temporary: true},
+		{status: http.StatusInternalServerError, temporary: true},
+		{status: http.StatusTooManyRequests, temporary: true},
+		{status: http.StatusBadRequest, temporary: false},
+		{status: http.StatusForbidden, temporary: false},
+	}
+	for _, tt := range tests {
+		atomic.StoreInt32(&status, int32(tt.status))
+		_, err := client.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err == nil {
+			t.Fatalf("Sign() succeeded with status %d", tt.status)
+		}
+		if temporary := isTemporary(err); temporary != tt.temporary {
+			t.Errorf("Sign() error with status %d is temporary: %v, want %v", tt.status, temporary, tt.temporary)
+		}
+	}
+
+	// transport errors are temporary as well
+	server.Close()
+	if _, err := client.Sign(rand.Reader, digest[:], crypto.SHA256); err == nil || !isTemporary(err) {
+		t.Errorf("Sign() error = %v with the signing service down, want a temporary error", err)
+	}
+}
+
+func isTemporary(err error) bool {
+	temporary, ok := err.(interface{ Temporary() bool })
+	return ok && temporary.Temporary()
+}
@@ -0,0 +1,91 @@
+package remotesigner
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// Server is the HTTP handler of a signing service, which signs digests with a key that never leaves it.
+// It must be served with mutual TLS, as every authenticated client can sign with the key.
+type Server struct {
+	signer    crypto.Signer
+	publicKey []byte
+}
+
+// NewServer creates the HTTP handler of a signing service that signs with `signer`.
+func NewServer(signer crypto.Signer) (*Server, error) {
+	publicKey, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		signer:    signer,
+		publicKey: publicKey,
+	}, nil
+}
+
+// ServeHTTP serves the public key and signs digests.
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch {
+	case req.URL.Path == PublicKeyPath && req.Method == http.MethodGet:
+		writeJSON(w, &PublicKeyResponse{PublicKey: s.publicKey})
+	case req.URL.Path == SignPath && req.Method == http.MethodPost:
+		s.sign(w, req)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// sign signs the digest of the request
+func (s *Server) sign(w http.ResponseWriter, req *http.Request) {
+	signRequest := &SignRequest{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxRequestSize)).Decode(signRequest); err != nil {
+		http.Error(w, "invalid sign request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hash, err := parseHash(signRequest.Hash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if hash != 0 && len(signRequest.Digest) != hash.Size() {
+		http.Error(w, "digest does not match the size of the hash function", http.StatusBadRequest)
+		return
+	}
+
+	var opts crypto.SignerOpts = hash
+	if signRequest.PSSSaltLength != nil {
+		opts = &rsa.PSSOptions{
+			SaltLength: *signRequest.PSSSaltLength,
+			Hash:       hash,
+		}
+	}
+
+	signature, err := s.signer.Sign(rand.Reader, signRequest.Digest, opts)
+	if err != nil {
+		zap.L().Warn("Error signing digest", zap.Error(err), zap.String("hash", signRequest.Hash))
+		http.Error(w, "failed to sign: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var client string
+	if req.TLS != nil && len(req.TLS.PeerCertificates) > 0 {
+		client = req.TLS.PeerCertificates[0].Subject.String()
+	}
+	zap.L().Info("Digest signed", zap.String("client", client), zap.String("hash", signRequest.Hash))
+	writeJSON(w, &SignResponse{Signature: signature})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		zap.L().Error("Error writing response", zap.Error(err))
+	}
+}
@@ -0,0 +1,55 @@
+package remotesigner
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// ClientTLSConfig returns the mutual TLS configuration of a client, which authenticates with the certificate
+// and key files, and trusts the signing service if its certificate is issued by the CA file.
+func ClientTLSConfig(certPath, keyPath, caPath string) (*tls.Config, error) {
+	cert, caPool, err := loadTLSFiles(certPath, keyPath, caPath)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      caPool,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// ServerTLSConfig returns the mutual TLS configuration of a signing service, which serves the certificate and
+// key files, and requires client certificates issued by the CA file.
+func ServerTLSConfig(certPath, keyPath, caPath string) (*tls.Config, error) {
+	cert, caPool, err := loadTLSFiles(certPath, keyPath, caPath)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    caPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// loadTLSFiles loads the certificate and key, and the pool of the CA certificates
+func loadTLSFiles(certPath, keyPath, caPath string) (tls.Certificate, *x509.CertPool, error) {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("unable to load TLS certificate: %s", err)
+	}
+
+	caPEM, err := ioutil.ReadFile(caPath)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("unable to read TLS CA file: %s", err)
+	}
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caPEM) {
+		return tls.Certificate{}, nil, fmt.Errorf("no PEM certificate found in TLS CA file %s", caPath)
+	}
+	return cert, caPool, nil
+}